@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"net/http"
+
+	"github.com/ophusdev/openmeteogo"
+)
+
+// AirQuality is the current air quality at a location.
+type AirQuality struct {
+	// AQI is the European Air Quality Index, 0 (good) to 100 and more (extremely poor)
+	AQI int
+	// PM10 and PM25 are the particulate matter concentrations in μg/m³
+	PM10 float64
+	PM25 float64
+}
+
+// FetchAirQuality fetches the current air quality from the Air Quality API
+// of Open-Meteo.
+func FetchAirQuality(ctx context.Context, client *http.Client, lat, lon float64) (AirQuality, error) {
+	opts := &openmeteogo.CurrentAirQualityOptions{
+		Latitude:  lat,
+		Longitude: lon,
+		Current: &[]openmeteogo.OpenMeteoConst{
+			openmeteogo.CurrentAirQualityEuropeanAqi,
+			openmeteogo.CurrentAirQualityPm10,
+			openmeteogo.CurrentAirQualityPm25,
+		},
+	}
+
+	response, err := openmeteogo.NewClient(client).CurrentAirQuality.Forecast(ctx, opts)
+	if err != nil {
+		return AirQuality{}, fmt.Errorf("failed to fetch air quality: %w", err)
+	}
+
+	current := response.Current
+	if current.EuropeanAqi == nil {
+		return AirQuality{}, errors.New("failed to fetch air quality: no european_aqi in the response")
+	}
+
+	quality := AirQuality{AQI: int(math.Round(*current.EuropeanAqi))}
+	if current.Pm10 != nil {
+		quality.PM10 = *current.Pm10
+	}
+	if current.Pm25 != nil {
+		quality.PM25 = *current.Pm25
+	}
+
+	return quality, nil
+}
+
+// aqiLevel is a band of the European Air Quality Index.
+type aqiLevel struct {
+	// Max is the highest AQI of the band
+	Max     int
+	LabelDE string
+	LabelEN string
+	Color   color.RGBA
+}
+
+// aqiLevels are the bands of the European Air Quality Index. Values above
+// the last band ("extremely poor") are shown as the last band.
+var aqiLevels = []aqiLevel{
+	{20, "Gut", "Good", ColorGreen},
+	{40, "Akzeptabel", "Fair", ColorGreen},
+	{60, "Mäßig", "Moderate", ColorYellow},
+	{80, "Schlecht", "Poor", ColorRed},
+	{100, "Sehr schlecht", "Very poor", ColorRed},
+}
+
+// Level returns the band of the AQI.
+func (q AirQuality) Level() aqiLevel {
+	for _, level := range aqiLevels {
+		if q.AQI <= level.Max {
+			return level
+		}
+	}
+	return aqiLevels[len(aqiLevels)-1]
+}
+
+// Layout of the AQI badge.
+const (
+	aqiBadgeHeight  = 22
+	aqiBadgePadding = 8
+)
+
+// drawAirQuality draws a badge in the color of the AQI's band, e.g.
+// "AQI 35 · Akzeptabel", with its left edge at x and its baseline at y.
+func drawAirQuality(dc *dashboardCanvas, quality AirQuality, x, y int, locale Locale) error {
+	err := setFont(dc.Context, FontBold, FontSizeXXS)
+	if err != nil {
+		return err
+	}
+
+	level := quality.Level()
+	label := level.LabelDE
+	if locale == LocaleEnglish {
+		label = level.LabelEN
+	}
+	text := fmt.Sprintf("AQI %d · %s", quality.AQI, label)
+	textW, _ := dc.MeasureString(text)
+
+	dc.SetColor(level.Color)
+	dc.DrawRoundedRectangle(float64(x), float64(y-aqiBadgeHeight+4), textW+2*aqiBadgePadding, aqiBadgeHeight, 4)
+	dc.Fill()
+
+	// White is hard to read on yellow.
+	dc.SetColor(ColorWhite)
+	if level.Color == ColorYellow {
+		dc.SetColor(ColorBlack)
+	}
+	dc.DrawStringAnchored(text, float64(x+aqiBadgePadding), float64(y-aqiBadgeHeight/2+4), 0, 0.35)
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+)
+
+// EmptyAppointments selects what the list view shows without appointments.
+type EmptyAppointments string
+
+const (
+	// EmptyAppointmentsPlaceholder keeps the heading and says that there are no appointments (default)
+	EmptyAppointmentsPlaceholder EmptyAppointments = "placeholder"
+	// EmptyAppointmentsCollapse leaves out the section and moves the quote up
+	EmptyAppointmentsCollapse EmptyAppointments = "collapse"
+)
+
+// UnmarshalText validates the empty appointments behavior from the config file.
+func (e *EmptyAppointments) UnmarshalText(text []byte) error {
+	switch EmptyAppointments(text) {
+	case EmptyAppointmentsPlaceholder, EmptyAppointmentsCollapse:
+		*e = EmptyAppointments(text)
+	case "":
+		*e = EmptyAppointmentsPlaceholder
+	default:
+		return fmt.Errorf("invalid appointments empty: %s (expected placeholder or collapse)", string(text))
+	}
+
+	return nil
+}
+
+// collapseAppointments reports whether the appointments section is left out.
+// It is only collapsed if nothing else is shown in it, otherwise the
+// placeholder is drawn.
+func (config *DashboardConfig) collapseAppointments() bool {
+	return config.EmptyAppointments == EmptyAppointmentsCollapse &&
+		config.AppointmentView != AppointmentViewWeekGrid &&
+		len(config.Appointments) == 0 &&
+		config.Steps == nil &&
+		config.Sleep == nil &&
+		len(config.Medications) == 0 &&
+		len(config.Garbage) == 0 &&
+		len(config.Pollen) == 0 &&
+		len(config.Todos) == 0 &&
+		len(config.Departures) == 0 &&
+		(config.Word == nil || config.WordReplacesQuote) &&
+		!config.ShowYearProgress &&
+		!config.ShowMiniMonth
+}
+
+// drawNoAppointments says that there are no appointments in the horizon,
+// centered between the heading at top and bottom.
+func drawNoAppointments(dc *dashboardCanvas, config *DashboardConfig, top, bottom int) error {
+	err := setFont(dc.Context, FontRegular, FontSizeSM)
+	if err != nil {
+		return err
+	}
+
+	days := int(appointmentHorizon.Hours() / 24)
+	label := fmt.Sprintf("Keine Termine in den nächsten %d Tagen", days)
+	if config.Locale == LocaleEnglish {
+		label = fmt.Sprintf("No appointments in the next %d days", days)
+	}
+
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(label, float64(config.Width)/2, float64(top+bottom)/2, 0.5, 0.5)
+
+	return nil
+}
+
+// appointmentOverflowHeight is the height of the line with the number of
+// hidden appointments.
+const appointmentOverflowHeight = 16
+
+// drawAppointmentOverflow draws the number of hidden appointments right
+// aligned with its baseline at y, e.g. "+3 weitere Termine".
+func drawAppointmentOverflow(dc *dashboardCanvas, config *DashboardConfig, hidden, y int) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return err
+	}
+
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(appointmentOverflowLabel(hidden, config.Locale), float64(config.Width-config.Padding*2), float64(y), 1, 0)
+
+	return nil
+}
+
+// appointmentOverflowLabel returns the label of drawAppointmentOverflow.
+func appointmentOverflowLabel(hidden int, locale Locale) string {
+	switch {
+	case locale == LocaleEnglish && hidden == 1:
+		return "+1 more appointment"
+	case locale == LocaleEnglish:
+		return fmt.Sprintf("+%d more appointments", hidden)
+	case hidden == 1:
+		return "+1 weiterer Termin"
+	default:
+		return fmt.Sprintf("+%d weitere Termine", hidden)
+	}
+}
+
+// Countdowns are shown for the next appointment and for all appointments
+// that start within countdownHorizon.
+const (
+	countdownHorizon           = 4 * time.Hour
+	appointmentCountdownHeight = 16
+)
+
+// appointmentCountdown returns the time until the i-th appointment, e.g.
+// "noch 2h 15min", or an empty string if it gets no countdown.
+func appointmentCountdown(config *DashboardConfig, i int, now time.Time) string {
+	if !config.ShowEventCountdown {
+		return ""
+	}
+
+	start := config.Appointments[i].Start
+	if !start.After(now) {
+		return ""
+	}
+	// The earlier appointments have started already, so this is the next one.
+	next := i == 0 || !config.Appointments[i-1].Start.After(now)
+	if !next && start.Sub(now) > countdownHorizon {
+		return ""
+	}
+
+	return formatCountdown(start.Sub(now), config.Locale)
+}
+
+// formatCountdown formats d as hours and minutes below a day, e.g.
+// "noch 2h 15min", and as days from then on, e.g. "noch 3 Tage".
+func formatCountdown(d time.Duration, locale Locale) string {
+	prefix := "noch "
+	if locale == LocaleEnglish {
+		prefix = "in "
+	}
+
+	if d >= 24*time.Hour {
+		days := int(d / (24 * time.Hour))
+		switch {
+		case locale == LocaleEnglish && days == 1:
+			return prefix + "1 day"
+		case locale == LocaleEnglish:
+			return fmt.Sprintf("%s%d days", prefix, days)
+		case days == 1:
+			return prefix + "1 Tag"
+		default:
+			return fmt.Sprintf("%s%d Tage", prefix, days)
+		}
+	}
+
+	hours, minutes := int(d/time.Hour), int(d%time.Hour/time.Minute)
+	if hours == 0 {
+		return fmt.Sprintf("%s%dmin", prefix, minutes)
+	}
+	return fmt.Sprintf("%s%dh %dmin", prefix, hours, minutes)
+}
+
+// drawAppointmentCountdown draws the countdown in red right aligned with
+// its baseline at y, below the date of the appointment.
+func drawAppointmentCountdown(dc *dashboardCanvas, config *DashboardConfig, label string, y int) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return err
+	}
+
+	dc.SetColor(ColorRed)
+	dc.DrawStringAnchored(label, float64(config.Width-config.Padding*2), float64(y), 1, 0)
+
+	return nil
+}
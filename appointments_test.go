@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/ophusdev/openmeteogo"
+)
+
+func TestEmptyAppointments(t *testing.T) {
+	now := time.Date(2025, time.March, 14, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		empty EmptyAppointments
+	}{
+		{name: "appointments_placeholder", empty: EmptyAppointmentsPlaceholder},
+		{name: "appointments_collapse", empty: EmptyAppointmentsCollapse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := fixtureConfig(now)
+			cfg.Now = now
+			cfg.Appointments = nil
+			cfg.EmptyAppointments = tt.empty
+
+			dc, err := GenerateDashboard(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.name, dc.Image())
+		})
+	}
+}
+
+func TestBuildAppointmentsTotal(t *testing.T) {
+	berlin := loadBerlin(t)
+	at := func(offset int) time.Time {
+		return testDay(berlin, offset).Add(10 * time.Hour)
+	}
+
+	var events []string
+	for i := range 5 {
+		events = append(events, fmt.Sprintf("UID:e%d\nSUMMARY:Termin %d\nDTSTART;TZID=Europe/Berlin:%s", i, i, icsLocal(at(i+1))))
+	}
+	srv := NewCalendarTestServer(t, icsCalendar(events...))
+	cals := Calendars{NewCalendar("C", ColorBlue, srv.URL, srv.Client())}
+
+	tests := []struct {
+		name  string
+		until time.Time
+		limit int
+		shown int
+		total int
+	}{
+		{name: "over the limit", until: at(10), limit: 3, shown: 3, total: 5},
+		{name: "without a limit", until: at(10), limit: 0, shown: 5, total: 5},
+		// The events after the horizon aren't counted as hidden.
+		{name: "horizon", until: testDay(berlin, 3), limit: 2, shown: 2, total: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appointments, total, err := buildAppointments(context.Background(), cals, tt.until, berlin, false, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(appointments) != tt.shown || total != tt.total {
+				t.Errorf("got %d of %d appointments, want %d of %d", len(appointments), total, tt.shown, tt.total)
+			}
+		})
+	}
+}
+
+func TestSnapshotAppointmentTotal(t *testing.T) {
+	clock := newFakeClock()
+	cache := newTestCache(clock)
+	cache.dailyWeather = &openmeteogo.DailyWeatherResponse{}
+	cache.hourlyWeather = &openmeteogo.HourlyWeatherResponse{}
+
+	event := func(uid string, start time.Time) CalendarEvent {
+		vevent := ics.NewEvent(uid)
+		vevent.SetSummary(uid)
+		return CalendarEvent{VEvent: vevent, Start: start}
+	}
+	// The events that started since the fetch are neither shown nor counted.
+	now := clock.Now()
+	cache.events["past"] = []CalendarEvent{event("past", now.Add(-time.Hour))}
+	for i := range calendarEventCount + 2 {
+		name := fmt.Sprintf("e%d", i)
+		cache.events[name] = []CalendarEvent{event(name, now.Add(time.Duration(i+1)*time.Hour))}
+	}
+
+	data, err := cache.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Appointments) != calendarEventCount || data.AppointmentTotal != calendarEventCount+2 {
+		t.Errorf("got %d of %d appointments, want %d of %d", len(data.Appointments), data.AppointmentTotal, calendarEventCount, calendarEventCount+2)
+	}
+}
+
+func TestAppointmentOverflowLabel(t *testing.T) {
+	tests := []struct {
+		hidden int
+		locale Locale
+		want   string
+	}{
+		{1, LocaleGerman, "+1 weiterer Termin"},
+		{3, LocaleGerman, "+3 weitere Termine"},
+		{1, LocaleEnglish, "+1 more appointment"},
+		{3, LocaleEnglish, "+3 more appointments"},
+	}
+
+	for _, tt := range tests {
+		if got := appointmentOverflowLabel(tt.hidden, tt.locale); got != tt.want {
+			t.Errorf("appointmentOverflowLabel(%d, %s) = %q, want %q", tt.hidden, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestAppointmentOverflow(t *testing.T) {
+	now := time.Date(2025, time.March, 14, 9, 30, 0, 0, time.UTC)
+
+	// Five of nine appointments are shown, the line goes below the last.
+	cfg := fixtureConfig(now)
+	cfg.Now = now
+	cfg.AppointmentTotal = 9
+
+	dc, err := GenerateDashboard(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "appointments_overflow", dc.Image())
+}
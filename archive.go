@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// defaultArchiveMaxAgeDays is used if ArchiveMaxAgeDays is not set.
+const defaultArchiveMaxAgeDays = 30
+
+// archiveTimeFormat is the timestamp in the names of archived images.
+const archiveTimeFormat = "2006-01-02T15:04:05"
+
+// archiveDashboard saves a copy of the rendered dashboard to dir
+// (e.g., "dash-2024-01-15T14:30:00.png"). The directory is created if needed.
+func archiveDashboard(canvas *gg.Context, dir string, now time.Time) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	path := filepath.Join(dir, "dash-"+now.Format(archiveTimeFormat)+".png")
+	if err := saveDashboard(canvas.Image(), path, OutputPNG); err != nil {
+		return fmt.Errorf("failed to save archived image: %w", err)
+	}
+
+	return nil
+}
+
+// pruneArchive removes the archived images in dir that are older than
+// maxAgeDays. Other files are left alone.
+func pruneArchive(dir string, maxAgeDays int, now time.Time) error {
+	if maxAgeDays <= 0 {
+		maxAgeDays = defaultArchiveMaxAgeDays
+	}
+	cutoff := now.AddDate(0, 0, -maxAgeDays)
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "dash-") || !strings.HasSuffix(name, ".png") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		if err = os.Remove(filepath.Join(dir, name)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Debug("removed archived dashboard", "file", name)
+	}
+
+	if err = errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to prune archive: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,660 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ophusdev/openmeteogo"
+)
+
+// Names of the data sources refreshed by DataCache. Calendars are
+// named "calendar:" followed by the calendar's name, the other weather
+// locations "weather:" followed by the location's name.
+const (
+	sourceWeather        = "weather"
+	sourceWeatherPrefix  = "weather:"
+	sourceQuote          = "quote"
+	sourceNetwork        = "network"
+	sourceFitness        = "fitness"
+	sourceSleep          = "sleep"
+	sourceTodos          = "todos"
+	sourceWord           = "word"
+	sourceTransit        = "transit"
+	sourceExchangeRates  = "exchange_rates"
+	sourcePollen         = "pollen"
+	sourceAirQuality     = "air_quality"
+	sourceWeatherHistory = "weather_history"
+	sourceCalendarPrefix = "calendar:"
+)
+
+// Refresh intervals of the data sources. The weather, the quote and the
+// calendars can be configured with their refresh setting.
+const (
+	weatherTTL  = 30 * time.Minute
+	calendarTTL = 15 * time.Minute
+	quoteTTL    = 6 * time.Hour
+	networkTTL  = time.Minute
+	fitnessTTL  = 15 * time.Minute
+	sleepTTL    = 15 * time.Minute
+	todosTTL    = 15 * time.Minute
+	// The departures are looked up in the downloaded feed, which is
+	// downloaded again after transit.refresh.
+	transitTTL = 5 * time.Minute
+	// The word changes at midnight, it is fetched again soon after.
+	wordTTL = time.Hour
+	// The ECB publishes the rates once per working day.
+	exchangeRatesTTL = 6 * time.Hour
+	// The DWD updates the pollen forecast once a day.
+	pollenTTL = 6 * time.Hour
+	// Open-Meteo updates the air quality every hour.
+	airQualityTTL = time.Hour
+	// Past days don't change, only a new one is added at midnight.
+	weatherHistoryTTL = 6 * time.Hour
+
+	// retryInterval is used instead of the TTL after a failed fetch.
+	retryInterval = time.Minute
+)
+
+// transitSpareDepartures are fetched in addition to the shown departures,
+// as some of them pass before the next fetch.
+const transitSpareDepartures = 4
+
+// errNoWeather is returned by Snapshot if the weather was never fetched.
+var errNoWeather = errors.New("no weather data available")
+
+// cacheSource is a data source that is refreshed in the background.
+type cacheSource struct {
+	name string
+	ttl  time.Duration
+	// fetch fetches the data and stores it in the cache.
+	fetch func(ctx context.Context) error
+	// payload returns the stored data that is shown on the dashboard. It
+	// is called with the cache locked after a successful fetch.
+	payload func() any
+}
+
+// DataCache refreshes every data source in its own goroutine, so a slow
+// calendar server does not delay the weather and vice versa. The dashboard
+// is rendered from the most recently fetched values. No source is fetched
+// during the quiet hours, they are all fetched when the window ends.
+type DataCache struct {
+	cfg      config
+	location *time.Location
+	client   *http.Client
+	sources  []cacheSource
+	ready    sync.WaitGroup
+	// now and after are the clock of the cache, replaced by the tests.
+	now   func() time.Time
+	after func(d time.Duration) <-chan time.Time
+
+	mu            sync.RWMutex
+	dailyWeather  *openmeteogo.DailyWeatherResponse
+	hourlyWeather *openmeteogo.HourlyWeatherResponse
+	// locations are the daily forecasts of the other weather locations in
+	// the order of the config, nil until fetched.
+	locations     []*openmeteogo.DailyWeatherResponse
+	events        map[string][]CalendarEvent
+	quote         quote
+	network       *NetworkStatus
+	steps         *stepCount
+	sleep         *SleepSummary
+	todos         []TodoItem
+	word          *WordEntry
+	departures    []Departure
+	exchangeRates []exchangeRate
+	pollen        []PollenEntry
+	airQuality    *AirQuality
+	history       *openmeteogo.DailyWeatherResponse
+	errs          map[string]error
+	updated       map[string]time.Time
+	// fetched is the time of the last fetch of every source, successful
+	// or not. changed is closed and replaced after every fetch.
+	fetched map[string]time.Time
+	changed chan struct{}
+	// version is incremented by every fetch that changed the payload of
+	// its source. hashes are the payload hashes of the last fetches.
+	version uint64
+	hashes  map[string]uint64
+}
+
+// NewDataCache creates a cache for the sources of the config. Call Start to
+// begin fetching.
+func NewDataCache(cfg config, location *time.Location) *DataCache {
+	c := &DataCache{
+		cfg:      cfg,
+		location: location,
+		client:   buildHTTPClient(cfg),
+		now:      time.Now,
+		after:    time.After,
+		events:   make(map[string][]CalendarEvent),
+		errs:     make(map[string]error),
+		updated:  make(map[string]time.Time),
+		fetched:  make(map[string]time.Time),
+		changed:  make(chan struct{}),
+		hashes:   make(map[string]uint64),
+	}
+
+	c.sources = append(c.sources, cacheSource{name: sourceWeather, ttl: cfg.Weather.Refresh.Or(weatherTTL), fetch: c.fetchWeather, payload: c.weatherPayload})
+	others := cfg.WeatherLocations()[1:]
+	c.locations = make([]*openmeteogo.DailyWeatherResponse, len(others))
+	for i, place := range others {
+		c.sources = append(c.sources, cacheSource{name: sourceWeatherPrefix + place.Name, ttl: cfg.Weather.Refresh.Or(weatherTTL), fetch: c.locationFetcher(i, place), payload: func() any { return c.locations[i].Daily }})
+	}
+	if pinned, ok := cfg.PinnedQuote(); ok {
+		c.quote = pinned
+	} else {
+		c.sources = append(c.sources, cacheSource{name: sourceQuote, ttl: cfg.Quote.Refresh.Or(quoteTTL), fetch: c.fetchQuote, payload: func() any { return c.quote }})
+	}
+	if cfg.Layout.ShowNetworkStatus || cfg.Layout.ShowWiFiSignal {
+		c.sources = append(c.sources, cacheSource{name: sourceNetwork, ttl: networkTTL, fetch: c.fetchNetwork, payload: c.networkPayload})
+	}
+	if len(cfg.ExchangeRates) > 0 {
+		c.sources = append(c.sources, cacheSource{name: sourceExchangeRates, ttl: exchangeRatesTTL, fetch: c.fetchExchangeRates, payload: func() any { return c.exchangeRates }})
+	}
+	if pollen, _ := cfg.PollenSource(c.client); pollen != nil {
+		c.sources = append(c.sources, cacheSource{name: sourcePollen, ttl: pollenTTL, fetch: c.pollenFetcher(pollen), payload: func() any { return c.pollen }})
+	}
+	if cfg.Layout.ShowAQI {
+		c.sources = append(c.sources, cacheSource{name: sourceAirQuality, ttl: airQualityTTL, fetch: c.fetchAirQuality, payload: func() any { return c.airQuality }})
+	}
+	if cfg.Weather.HistoricalDays > 0 {
+		c.sources = append(c.sources, cacheSource{name: sourceWeatherHistory, ttl: weatherHistoryTTL, fetch: c.fetchWeatherHistory, payload: func() any { return c.history.Daily }})
+	}
+	if fitness, _ := cfg.FitnessSource(c.client); fitness != nil {
+		c.sources = append(c.sources, cacheSource{name: sourceFitness, ttl: fitnessTTL, fetch: c.fitnessFetcher(fitness), payload: func() any { return c.steps }})
+	}
+	if sleep := cfg.SleepSource(); sleep != nil {
+		c.sources = append(c.sources, cacheSource{name: sourceSleep, ttl: sleepTTL, fetch: c.sleepFetcher(sleep), payload: func() any { return c.sleep }})
+	}
+	if todos, _ := cfg.TodoSource(c.client, location); todos != nil {
+		c.sources = append(c.sources, cacheSource{name: sourceTodos, ttl: todosTTL, fetch: c.todosFetcher(todos), payload: func() any { return c.todos }})
+	}
+	if transit := cfg.TransitSource(c.client, location); transit != nil {
+		c.sources = append(c.sources, cacheSource{name: sourceTransit, ttl: transitTTL, fetch: c.transitFetcher(transit), payload: func() any { return c.departures }})
+	}
+	if word, _ := cfg.WordSource(c.client, location); word != nil {
+		c.sources = append(c.sources, cacheSource{name: sourceWord, ttl: wordTTL, fetch: c.wordFetcher(word), payload: func() any { return c.word }})
+	}
+
+	seen := make(map[string]bool)
+	for i, cal := range cfg.GetCalendars(c.client) {
+		name := sourceCalendarPrefix + cal.CalendarName()
+		if seen[name] {
+			name = fmt.Sprintf("%s#%d", name, i+1)
+		}
+		seen[name] = true
+
+		ttl := cfg.Calendars[i].Refresh.Or(calendarTTL)
+		c.sources = append(c.sources, cacheSource{name: name, ttl: ttl, fetch: c.calendarFetcher(name, cal), payload: c.calendarPayload(name)})
+	}
+
+	return c
+}
+
+// Start launches the background goroutines. They stop when ctx is cancelled.
+func (c *DataCache) Start(ctx context.Context) {
+	c.ready.Add(len(c.sources))
+	for _, src := range c.sources {
+		go c.refresh(ctx, src)
+	}
+}
+
+// WaitReady blocks until every source was fetched once, successfully or not.
+func (c *DataCache) WaitReady(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.ready.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
+// WaitFetched blocks until every source was fetched at or after since,
+// successfully or not.
+func (c *DataCache) WaitFetched(ctx context.Context, since time.Time) error {
+	for {
+		c.mu.RLock()
+		fetched := true
+		for _, src := range c.sources {
+			if c.fetched[src.name].Before(since) {
+				fetched = false
+				break
+			}
+		}
+		changed := c.changed
+		c.mu.RUnlock()
+
+		if fetched {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// LastError returns the error of the most recent fetch of the source,
+// or nil if it succeeded.
+func (c *DataCache) LastError(source string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.errs[source]
+}
+
+// LastUpdate returns the time of the last successful fetch of the source.
+func (c *DataCache) LastUpdate(source string) time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.updated[source]
+}
+
+// Version returns a number that changes whenever a fetch changed the data
+// of a source, so the caller can tell if the data changed since an earlier
+// snapshot.
+func (c *DataCache) Version() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.version
+}
+
+// Snapshot returns the most recently fetched data. Calendars and the quote
+// are optional, the weather is required.
+func (c *DataCache) Snapshot() (*dashboardData, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.dailyWeather == nil || c.hourlyWeather == nil {
+		if err := c.errs[sourceWeather]; err != nil {
+			return nil, fmt.Errorf("%w: %w", errNoWeather, err)
+		}
+		return nil, errNoWeather
+	}
+
+	// Drop the events that started since the calendar was fetched.
+	now := c.now()
+	var events []CalendarEvent
+	for _, calendarEvents := range c.events {
+		for _, event := range calendarEvents {
+			if !event.Start.Before(now) {
+				events = append(events, event)
+			}
+		}
+	}
+	sortEvents(events)
+
+	data := &dashboardData{
+		Appointments:     appointmentsFrom(events, c.cfg.PreferEventCategoryAsTag, c.cfg.AppointmentCount()),
+		AppointmentTotal: len(events),
+		DailyWeather:     c.dailyWeather,
+		HourlyWeather:    c.hourlyWeather,
+		Quote:            c.quote,
+		Updated:          c.updated[sourceWeather],
+	}
+	if c.errs[sourceNetwork] == nil {
+		data.Network = c.network
+	}
+	data.ExchangeRates = c.exchangeRates
+	data.Pollen = c.pollen
+	data.AirQuality = c.airQuality
+	data.HistoricalWeather = c.history
+	for i, place := range c.cfg.WeatherLocations()[1:] {
+		if c.locations[i] != nil {
+			data.Locations = append(data.Locations, locationWeather{Name: place.Name, Daily: c.locations[i]})
+		}
+	}
+	// Yesterday's steps must not be shown after midnight.
+	if c.steps != nil && daysUntil(now, c.updated[sourceFitness].In(c.location)) == 0 {
+		data.Steps = c.steps
+	}
+	data.Sleep = c.sleep
+	data.Todos = c.todos
+	data.Word = c.word
+	data.Departures = c.departures
+	if c.quote.Text == "" {
+		data.QuoteErr = c.errs[sourceQuote]
+	}
+
+	return data, nil
+}
+
+// refresh fetches the source until ctx is cancelled.
+func (c *DataCache) refresh(ctx context.Context, src cacheSource) {
+	first := true
+	for {
+		start := c.now()
+
+		fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+		err := src.fetch(fetchCtx)
+		cancel()
+
+		metrics.observeFetch(src.name, c.now().Sub(start), err)
+
+		c.mu.Lock()
+		c.errs[src.name] = err
+		if err == nil {
+			c.updated[src.name] = c.now()
+			if c.payloadChanged(src) {
+				c.version++
+			}
+		}
+		c.fetched[src.name] = c.now()
+		close(c.changed)
+		c.changed = make(chan struct{})
+		c.mu.Unlock()
+
+		wait := src.ttl
+		if err != nil {
+			wait = min(wait, retryInterval)
+			if ctx.Err() == nil {
+				slog.Warn("failed to refresh data source", "source", src.name, "error", err)
+			}
+		} else {
+			slog.Info("refreshed data source", "source", src.name, "duration", c.now().Sub(start))
+		}
+
+		if first {
+			c.ready.Done()
+			first = false
+		}
+
+		// A fetch due in the quiet hours waits for their end.
+		now := c.now().In(c.location)
+		next := c.cfg.Schedule.QuietHours.Postpone(now, now.Add(wait))
+		if next.Sub(now) > wait {
+			slog.Debug("quiet hours, pausing the data source", "source", src.name, "until", next)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.after(next.Sub(now)):
+		}
+	}
+}
+
+// payloadChanged reports whether the payload of the source differs from the
+// one of its last successful fetch. It must be called with the cache locked.
+func (c *DataCache) payloadChanged(src cacheSource) bool {
+	if src.payload == nil {
+		return true
+	}
+
+	encoded, err := json.Marshal(src.payload())
+	if err != nil {
+		slog.Debug("failed to encode the payload of the data source", "source", src.name, "error", err)
+		return true
+	}
+	hash := fnv.New64a()
+	hash.Write(encoded)
+	sum := hash.Sum64()
+
+	previous, ok := c.hashes[src.name]
+	c.hashes[src.name] = sum
+	return !ok || previous != sum
+}
+
+// weatherPayload returns the forecasts without the generation time of the
+// responses, which changes with every fetch.
+func (c *DataCache) weatherPayload() any {
+	return []any{c.dailyWeather.Daily, c.hourlyWeather.Hourly}
+}
+
+// networkPayload returns what the dashboard shows of the network status. The
+// signal level changes by a few dBm all the time, only its bars are shown.
+func (c *DataCache) networkPayload() any {
+	return []any{c.network.Connected, c.network.SSID, c.network.Bars()}
+}
+
+// calendarPayload returns the payload function of a calendar source. It is
+// made of the appointments, as the raw events contain properties like the
+// DTSTAMP that some servers set to the time of the request.
+func (c *DataCache) calendarPayload(name string) func() any {
+	return func() any {
+		return appointmentsFrom(c.events[name], c.cfg.PreferEventCategoryAsTag, 0)
+	}
+}
+
+// fetchWeather fetches the daily and hourly forecast.
+func (c *DataCache) fetchWeather(ctx context.Context) error {
+	primary := c.cfg.WeatherLocations()[0]
+	dailyWeather, err := fetchDailyWeather(ctx, primary, c.cfg.Timezone, c.client)
+	if err != nil {
+		return err
+	}
+
+	hourlyWeather, err := fetchHourlyWeather(ctx, primary, c.cfg.Timezone, c.client)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.dailyWeather = dailyWeather
+	c.hourlyWeather = hourlyWeather
+	c.mu.Unlock()
+
+	return nil
+}
+
+// locationFetcher returns the fetch function of the i-th other weather location.
+func (c *DataCache) locationFetcher(i int, place weatherLocation) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		daily, err := fetchDailyWeather(ctx, place, c.cfg.Timezone, c.client)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		c.locations[i] = daily
+		c.mu.Unlock()
+
+		return nil
+	}
+}
+
+// fetchQuote fetches a new quote.
+func (c *DataCache) fetchQuote(ctx context.Context) error {
+	q, err := fetchNewQuote(ctx, c.cfg, c.client)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.quote = q
+	c.mu.Unlock()
+
+	return nil
+}
+
+// fetchNetwork reads the status of the WiFi.
+func (c *DataCache) fetchNetwork(ctx context.Context) error {
+	status, err := FetchNetworkStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.network = &status
+	c.mu.Unlock()
+
+	return nil
+}
+
+// fetchExchangeRates fetches the configured exchange rates.
+func (c *DataCache) fetchExchangeRates(ctx context.Context) error {
+	rates, err := fetchConfiguredExchangeRates(ctx, c.client, c.cfg.ExchangeRates)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.exchangeRates = rates
+	c.mu.Unlock()
+
+	return nil
+}
+
+// pollenFetcher returns the fetch function of the pollen source.
+func (c *DataCache) pollenFetcher(pollen PollenSource) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		primary := c.cfg.WeatherLocations()[0]
+		entries, err := pollen.FetchPollenCount(ctx, primary.Latitude, primary.Longitude)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		c.pollen = entries
+		c.mu.Unlock()
+
+		return nil
+	}
+}
+
+// fetchAirQuality fetches the air quality at the first weather location.
+func (c *DataCache) fetchAirQuality(ctx context.Context) error {
+	primary := c.cfg.WeatherLocations()[0]
+	quality, err := FetchAirQuality(ctx, c.client, primary.Latitude, primary.Longitude)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.airQuality = &quality
+	c.mu.Unlock()
+
+	return nil
+}
+
+// fetchWeatherHistory fetches the weather of the past days.
+func (c *DataCache) fetchWeatherHistory(ctx context.Context) error {
+	history, err := FetchHistoricalWeather(ctx, c.cfg, c.client)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.history = history
+	c.mu.Unlock()
+
+	return nil
+}
+
+// fitnessFetcher returns the fetch function of the step count source.
+func (c *DataCache) fitnessFetcher(fitness FitnessSource) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		steps, goal, err := fitness.FetchStepCount(ctx, c.now().In(c.location))
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		c.steps = &stepCount{Steps: steps, Goal: goal}
+		c.mu.Unlock()
+
+		return nil
+	}
+}
+
+// sleepFetcher returns the fetch function of the sleep source.
+func (c *DataCache) sleepFetcher(sleep *FitSleepSource) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		summary, err := sleep.FetchSleep(ctx)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		c.sleep = &summary
+		c.mu.Unlock()
+
+		return nil
+	}
+}
+
+// todosFetcher returns the fetch function of the todo source.
+func (c *DataCache) todosFetcher(todos TodoSource) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		items, err := todos.FetchTodos(ctx)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		c.todos = items
+		c.mu.Unlock()
+
+		return nil
+	}
+}
+
+// transitFetcher returns the fetch function of the GTFS source. It fetches
+// enough departures to fill the widget until the next fetch.
+func (c *DataCache) transitFetcher(transit *GTFSStaticSource) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		departures, err := transit.FetchDepartures(ctx, c.now(), c.cfg.MaxDepartures()+transitSpareDepartures)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		c.departures = departures
+		c.mu.Unlock()
+
+		return nil
+	}
+}
+
+// wordFetcher returns the fetch function of the word of the day source.
+func (c *DataCache) wordFetcher(word WordSource) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		entry, err := word.FetchWord(ctx)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		c.word = &entry
+		c.mu.Unlock()
+
+		return nil
+	}
+}
+
+// calendarFetcher returns the fetch function of a calendar source.
+func (c *DataCache) calendarFetcher(name string, cal EventSource) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := cal.Refresh(ctx); err != nil {
+			return err
+		}
+
+		events, err := cal.FutureEvents(ctx, c.cfg.AppointmentsUntil(c.now().In(c.location)), c.location)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		c.events[name] = events
+		c.mu.Unlock()
+
+		return nil
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 3, 15, 8, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock and fires the timers that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	c.waiters = slices.DeleteFunc(c.waiters, func(w fakeTimer) bool {
+		if w.at.After(c.now) {
+			return false
+		}
+		w.ch <- c.now
+		return true
+	})
+}
+
+// WaitTimers blocks until n timers are pending, i.e. every source of the
+// cache waits for its next fetch.
+func (c *fakeClock) WaitTimers(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		pending := len(c.waiters)
+		c.mu.Unlock()
+
+		if pending == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d pending timers, want %d", pending, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newTestCache returns a cache of the sources that runs on the clock.
+func newTestCache(clock *fakeClock, sources ...cacheSource) *DataCache {
+	return &DataCache{
+		location: time.UTC,
+		sources:  sources,
+		now:      clock.Now,
+		after:    clock.After,
+		events:   make(map[string][]CalendarEvent),
+		errs:     make(map[string]error),
+		updated:  make(map[string]time.Time),
+		fetched:  make(map[string]time.Time),
+		changed:  make(chan struct{}),
+		hashes:   make(map[string]uint64),
+	}
+}
+
+// countingSource returns a source that sends its name to fetches on every
+// fetch and returns the payload and error of result.
+func countingSource(name string, ttl time.Duration, fetches chan<- string, result func() (any, error)) cacheSource {
+	var payload any
+	return cacheSource{
+		name: name,
+		ttl:  ttl,
+		fetch: func(ctx context.Context) error {
+			fetches <- name
+			p, err := result()
+			if err == nil {
+				payload = p
+			}
+			return err
+		},
+		payload: func() any { return payload },
+	}
+}
+
+// fetchedSources returns the sorted names sent to fetches so far.
+func fetchedSources(fetches chan string) []string {
+	var names []string
+	for len(fetches) > 0 {
+		names = append(names, <-fetches)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestDataCacheRefreshesSourcesByTTL(t *testing.T) {
+	clock := newFakeClock()
+	fetches := make(chan string, 16)
+	constant := func() (any, error) { return "data", nil }
+	failing := func() (any, error) { return nil, errors.New("unavailable") }
+	cache := newTestCache(clock,
+		countingSource("fast", 5*time.Minute, fetches, constant),
+		countingSource("slow", 30*time.Minute, fetches, constant),
+		countingSource("failing", 30*time.Minute, fetches, failing),
+	)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	cache.Start(ctx)
+	if err := cache.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The failing source is retried every retryInterval rather than its TTL.
+	for elapsed := time.Duration(0); elapsed <= 30*time.Minute; elapsed += retryInterval {
+		if elapsed > 0 {
+			clock.Advance(retryInterval)
+		}
+		clock.WaitTimers(t, 3)
+
+		want := []string{"failing"}
+		if elapsed%(5*time.Minute) == 0 {
+			want = append(want, "fast")
+		}
+		if elapsed%(30*time.Minute) == 0 {
+			want = append(want, "slow")
+		}
+		if got := fetchedSources(fetches); !slices.Equal(got, want) {
+			t.Errorf("after %v: got fetches %v, want %v", elapsed, got, want)
+		}
+	}
+}
+
+func TestDataCacheVersion(t *testing.T) {
+	clock := newFakeClock()
+	fetches := make(chan string, 16)
+	var counter int
+	cache := newTestCache(clock,
+		countingSource("constant", time.Minute, fetches, func() (any, error) {
+			return []string{"a", "b"}, nil
+		}),
+		countingSource("changing", 2*time.Minute, fetches, func() (any, error) {
+			counter++
+			return counter, nil
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	cache.Start(ctx)
+	if err := cache.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clock.WaitTimers(t, 2)
+	fetchedSources(fetches)
+
+	// The first fetch of both sources changed the data.
+	if got := cache.Version(); got != 2 {
+		t.Fatalf("got version %d after the first fetches, want 2", got)
+	}
+
+	// Only the constant source is fetched again, its payload is the same.
+	clock.Advance(time.Minute)
+	clock.WaitTimers(t, 2)
+	if got := fetchedSources(fetches); !slices.Equal(got, []string{"constant"}) {
+		t.Fatalf("got fetches %v, want [constant]", got)
+	}
+	if got := cache.Version(); got != 2 {
+		t.Errorf("got version %d after an unchanged fetch, want 2", got)
+	}
+
+	// Both are fetched again, the changing source has a new payload.
+	clock.Advance(time.Minute)
+	clock.WaitTimers(t, 2)
+	if got := cache.Version(); got != 3 {
+		t.Errorf("got version %d after a changed fetch, want 3", got)
+	}
+}
@@ -1,50 +1,61 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image/color"
+	"log/slog"
+	"net/http"
 	"slices"
 	"time"
 
 	"github.com/arran4/golang-ical"
 )
 
-type Calendars []*Calendar
+// EventSource provides the events of a calendar, e.g., an iCal feed
+// (Calendar) or a local JSON file (JSONCalendar).
+type EventSource interface {
+	// CalendarName returns the name of the calendar.
+	CalendarName() string
+	// Refresh fetches the events again, even if they were fetched before.
+	Refresh(ctx context.Context) error
+	// FutureEvents returns the events between now and until with their
+	// start times converted to location.
+	FutureEvents(ctx context.Context, until time.Time, location *time.Location) ([]CalendarEvent, error)
+}
+
+type Calendars []EventSource
 
 type CalendarEvent struct {
 	*ics.VEvent
+	// Start is the start time of the event in the configured location.
+	Start time.Time
 	Tag   string
 	Color color.Color
 }
 
-func (c Calendars) MergedEvents(until time.Time) ([]CalendarEvent, error) {
+func (c Calendars) MergedEvents(ctx context.Context, until time.Time, location *time.Location) ([]CalendarEvent, error) {
 	var mergedEvents []CalendarEvent
 	for _, calendar := range c {
-		events, err := calendar.FutureEvents(until)
+		events, err := calendar.FutureEvents(ctx, until, location)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch future events: %w", err)
 		}
 		mergedEvents = append(mergedEvents, events...)
 	}
 
-	// Sort the events by start time
-	slices.SortFunc(mergedEvents, func(a, b CalendarEvent) int {
-		startA, errA := a.GetStartAt()
-		startB, errB := b.GetStartAt()
-		if errA != nil || errB != nil {
-			return 0
-		}
-		if startA.Before(startB) {
-			return -1
-		} else if startA.After(startB) {
-			return 1
-		}
-		return 0
-	})
+	sortEvents(mergedEvents)
 
 	return mergedEvents, nil
 }
 
+// sortEvents sorts the events by start time.
+func sortEvents(events []CalendarEvent) {
+	slices.SortFunc(events, func(a, b CalendarEvent) int {
+		return a.Start.Compare(b.Start)
+	})
+}
+
 type Calendar struct {
 	URL   string
 	Name  string
@@ -52,22 +63,29 @@ type Calendar struct {
 
 	Events  []*ics.VEvent
 	fetched bool
+	client  *http.Client
 }
 
-func NewCalendar(name string, col color.Color, url string) *Calendar {
+func NewCalendar(name string, col color.Color, url string, client *http.Client) *Calendar {
 	return &Calendar{
-		Name:  name,
-		URL:   url,
-		Color: col,
+		Name:   name,
+		URL:    url,
+		Color:  col,
+		client: client,
 	}
 }
 
-func (c *Calendar) Fetch() error {
+// CalendarName returns the name of the calendar.
+func (c *Calendar) CalendarName() string {
+	return c.Name
+}
+
+func (c *Calendar) Fetch(ctx context.Context) error {
 	if c.fetched {
 		return nil
 	}
 
-	cal, err := ics.ParseCalendarFromUrl(c.URL)
+	cal, err := ics.ParseCalendarFromUrl(c.URL, ctx, c.client)
 	if err != nil {
 		return fmt.Errorf("failed to parse calendar: %w", err)
 	}
@@ -78,33 +96,71 @@ func (c *Calendar) Fetch() error {
 	return nil
 }
 
+// Refresh fetches the calendar again, even if it was fetched before.
+func (c *Calendar) Refresh(ctx context.Context) error {
+	c.fetched = false
+	return c.Fetch(ctx)
+}
+
 // FutureEvents returns all events that are in the future.
-func (c *Calendar) FutureEvents(until time.Time) ([]CalendarEvent, error) {
-	err := c.Fetch()
+// Recurring events are expanded to their individual occurrences.
+// The start times are converted to location.
+func (c *Calendar) FutureEvents(ctx context.Context, until time.Time, location *time.Location) ([]CalendarEvent, error) {
+	err := c.Fetch(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch future events: %w", err)
 	}
 
+	// Occurrences of recurring events that were moved or changed are
+	// stored as separate events and must be skipped in the series.
+	overridden := make(map[string][]time.Time)
+	for _, event := range c.Events {
+		if id, ok := recurrenceID(event, location); ok {
+			overridden[event.Id()] = append(overridden[event.Id()], id)
+		}
+	}
+
 	var futureEvents []CalendarEvent
 
-	var starts time.Time
+	now := time.Now()
 	for _, event := range c.Events {
-		starts, err = event.GetStartAt()
+		starts, err := eventStart(event, location)
 		if err != nil {
 			// Skip invalid events.
 			continue
 		}
 
-		if starts.Before(time.Now()) || starts.After(until) {
+		var excluded []time.Time
+		if _, ok := recurrenceID(event, location); !ok {
+			excluded = overridden[event.Id()]
+		}
+
+		occurrences, err := eventOccurrences(event, starts, now, until, excluded, location)
+		if err != nil {
+			slog.Warn("skipping event with invalid recurrence", "calendar", c.Name, "error", err)
 			continue
 		}
 
-		futureEvents = append(futureEvents, CalendarEvent{
-			VEvent: event,
-			Tag:    c.Name,
-			Color:  c.Color,
-		})
+		for _, occurrence := range occurrences {
+			futureEvents = append(futureEvents, CalendarEvent{
+				VEvent: event,
+				Start:  occurrence.In(location),
+				Tag:    c.Name,
+				Color:  c.Color,
+			})
+		}
 	}
 
 	return futureEvents, nil
 }
+
+// eventStart returns the start time of the event in the time zone it was
+// created in, see parseICalTime.
+func eventStart(event *ics.VEvent, location *time.Location) (time.Time, error) {
+	prop := event.GetProperty(ics.ComponentPropertyDtStart)
+	if prop == nil {
+		return time.Time{}, fmt.Errorf("event has no start time")
+	}
+
+	return parseICalTime(prop.Value, prop.ICalParameters, location)
+}
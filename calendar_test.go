@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// NewCalendarTestServer returns a server that serves ics as a calendar
+// feed. It is closed when the test ends.
+func NewCalendarTestServer(t *testing.T, ics string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Write([]byte(ics))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// icsCalendar wraps the events in a VCALENDAR with CRLF line endings.
+func icsCalendar(events ...string) string {
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//epd//test//EN"}
+	for _, event := range events {
+		lines = append(lines, "BEGIN:VEVENT")
+		lines = append(lines, strings.Split(strings.TrimSpace(event), "\n")...)
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR", "")
+	return strings.Join(lines, "\r\n")
+}
+
+// testDay returns midnight of the day offset days after today in location.
+func testDay(location *time.Location, offset int) time.Time {
+	now := time.Now().In(location)
+	return time.Date(now.Year(), now.Month(), now.Day()+offset, 0, 0, 0, 0, location)
+}
+
+// icsLocal formats t as a floating iCal date-time.
+func icsLocal(t time.Time) string {
+	return t.Format("20060102T150405")
+}
+
+// eventStarts returns the start times of the events formatted in their location.
+func eventStarts(events []CalendarEvent) []string {
+	var starts []string
+	for _, event := range events {
+		starts = append(starts, event.Start.Format("2006-01-02 15:04"))
+	}
+	return starts
+}
+
+func TestFutureEvents(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(offset, hour int) time.Time {
+		return testDay(berlin, offset).Add(time.Duration(hour) * time.Hour)
+	}
+
+	ics := icsCalendar(
+		"UID:past\nSUMMARY:Past\nDTSTART;TZID=Europe/Berlin:"+icsLocal(at(-2, 10)),
+		"UID:soon\nSUMMARY:Soon\nDTSTART;TZID=Europe/Berlin:"+icsLocal(at(1, 9)),
+		"UID:utc\nSUMMARY:UTC\nDTSTART:"+at(2, 12).UTC().Format("20060102T150405Z"),
+		"UID:later\nSUMMARY:Later\nDTSTART;TZID=Europe/Berlin:"+icsLocal(at(30, 10)),
+		"UID:allday\nSUMMARY:All day\nDTSTART;VALUE=DATE:"+at(3, 0).Format("20060102"),
+	)
+	srv := NewCalendarTestServer(t, ics)
+
+	cal := NewCalendar("Test", ColorRed, srv.URL, srv.Client())
+	events, err := cal.FutureEvents(context.Background(), at(14, 0), berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortEvents(events)
+
+	want := []string{
+		at(1, 9).Format("2006-01-02 15:04"),
+		at(2, 12).Format("2006-01-02 15:04"),
+		at(3, 0).Format("2006-01-02 15:04"),
+	}
+	if got := eventStarts(events); !slices.Equal(got, want) {
+		t.Errorf("starts = %v, want %v", got, want)
+	}
+	for _, event := range events {
+		if event.Start.Location() != berlin {
+			t.Errorf("start %v is not in Europe/Berlin", event.Start)
+		}
+		if event.Tag != "Test" || event.Color != ColorRed {
+			t.Errorf("event has tag %q and color %v, want the calendar's", event.Tag, event.Color)
+		}
+	}
+}
+
+func TestFutureEventsRecurring(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(offset, hour int) time.Time {
+		return testDay(berlin, offset).Add(time.Duration(hour) * time.Hour)
+	}
+	series := "UID:series\nSUMMARY:Daily\nDTSTART;TZID=Europe/Berlin:" + icsLocal(at(1, 10)) + "\nRRULE:FREQ=DAILY;COUNT=5\n"
+
+	tests := []struct {
+		name   string
+		events []string
+		want   []time.Time
+	}{
+		{
+			name:   "no exceptions",
+			events: []string{series},
+			want:   []time.Time{at(1, 10), at(2, 10), at(3, 10), at(4, 10), at(5, 10)},
+		},
+		{
+			name:   "single EXDATE",
+			events: []string{series + "EXDATE;TZID=Europe/Berlin:" + icsLocal(at(3, 10))},
+			want:   []time.Time{at(1, 10), at(2, 10), at(4, 10), at(5, 10)},
+		},
+		{
+			name:   "multiple EXDATE on one line",
+			events: []string{series + "EXDATE;TZID=Europe/Berlin:" + icsLocal(at(2, 10)) + "," + icsLocal(at(4, 10))},
+			want:   []time.Time{at(1, 10), at(3, 10), at(5, 10)},
+		},
+		{
+			name:   "EXDATE in UTC",
+			events: []string{series + "EXDATE:" + at(2, 10).UTC().Format("20060102T150405Z")},
+			want:   []time.Time{at(1, 10), at(3, 10), at(4, 10), at(5, 10)},
+		},
+		{
+			name:   "EXDATE of the first occurrence",
+			events: []string{series + "EXDATE;TZID=Europe/Berlin:" + icsLocal(at(1, 10))},
+			want:   []time.Time{at(2, 10), at(3, 10), at(4, 10), at(5, 10)},
+		},
+		{
+			name: "moved occurrence",
+			events: []string{
+				series,
+				"UID:series\nSUMMARY:Moved\nRECURRENCE-ID;TZID=Europe/Berlin:" + icsLocal(at(4, 10)) + "\nDTSTART;TZID=Europe/Berlin:" + icsLocal(at(4, 15)),
+			},
+			want: []time.Time{at(1, 10), at(2, 10), at(3, 10), at(4, 15), at(5, 10)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewCalendarTestServer(t, icsCalendar(tt.events...))
+
+			cal := NewCalendar("Test", ColorRed, srv.URL, srv.Client())
+			events, err := cal.FutureEvents(context.Background(), at(14, 0), berlin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sortEvents(events)
+
+			var want []string
+			for _, start := range tt.want {
+				want = append(want, start.Format("2006-01-02 15:04"))
+			}
+			if got := eventStarts(events); !slices.Equal(got, want) {
+				t.Errorf("starts = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMergedEvents(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(offset, hour int) time.Time {
+		return testDay(berlin, offset).Add(time.Duration(hour) * time.Hour)
+	}
+
+	work := NewCalendarTestServer(t, icsCalendar(
+		"UID:w1\nSUMMARY:Meeting\nDTSTART;TZID=Europe/Berlin:"+icsLocal(at(2, 9)),
+		"UID:w2\nSUMMARY:Review\nCATEGORIES:Projekt,Intern\nDTSTART;TZID=Europe/Berlin:"+icsLocal(at(4, 14)),
+	))
+	home := NewCalendarTestServer(t, icsCalendar(
+		"UID:h1\nSUMMARY:Zahnarzt\nDTSTART;TZID=Europe/Berlin:"+icsLocal(at(1, 8)),
+		"UID:h2\nSUMMARY:Kino\nDTSTART;TZID=Europe/Berlin:"+icsLocal(at(3, 20)),
+	))
+	cals := Calendars{
+		NewCalendar("W", ColorBlue, work.URL, work.Client()),
+		NewCalendar("H", ColorGreen, home.URL, home.Client()),
+	}
+
+	events, err := cals.MergedEvents(context.Background(), at(14, 0), berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var titles []string
+	for _, event := range events {
+		titles = append(titles, event.Tag+" "+event.GetProperty("SUMMARY").Value)
+	}
+	want := []string{"H Zahnarzt", "W Meeting", "H Kino", "W Review"}
+	if !slices.Equal(titles, want) {
+		t.Errorf("events = %v, want %v", titles, want)
+	}
+
+	// The limit cuts off the list, the category replaces the calendar's name.
+	appointments := appointmentsFrom(events, true, 4)
+	if got := appointments[3].Tag; got != "Projekt" {
+		t.Errorf("tag = %q, want the category", got)
+	}
+	if got := len(appointmentsFrom(events, false, 2)); got != 2 {
+		t.Errorf("got %d appointments, want the limit of 2", got)
+	}
+}
+
+func TestMergedEventsError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	cals := Calendars{NewCalendar("Missing", ColorRed, srv.URL, srv.Client())}
+	if _, err := cals.MergedEvents(context.Background(), time.Now().Add(appointmentHorizon), time.UTC); err == nil {
+		t.Error("MergedEvents of a missing calendar succeeded")
+	}
+}
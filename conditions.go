@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+// weatherDescriptionsPath is the file with descriptions of the weather
+// codes. It is read from the working directory, or embedded next to the
+// config if it is missing there.
+const weatherDescriptionsPath = "config/weather_descriptions.toml"
+
+// useWeatherDescriptions reads the descriptions of weatherDescriptionsPath
+// into weatherDescriptions. They replace the built-in description of their
+// code in every locale, the other codes keep theirs. The file has a single table, e.g.
+//
+//	[descriptions]
+//	0 = "Clear sky"
+//	3 = "Overcast"
+func useWeatherDescriptions() error {
+	data, err := os.ReadFile(weatherDescriptionsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = configFS.ReadFile(weatherDescriptionsPath)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read weather descriptions: %w", err)
+	}
+
+	var file struct {
+		Descriptions map[string]string `toml:"descriptions"`
+	}
+	if _, err = toml.Decode(string(data), &file); err != nil {
+		return fmt.Errorf("failed to parse weather descriptions: %w", err)
+	}
+
+	for key, description := range file.Descriptions {
+		code, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("invalid weather code in weather descriptions: %s", key)
+		}
+		weatherDescriptions[code] = description
+	}
+
+	return nil
+}
@@ -1,36 +1,823 @@
 package main
 
 import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"image/color"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"periph.io/x/conn/v3/physic"
 )
 
 type config struct {
-	Timezone string `toml:"timezone"`
-	Weather  struct {
+	Timezone   string     `toml:"timezone"`
+	Locale     Locale     `toml:"locale"`
+	TimeFormat TimeFormat `toml:"time_format"`
+	DateFormat string     `toml:"date_format"`
+	LockFile   string     `toml:"lock_file"`
+
+	// IconsDir contains icons that replace the embedded ones. Its layout
+	// matches the embedded icons directory (e.g., "weather/sun.png").
+	IconsDir string `toml:"icons_dir"`
+
+	// ShowVersion renders the version label also for development builds.
+	ShowVersion bool `toml:"show_version"`
+
+	// ShowRefreshTime renders the time of the last update in the footer.
+	ShowRefreshTime bool `toml:"show_refresh_time"`
+
+	// RefreshInterval is the time between two display updates in daemon mode.
+	RefreshInterval tomlDuration `toml:"refresh_interval"`
+	// RefreshCron schedules the display updates in daemon mode with a cron
+	// expression instead of RefreshInterval.
+	RefreshCron cronSchedule `toml:"refresh_cron"`
+
+	// WeekdayAbbreviations overrides the short weekday names of the locale.
+	WeekdayAbbreviations []string `toml:"weekday_abbreviations"`
+	Weather              struct {
 		Latitude  float64 `toml:"latitude"`
 		Longitude float64 `toml:"longitude"`
+		// Locations replace Latitude and Longitude with several places,
+		// see WeatherLocations.
+		Locations []weatherLocation `toml:"locations"`
+		RainChart bool              `toml:"rain_chart"`
+		// HistoricalDays replaces the forecast with the temperature ranges
+		// of that many past days, 0 to show the forecast.
+		HistoricalDays int `toml:"historical_days"`
+		// Refresh is the time between two weather fetches in daemon mode.
+		Refresh tomlDuration `toml:"refresh"`
 	} `toml:"weather"`
 
 	Calendars []calendarConfig `toml:"calendars"`
+
+	Quote struct {
+		// Provider is "zitat-service" (default), "quotable", "file" with
+		// the quotes of Path, or "exec" with the output of Command.
+		Provider string   `toml:"provider"`
+		Path     string   `toml:"path"`
+		Command  []string `toml:"command"`
+
+		FetchTimeoutSeconds int `toml:"fetch_timeout_seconds"`
+		MaxChars            int `toml:"max_chars"`
+		// PinnedText is shown instead of a fetched quote if set, with
+		// PinnedAuthor below it unless that is empty.
+		PinnedText   string `toml:"pinned_text"`
+		PinnedAuthor string `toml:"pinned_author"`
+		// Refresh is the time between two quote fetches in daemon mode.
+		Refresh tomlDuration `toml:"refresh"`
+		// History is the number of recent quotes that are not shown again,
+		// kept in the state file. 0 allows repeats.
+		History int `toml:"history"`
+	} `toml:"quote"`
+
+	Appointments struct {
+		View AppointmentView `toml:"view"`
+		// Empty shows a placeholder or leaves out the section without appointments.
+		Empty         EmptyAppointments `toml:"empty"`
+		TitleMaxChars int               `toml:"title_max_chars"`
+		// WrapTitles wraps long titles onto a second line instead of
+		// cutting them off after TitleMaxChars.
+		WrapTitles bool `toml:"wrap_titles"`
+		// ShowCountdown shows the time until the next appointment, and
+		// until all appointments of the next 4 hours.
+		ShowCountdown bool `toml:"show_countdown"`
+	} `toml:"appointments"`
+
+	Layout struct {
+		// ShowMiniMonth draws a calendar of the current month below the
+		// appointment list. It is not shown with the week view.
+		ShowMiniMonth bool `toml:"show_mini_month"`
+		// ShowNetworkStatus shows the WiFi's SSID and signal in the top right corner.
+		ShowNetworkStatus bool `toml:"show_network_status"`
+		// ShowWiFiSignal shows only the WiFi's signal in the top right corner.
+		ShowWiFiSignal bool `toml:"show_wifi_signal"`
+		// ShowYearProgress shows the elapsed part of the year below the
+		// appointments, ShowMonthProgress adds the month.
+		ShowYearProgress  bool `toml:"show_year_progress"`
+		ShowMonthProgress bool `toml:"show_month_progress"`
+		// ShowDayProgress shows the elapsed days of the year as a bar
+		// below the date. It is on unless it is set to false.
+		ShowDayProgress bool `toml:"show_day_progress"`
+		// ShowPressure shows the surface pressure and its trend below the
+		// sunrise and sunset.
+		ShowPressure bool `toml:"show_pressure"`
+		// ShowAQI shows the European Air Quality Index below the sunrise
+		// and sunset.
+		ShowAQI bool `toml:"show_aqi"`
+		// Separator is the style of the lines between the sections.
+		Separator SeparatorStyle `toml:"separator"`
+	} `toml:"layout"`
+
+	// PreferEventCategoryAsTag shows the event's CATEGORIES instead of the calendar name.
+	PreferEventCategoryAsTag bool `toml:"prefer_event_category_as_tag"`
+
+	Log struct {
+		Level string `toml:"level"`
+	} `toml:"log"`
+
+	// Fonts replaces the embedded fonts with TrueType or OpenType files.
+	Fonts struct {
+		Regular  string `toml:"regular"`
+		Bold     string `toml:"bold"`
+		Fallback string `toml:"fallback"`
+	} `toml:"fonts"`
+
+	// Archive keeps a copy of every rendered dashboard for later review.
+	Archive struct {
+		Dir        string `toml:"dir"`
+		MaxAgeDays int    `toml:"max_age_days"`
+	} `toml:"archive"`
+
+	// ExchangeRates are shown in the top left corner.
+	ExchangeRates []exchangeRateConfig `toml:"exchange_rates"`
+
+	// Timezones are shown as clocks below the heading.
+	Timezones []timezoneConfig `toml:"timezones"`
+
+	// GarbageSchedule are the waste pickups shown on the day before and on the day.
+	GarbageSchedule GarbageConfig `toml:"garbage_schedule"`
+
+	// Medications are the doses shown below the appointments, from the
+	// start of the day until the lookahead of the [medication] table.
+	Medications []MedicationConfig `toml:"medications"`
+	Medication  struct {
+		Lookahead tomlDuration `toml:"lookahead"`
+		// TakenFile stores the doses marked with -take.
+		TakenFile string `toml:"taken_file"`
+	} `toml:"medication"`
+
+	// Fitness shows the daily step count below the appointments. The
+	// only provider is "fitbit", which needs an access token.
+	Fitness struct {
+		Provider    string `toml:"provider"`
+		AccessToken string `toml:"access_token"`
+	} `toml:"fitness"`
+
+	// Sleep shows the last night's sleep below the appointments, read from
+	// the FIT files in Dir.
+	Sleep struct {
+		Dir string `toml:"dir"`
+	} `toml:"sleep"`
+
+	// Pollen shows the pollen levels below the appointments. The only
+	// provider is "dwd", the forecast of the Deutscher Wetterdienst.
+	Pollen struct {
+		Provider string   `toml:"provider"`
+		Region   int      `toml:"region"`
+		Species  []string `toml:"species"`
+	} `toml:"pollen"`
+
+	// Todos shows the next open tasks below the appointments, either of
+	// "todoist" with an API token, or of a "todotxt" file at Path.
+	Todos struct {
+		Provider string `toml:"provider"`
+		APIToken string `toml:"api_token"`
+		Path     string `toml:"path"`
+		MaxTodos int    `toml:"max_todos"`
+	} `toml:"todos"`
+
+	// Word shows a word of the day from the Wordlist file, one per line as
+	// "word|definition". The "merriam-webster" provider looks up the words
+	// with the APIKey, the "local" provider takes the definitions of the
+	// file. ReplaceQuote shows it in the footer instead of the quote.
+	Word struct {
+		Provider     string `toml:"provider"`
+		APIKey       string `toml:"api_key"`
+		Wordlist     string `toml:"wordlist"`
+		ReplaceQuote bool   `toml:"replace_quote"`
+	} `toml:"word"`
+
+	// Transit shows the next departures from StopID in the static GTFS
+	// feed at URL. The feed is kept in CacheFile and downloaded again after
+	// Refresh.
+	Transit struct {
+		URL           string       `toml:"url"`
+		StopID        string       `toml:"stop_id"`
+		MaxDepartures int          `toml:"max_departures"`
+		CacheFile     string       `toml:"cache_file"`
+		Refresh       tomlDuration `toml:"refresh"`
+	} `toml:"transit"`
+
+	// Photo shows a photo of Dir each day at X and Y with Width and
+	// Height, inside the whole frame if they are not set.
+	Photo struct {
+		Dir    string `toml:"dir"`
+		X      int    `toml:"x"`
+		Y      int    `toml:"y"`
+		Width  int    `toml:"width"`
+		Height int    `toml:"height"`
+	} `toml:"photo"`
+
+	// Schedule pauses the updates during the quiet hours.
+	Schedule struct {
+		QuietHours quietHours `toml:"quiet_hours"`
+	} `toml:"schedule"`
+
+	// Network configures the requests of the remote sources.
+	Network struct {
+		// ProxyURL is the proxy of all requests, e.g. "http://proxy:3128".
+		// The HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+		// are used if it is not set.
+		ProxyURL string `toml:"proxy_url"`
+		// TLSSkipVerify accepts any server certificate. Prefer CACertFile.
+		TLSSkipVerify bool `toml:"tls_skip_verify"`
+		// CACertFile is a PEM bundle of additional trusted CAs, e.g. of a
+		// server with a self-signed certificate.
+		CACertFile string `toml:"ca_cert_file"`
+		// ClientCertFile and ClientKeyFile are a PEM certificate and key
+		// for servers that require client certificates.
+		ClientCertFile string `toml:"client_cert_file"`
+		ClientKeyFile  string `toml:"client_key_file"`
+		// Timeout limits a single request including reading the body.
+		Timeout tomlDuration `toml:"timeout"`
+		// UserAgent replaces the User-Agent header of all requests.
+		UserAgent string `toml:"user_agent"`
+	} `toml:"network"`
+
+	// Metrics serves Prometheus metrics at /metrics in daemon mode if Listen is set.
+	Metrics struct {
+		Listen string `toml:"listen"`
+	} `toml:"metrics"`
+
+	// Display configures the connection to the panel.
+	Display struct {
+		// SPIHz is the SPI clock in Hz. Long cables may need a lower one.
+		SPIHz int64 `toml:"spi_hz"`
+		// DeepClean runs a deep clean against ghosting in the quiet hours.
+		DeepClean deepCleanPeriod `toml:"deep_clean"`
+		// StateFile keeps the time of the last deep clean and the recent quotes.
+		StateFile string `toml:"state_file"`
+	} `toml:"display"`
+
+	// Render converts the image sent to the panel to dithered black and
+	// white if Grayscale is set.
+	Render struct {
+		Grayscale  bool       `toml:"grayscale"`
+		DitherSize ditherSize `toml:"dither_size"`
+	} `toml:"render"`
+
+	// MQTT triggers a display update in daemon mode for every message on
+	// the topic.
+	MQTT mqttConfig `toml:"mqtt"`
+
+	// Output is the file the rendered dashboard is written to.
+	Output struct {
+		Path    string `toml:"path"`
+		History int    `toml:"history"`
+	} `toml:"output"`
+
+	// meta tells which keys were set in the file.
+	meta toml.MetaData
 }
 
-func (c config) GetCalendars() Calendars {
+// parseConfig decodes a TOML config. Call Validate before using it.
+func parseConfig(data []byte) (config, error) {
+	var cfg config
+	meta, err := toml.Decode(string(data), &cfg)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.meta = meta
+
+	return cfg, nil
+}
+
+// Validate checks the config and returns all problems joined, so they can
+// be fixed in one pass.
+func (c config) Validate() error {
+	var errs []error
+
+	// The keys of an unknown table are not reported on their own.
+	unknown := make(map[string]bool)
+	for _, key := range c.meta.Undecoded() {
+		if len(key) > 1 && unknown[key[:len(key)-1].String()] {
+			continue
+		}
+		unknown[key.String()] = true
+		errs = append(errs, fmt.Errorf("unknown config key: %s", key))
+	}
+
+	if c.Timezone == "" {
+		errs = append(errs, errors.New("timezone is not set in the config"))
+	} else if _, err := time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("invalid timezone: %s", c.Timezone))
+	}
+
+	if len(c.Weather.Locations) > 0 && (c.Weather.Latitude != 0 || c.Weather.Longitude != 0) {
+		errs = append(errs, errors.New("set either weather latitude and longitude or [[weather.locations]] in the config"))
+	}
+	if len(c.Weather.Locations) > maxWeatherLocations {
+		errs = append(errs, fmt.Errorf("too many weather locations: %d (expected at most %d)", len(c.Weather.Locations), maxWeatherLocations))
+	}
+	for i, loc := range c.WeatherLocations() {
+		if err := loc.validate(i); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.Weather.HistoricalDays < 0 || c.Weather.HistoricalDays > maxHistoricalDays {
+		errs = append(errs, fmt.Errorf("invalid weather historical_days: %d (expected 0 to %d)", c.Weather.HistoricalDays, maxHistoricalDays))
+	}
+
+	if len(c.Calendars) == 0 && !c.meta.IsDefined("calendars") {
+		errs = append(errs, errors.New("no calendars in the config (set calendars = [] to show none)"))
+	}
+	for i, cal := range c.Calendars {
+		name := cmp.Or(cal.Name, fmt.Sprintf("#%d", i+1))
+		switch {
+		case cal.URL == "" && cal.Path == "":
+			errs = append(errs, fmt.Errorf("calendar %s needs a url or a path", name))
+		case cal.URL != "":
+			u, err := url.Parse(cal.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("invalid url of calendar %s: %s", name, cal.URL))
+			}
+		}
+		if !cal.Color.set {
+			errs = append(errs, fmt.Errorf("calendar %s has no color", name))
+		}
+		if cal.Refresh.duration < 0 {
+			errs = append(errs, fmt.Errorf("invalid refresh of calendar %s: %s", name, cal.Refresh.duration))
+		}
+	}
+
+	for i, tz := range c.Timezones {
+		if err := tz.validate(i); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	durations := []struct {
+		key      string
+		duration tomlDuration
+	}{
+		{"refresh_interval", c.RefreshInterval},
+		{"weather.refresh", c.Weather.Refresh},
+		{"quote.refresh", c.Quote.Refresh},
+		{"network.timeout", c.Network.Timeout},
+		{"transit.refresh", c.Transit.Refresh},
+	}
+	for _, d := range durations {
+		if d.duration.duration < 0 {
+			errs = append(errs, fmt.Errorf("invalid %s: %s", d.key, d.duration.duration))
+		}
+	}
+
+	if _, err := c.FitnessSource(http.DefaultClient); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.PollenSource(http.DefaultClient); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.TodoSource(http.DefaultClient, time.UTC); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.QuoteProvider(http.DefaultClient); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.WordSource(http.DefaultClient, time.UTC); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Photo.X < 0 || c.Photo.Y < 0 || c.Photo.Width < 0 || c.Photo.Height < 0 {
+		errs = append(errs, errors.New("invalid photo position or size: the values must not be negative"))
+	}
+	if c.Quote.History < 0 {
+		errs = append(errs, fmt.Errorf("invalid quote.history: %d", c.Quote.History))
+	}
+	if c.Transit.URL != "" && c.Transit.StopID == "" {
+		errs = append(errs, errors.New("transit.stop_id is not set in the config"))
+	}
+	if c.Transit.MaxDepartures < 0 {
+		errs = append(errs, fmt.Errorf("invalid transit.max_departures: %d", c.Transit.MaxDepartures))
+	}
+	if c.Todos.MaxTodos < 0 {
+		errs = append(errs, fmt.Errorf("invalid todos.max_todos: %d", c.Todos.MaxTodos))
+	}
+	if _, err := c.ProxyURL(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.TLSConfig(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.SPISpeed(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Display.DeepClean != 0 && !c.Schedule.QuietHours.set {
+		errs = append(errs, errors.New("display.deep_clean needs quiet_hours in the [schedule] table"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// logConfigProblems logs every problem found by Validate on its own line.
+func logConfigProblems(err error) {
+	problems := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		problems = joined.Unwrap()
+	}
+	for _, problem := range problems {
+		slog.Error("invalid config", "problem", problem)
+	}
+}
+
+// LogLevel returns the configured log level. It defaults to warnings so a
+// regular run only prints problems.
+func (c config) LogLevel() (slog.Level, error) {
+	if c.Log.Level == "" {
+		return slog.LevelWarn, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		return 0, fmt.Errorf("invalid log level: %s", c.Log.Level)
+	}
+
+	return level, nil
+}
+
+// GetWeekdayAbbreviations returns the short weekday names, starting with Sunday.
+// Custom abbreviations from the config take precedence over the locale.
+func (c config) GetWeekdayAbbreviations() ([7]string, error) {
+	if len(c.WeekdayAbbreviations) == 0 {
+		return c.Locale.WeekdayAbbreviations(), nil
+	}
+
+	var abbreviations [7]string
+	if len(c.WeekdayAbbreviations) != len(abbreviations) {
+		return abbreviations, fmt.Errorf("weekday_abbreviations needs exactly 7 entries, got %d", len(c.WeekdayAbbreviations))
+	}
+	copy(abbreviations[:], c.WeekdayAbbreviations)
+
+	return abbreviations, nil
+}
+
+// QuoteProvider returns the configured quote provider.
+func (c config) QuoteProvider(client *http.Client) (QuoteProvider, error) {
+	switch c.Quote.Provider {
+	case "", "zitat-service":
+		return NewZitatServiceProvider(client), nil
+	case "quotable":
+		return NewQuotableProvider(client), nil
+	case "file":
+		if c.Quote.Path == "" {
+			return nil, errors.New("quote path is not set in the config")
+		}
+		return NewFileQuoteProvider(c.Quote.Path), nil
+	case "exec":
+		if len(c.Quote.Command) == 0 {
+			return nil, errors.New("quote command is not set in the config")
+		}
+		return NewExecQuoteProvider(c.Quote.Command), nil
+	default:
+		return nil, fmt.Errorf("invalid quote provider: %s (expected zitat-service, quotable, file or exec)", c.Quote.Provider)
+	}
+}
+
+// QuoteHistory returns the number of recent quotes that are not shown again.
+func (c config) QuoteHistory() int {
+	if !c.meta.IsDefined("quote", "history") {
+		return defaultQuoteHistory
+	}
+	return c.Quote.History
+}
+
+// QuoteTimeout returns the timeout of a single quote request.
+func (c config) QuoteTimeout() time.Duration {
+	if c.Quote.FetchTimeoutSeconds <= 0 {
+		return defaultQuoteTimeout
+	}
+	return time.Duration(c.Quote.FetchTimeoutSeconds) * time.Second
+}
+
+// FontFiles returns the configured font files by style.
+func (c config) FontFiles() map[FontStyle]string {
+	return map[FontStyle]string{
+		FontRegular:  c.Fonts.Regular,
+		FontBold:     c.Fonts.Bold,
+		fontFallback: c.Fonts.Fallback,
+	}
+}
+
+// AppointmentCount returns the number of appointments to fetch. The week
+// view and the mini calendar need all appointments of the next days.
+func (c config) AppointmentCount() int {
+	if c.Appointments.View == AppointmentViewWeekGrid || c.Layout.ShowMiniMonth {
+		return 0
+	}
+	return calendarEventCount
+}
+
+// AppointmentsUntil returns the end of the appointments fetched at now. The
+// mini calendar marks the appointments of the rest of the month, which may
+// be further ahead than the horizon of the list.
+func (c config) AppointmentsUntil(now time.Time) time.Time {
+	until := now.Add(appointmentHorizon)
+	if c.Layout.ShowMiniMonth {
+		endOfMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+		if endOfMonth.After(until) {
+			return endOfMonth
+		}
+	}
+	return until
+}
+
+// Interval returns the time between two display updates.
+func (c config) Interval() time.Duration {
+	if c.RefreshInterval.duration <= 0 {
+		return defaultRefreshInterval
+	}
+	return c.RefreshInterval.duration
+}
+
+// StateFile returns the file the daemon keeps its state in.
+func (c config) StateFile() string {
+	if c.Display.StateFile == "" {
+		return defaultStateFile
+	}
+	return c.Display.StateFile
+}
+
+// PinnedQuote returns the quote pinned in the config and whether there is one.
+func (c config) PinnedQuote() (quote, bool) {
+	if c.Quote.PinnedText == "" {
+		return quote{}, false
+	}
+	return quote{Text: c.Quote.PinnedText, Author: c.Quote.PinnedAuthor, Pinned: true}, true
+}
+
+// ProxyURL returns the configured proxy, nil if none is configured.
+func (c config) ProxyURL() (*url.URL, error) {
+	if c.Network.ProxyURL == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(c.Network.ProxyURL)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid network proxy_url: %s", c.Network.ProxyURL)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid network proxy_url: %s (expected http, https or socks5)", c.Network.ProxyURL)
+	}
+
+	return proxyURL, nil
+}
+
+// TLSConfig returns the TLS settings of the remote sources, nil if the
+// defaults are used.
+func (c config) TLSConfig() (*tls.Config, error) {
+	n := c.Network
+	if !n.TLSSkipVerify && n.CACertFile == "" && n.ClientCertFile == "" && n.ClientKeyFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: n.TLSSkipVerify}
+
+	if n.CACertFile != "" {
+		pem, err := os.ReadFile(n.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read network ca_cert_file: %w", err)
+		}
+		// The bundle extends the system CAs instead of replacing them.
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in network ca_cert_file: %s", n.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (n.ClientCertFile == "") != (n.ClientKeyFile == "") {
+		return nil, errors.New("network client_cert_file and client_key_file must be set together")
+	}
+	if n.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(n.ClientCertFile, n.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load network client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Bounds and default of the SPI clock of the panel.
+const (
+	defaultSPIHz = 5_000_000
+	minSPIHz     = 100_000
+	maxSPIHz     = 20_000_000
+)
+
+// SPISpeed returns the configured SPI clock of the panel.
+func (c config) SPISpeed() (physic.Frequency, error) {
+	hz := c.Display.SPIHz
+	if hz == 0 {
+		hz = defaultSPIHz
+	}
+	if hz < minSPIHz || hz > maxSPIHz {
+		return 0, fmt.Errorf("invalid display spi_hz: %d (expected %d to %d)", hz, minSPIHz, maxSPIHz)
+	}
+
+	return physic.Frequency(hz) * physic.Hertz, nil
+}
+
+// FitnessSource returns the configured step count source, nil if none is configured.
+func (c config) FitnessSource(client *http.Client) (FitnessSource, error) {
+	switch c.Fitness.Provider {
+	case "":
+		return nil, nil
+	case "fitbit":
+		if c.Fitness.AccessToken == "" {
+			return nil, errors.New("fitness access token is not set in the config")
+		}
+		return NewFitbitSource(c.Fitness.AccessToken, client), nil
+	default:
+		return nil, fmt.Errorf("invalid fitness provider: %s (expected fitbit)", c.Fitness.Provider)
+	}
+}
+
+// SleepSource returns the configured sleep source, nil if none is configured.
+func (c config) SleepSource() *FitSleepSource {
+	if c.Sleep.Dir == "" {
+		return nil
+	}
+	return NewFitSleepSource(c.Sleep.Dir)
+}
+
+// PollenSource returns the configured pollen source, nil if none is configured.
+func (c config) PollenSource(client *http.Client) (PollenSource, error) {
+	switch c.Pollen.Provider {
+	case "":
+		return nil, nil
+	case "dwd":
+		species := c.Pollen.Species
+		if len(species) == 0 {
+			species = []string{"Birke", "Graeser", "Hasel"}
+		}
+		return NewDWDPollenSource(c.Pollen.Region, species, client), nil
+	default:
+		return nil, fmt.Errorf("invalid pollen provider: %s (expected dwd)", c.Pollen.Provider)
+	}
+}
+
+// TodoSource returns the configured todo source, nil if none is configured.
+// The due dates without a time are in location.
+func (c config) TodoSource(client *http.Client, location *time.Location) (TodoSource, error) {
+	switch c.Todos.Provider {
+	case "":
+		return nil, nil
+	case "todoist":
+		if c.Todos.APIToken == "" {
+			return nil, errors.New("todoist API token is not set in the config")
+		}
+		return NewTodoistSource(c.Todos.APIToken, location, client), nil
+	case "todotxt":
+		if c.Todos.Path == "" {
+			return nil, errors.New("todo.txt path is not set in the config")
+		}
+		return NewTodoTxtSource(c.Todos.Path, location), nil
+	default:
+		return nil, fmt.Errorf("invalid todos provider: %s (expected todoist or todotxt)", c.Todos.Provider)
+	}
+}
+
+// WordSource returns the configured word of the day source, nil if none is
+// configured. The day changes at midnight in location.
+func (c config) WordSource(client *http.Client, location *time.Location) (WordSource, error) {
+	if c.Word.Provider != "" && c.Word.Wordlist == "" {
+		return nil, errors.New("wordlist of the word of the day is not set in the config")
+	}
+
+	switch c.Word.Provider {
+	case "":
+		return nil, nil
+	case "local":
+		return NewLocalWordSource(c.Word.Wordlist, location), nil
+	case "merriam-webster":
+		if c.Word.APIKey == "" {
+			return nil, errors.New("merriam-webster API key is not set in the config")
+		}
+		return NewMerriamWebsterSource(c.Word.APIKey, c.Word.Wordlist, location, client), nil
+	default:
+		return nil, fmt.Errorf("invalid word provider: %s (expected local or merriam-webster)", c.Word.Provider)
+	}
+}
+
+// defaultGTFSCacheFile keeps the GTFS feed if no cache_file is configured.
+const defaultGTFSCacheFile = "gtfs.zip"
+
+// TransitSource returns the configured GTFS source, nil if none is configured.
+func (c config) TransitSource(client *http.Client, location *time.Location) *GTFSStaticSource {
+	if c.Transit.URL == "" {
+		return nil
+	}
+
+	cacheFile := c.Transit.CacheFile
+	if cacheFile == "" {
+		cacheFile = defaultGTFSCacheFile
+	}
+	return NewGTFSStaticSource(c.Transit.URL, c.Transit.StopID, cacheFile, c.Transit.Refresh.Or(defaultGTFSTTL), location, client)
+}
+
+// defaultMaxDepartures is the number of departures shown without max_departures.
+const defaultMaxDepartures = 4
+
+// MaxDepartures returns the number of departures shown.
+func (c config) MaxDepartures() int {
+	if c.Transit.MaxDepartures == 0 {
+		return defaultMaxDepartures
+	}
+	return c.Transit.MaxDepartures
+}
+
+// MaxTodos returns the number of tasks shown.
+func (c config) MaxTodos() int {
+	if c.Todos.MaxTodos == 0 {
+		return defaultMaxTodos
+	}
+	return c.Todos.MaxTodos
+}
+
+func (c config) GetCalendars(client *http.Client) Calendars {
 	calendars := make(Calendars, len(c.Calendars))
 	for i, cal := range c.Calendars {
-		calendars[i] = NewCalendar(cal.Name, cal.Color.color, cal.URL)
+		if cal.Path != "" {
+			calendars[i] = NewJSONCalendar(cal.Name, cal.Color.color, cal.Path)
+			continue
+		}
+		calendars[i] = NewCalendar(cal.Name, cal.Color.color, cal.URL, client)
 	}
 	return calendars
 }
 
+// maxWeatherLocations is the number of [[weather.locations]] entries that fit
+// on the dashboard.
+const maxWeatherLocations = 3
+
+// WeatherLocations returns the places the weather is fetched for. The first
+// one is shown in full, the others get a line each below it. Without
+// [[weather.locations]], latitude and longitude of [weather] are the only one.
+func (c config) WeatherLocations() []weatherLocation {
+	if len(c.Weather.Locations) > 0 {
+		return c.Weather.Locations
+	}
+	return []weatherLocation{{Latitude: c.Weather.Latitude, Longitude: c.Weather.Longitude}}
+}
+
+// weatherLocation is a place the weather is fetched for.
+type weatherLocation struct {
+	// Name is shown in the line of the location. It is not shown for the
+	// first location.
+	Name      string  `toml:"name"`
+	Latitude  float64 `toml:"latitude"`
+	Longitude float64 `toml:"longitude"`
+}
+
+// validate checks the coordinates of the i-th location.
+func (l weatherLocation) validate(i int) error {
+	label := "weather"
+	if l.Name != "" {
+		label = "weather location " + l.Name
+	}
+
+	switch {
+	case i > 0 && l.Name == "":
+		return fmt.Errorf("weather location #%d needs a name", i+1)
+	case l.Latitude == 0 && l.Longitude == 0:
+		return fmt.Errorf("%s latitude and longitude are not set in the config", label)
+	case l.Latitude < -90 || l.Latitude > 90:
+		return fmt.Errorf("invalid %s latitude: %g (expected -90 to 90)", label, l.Latitude)
+	case l.Longitude < -180 || l.Longitude > 180:
+		return fmt.Errorf("invalid %s longitude: %g (expected -180 to 180)", label, l.Longitude)
+	}
+
+	return nil
+}
+
 type calendarConfig struct {
-	URL   string    `toml:"url"`
+	URL string `toml:"url"`
+	// Path is a JSON file with events, used instead of URL (see JSONCalendar).
+	Path  string    `toml:"path"`
 	Name  string    `toml:"name"`
 	Color tomlColor `toml:"color"`
+	// Refresh is the time between two fetches of the calendar in daemon mode.
+	Refresh tomlDuration `toml:"refresh"`
 }
 
 type tomlColor struct {
 	color color.RGBA
+	set   bool
 }
 
 // UnmarshalText parses a color string to a color.RGBA.
@@ -54,6 +841,31 @@ func (c *tomlColor) UnmarshalText(text []byte) error {
 	}
 
 	c.color = value
+	c.set = true
+
+	return nil
+}
+
+type tomlDuration struct {
+	duration time.Duration
+}
+
+// UnmarshalText parses a duration string (e.g., "15m") to a time.Duration.
+func (d *tomlDuration) UnmarshalText(text []byte) error {
+	value, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration: %s", string(text))
+	}
+
+	d.duration = value
 
 	return nil
 }
+
+// Or returns the duration, or fallback if it is not set.
+func (d tomlDuration) Or(fallback time.Duration) time.Duration {
+	if d.duration <= 0 {
+		return fallback
+	}
+	return d.duration
+}
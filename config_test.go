@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfigTOML is the smallest config that passes Validate.
+const validConfigTOML = `
+timezone = "Europe/Berlin"
+refresh_interval = "15m"
+
+[weather]
+latitude = 47.56
+longitude = 7.58
+
+[[calendars]]
+name = "Privat"
+url = "https://example.com/privat.ics"
+color = "red"
+`
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		// replace replaces the first occurrence of old with new in the valid config.
+		old, new string
+		// top is added at the start, tables at the end.
+		top, tables string
+		wantErr     string
+	}{
+		{name: "valid"},
+
+		{name: "no timezone", old: `timezone = "Europe/Berlin"`, new: ``, wantErr: "timezone is not set"},
+		{name: "unknown timezone", old: `"Europe/Berlin"`, new: `"Europe/Basel"`, wantErr: "invalid timezone: Europe/Basel"},
+		{name: "UTC", old: `"Europe/Berlin"`, new: `"UTC"`},
+
+		{name: "latitude too small", old: `latitude = 47.56`, new: `latitude = -90.5`, wantErr: "invalid weather latitude: -90.5"},
+		{name: "latitude too large", old: `latitude = 47.56`, new: `latitude = 91`, wantErr: "invalid weather latitude: 91"},
+		{name: "latitude at the pole", old: `latitude = 47.56`, new: `latitude = -90`},
+		{name: "longitude too small", old: `longitude = 7.58`, new: `longitude = -181`, wantErr: "invalid weather longitude: -181"},
+		{name: "longitude too large", old: `longitude = 7.58`, new: `longitude = 180.1`, wantErr: "invalid weather longitude: 180.1"},
+		{name: "longitude at the date line", old: `longitude = 7.58`, new: `longitude = 180`},
+		{name: "null island", old: "latitude = 47.56\nlongitude = 7.58", new: "latitude = 0\nlongitude = 0", wantErr: "weather latitude and longitude are not set"},
+		{name: "no position", old: "latitude = 47.56\nlongitude = 7.58", new: "", wantErr: "weather latitude and longitude are not set"},
+		{name: "on the equator", old: `latitude = 47.56`, new: `latitude = 0`},
+		{
+			name:    "position and locations",
+			tables:  "[[weather.locations]]\nname = \"Bern\"\nlatitude = 46.95\nlongitude = 7.45\n",
+			wantErr: "set either weather latitude and longitude or [[weather.locations]]",
+		},
+		{
+			name:    "unnamed other location",
+			old:     "latitude = 47.56\nlongitude = 7.58",
+			tables:  "[[weather.locations]]\nlatitude = 46.95\nlongitude = 7.45\n[[weather.locations]]\nlatitude = 47.37\nlongitude = 8.54\n",
+			wantErr: "weather location #2 needs a name",
+		},
+
+		{name: "no calendars", old: "[[calendars]]\nname = \"Privat\"\nurl = \"https://example.com/privat.ics\"\ncolor = \"red\"", new: "", wantErr: "no calendars in the config"},
+		{name: "empty calendars", old: "[[calendars]]\nname = \"Privat\"\nurl = \"https://example.com/privat.ics\"\ncolor = \"red\"", new: "", top: "calendars = []"},
+		{name: "calendar without url", old: `url = "https://example.com/privat.ics"`, new: ``, wantErr: "calendar Privat needs a url or a path"},
+		{name: "calendar with a path", old: `url = "https://example.com/privat.ics"`, new: `path = "events.json"`},
+		{name: "calendar url without scheme", old: `"https://example.com/privat.ics"`, new: `"example.com/privat.ics"`, wantErr: "invalid url of calendar Privat"},
+		{name: "webcal url", old: `"https://example.com/privat.ics"`, new: `"webcal://example.com/privat.ics"`, wantErr: "invalid url of calendar Privat"},
+		{name: "calendar url without host", old: `"https://example.com/privat.ics"`, new: `"https:///privat.ics"`, wantErr: "invalid url of calendar Privat"},
+		{name: "unnamed calendar", old: `name = "Privat"`, new: ``, tables: "[[calendars]]\nurl = \"ftp://example.com\"\ncolor = \"red\"\n", wantErr: "invalid url of calendar #2"},
+
+		{name: "calendar without color", old: `color = "red"`, new: ``, wantErr: "calendar Privat has no color"},
+		{name: "unknown color", old: `color = "red"`, new: `color = "purple"`, wantErr: "invalid color name: purple"},
+		{name: "every color", old: `color = "red"`, new: `color = "black"`},
+
+		{name: "negative refresh interval", old: `"15m"`, new: `"-15m"`, wantErr: "invalid refresh_interval: -15m0s"},
+		{name: "invalid refresh interval", old: `"15m"`, new: `"15 minutes"`, wantErr: "invalid duration: 15 minutes"},
+		{name: "negative quote refresh", tables: "[quote]\nrefresh = \"-1h\"\n", wantErr: "invalid quote.refresh: -1h0m0s"},
+		{name: "negative calendar refresh", old: `color = "red"`, new: "color = \"red\"\nrefresh = \"-5m\"", wantErr: "invalid refresh of calendar Privat: -5m0s"},
+
+		{name: "unknown appointment view", tables: "[appointments]\nview = \"month\"\n", wantErr: "month"},
+		{name: "unknown separator", tables: "[layout]\nseparator = \"wavy\"\n", wantErr: "wavy"},
+		{name: "unknown key", tables: "[layout]\nshow_moon = true\n", wantErr: "unknown config key: layout.show_moon"},
+		{name: "unknown table", tables: "[moon]\nshow = true\nphase = 1\n", wantErr: "unknown config key: moon"},
+		{name: "deep clean without quiet hours", tables: "[display]\ndeep_clean = \"weekly\"\n", wantErr: "display.deep_clean needs quiet_hours"},
+		{name: "deep clean with quiet hours", tables: "[display]\ndeep_clean = \"weekly\"\n[schedule]\nquiet_hours = \"23:00-06:00\"\n"},
+		{name: "invalid quiet hours", tables: "[schedule]\nquiet_hours = \"night\"\n", wantErr: "invalid quiet hours: night"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := validConfigTOML
+			if tt.old != "" {
+				if !strings.Contains(data, tt.old) {
+					t.Fatalf("the valid config has no %q", tt.old)
+				}
+				data = strings.Replace(data, tt.old, tt.new, 1)
+			}
+			data = tt.top + data + tt.tables
+
+			cfg, err := parseConfig([]byte(data))
+			if err == nil {
+				err = cfg.Validate()
+			}
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("config is invalid: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidateJoinsProblems(t *testing.T) {
+	data := strings.NewReplacer(`"Europe/Berlin"`, `"Mars/Olympus"`, `latitude = 47.56`, `latitude = 123`, `color = "red"`, ``).Replace(validConfigTOML)
+	cfg, err := parseConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cfg.Validate()
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 3 {
+		t.Errorf("err = %v, want the 3 problems joined", err)
+	}
+}
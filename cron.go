@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a standard 5-field cron expression: minute, hour, day of
+// month, month and day of week, e.g. "0 6,12,18,23 * * 1-5". Every field is
+// "*", a number, a range like "1-5" or a list of them, each optionally with a
+// step like "*/15". Sunday is 0 or 7. As in cron, a time matches if the day
+// of month or the day of week matches when both are restricted.
+type cronSchedule struct {
+	minute, hour, day, month, weekday uint64
+	// dayAny and weekdayAny are set for "*", so only the other field counts.
+	dayAny, weekdayAny bool
+	expr               string
+}
+
+// cronFields are the bounds of the fields of a cron expression.
+var cronFields = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// cronSearchYears limits the search for the next time of expressions that
+// never match, e.g. "0 0 30 2 *".
+const cronSearchYears = 5
+
+// UnmarshalText parses a cron expression. An empty expression is unset.
+func (c *cronSchedule) UnmarshalText(text []byte) error {
+	expr := strings.TrimSpace(string(text))
+	if expr == "" {
+		*c = cronSchedule{}
+		return nil
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return fmt.Errorf("invalid cron expression: %s (expected 5 fields)", expr)
+	}
+
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return fmt.Errorf("invalid %s in cron expression %s: %w", cronFields[i].name, expr, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday may be 0 or 7.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	schedule := cronSchedule{
+		minute:     sets[0],
+		hour:       sets[1],
+		day:        sets[2],
+		month:      sets[3],
+		weekday:    sets[4],
+		dayAny:     strings.HasPrefix(fields[2], "*"),
+		weekdayAny: strings.HasPrefix(fields[4], "*"),
+		expr:       expr,
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("invalid cron expression: %s never matches", expr)
+	}
+
+	*c = schedule
+
+	return nil
+}
+
+// parseCronField returns the values of a field as a bit set.
+func parseCronField(field string, low, high int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		start, end := low, high
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			start, err = strconv.Atoi(from)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", from)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				// "5/15" starts at 5 and runs to the end.
+				end = high
+			}
+		}
+		if start < low || end > high || start > end {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, low, high)
+		}
+
+		for v := start; v <= end; v += step {
+			set |= 1 << v
+		}
+	}
+
+	return set, nil
+}
+
+// IsSet reports whether an expression was configured.
+func (c cronSchedule) IsSet() bool {
+	return c.expr != ""
+}
+
+// String returns the expression.
+func (c cronSchedule) String() string {
+	return c.expr
+}
+
+// Next returns the first full minute after t that matches the schedule, in
+// the location of t. It returns the zero time if there is none. Like in
+// cron, the times are wall clock times: a time skipped when the clocks go
+// forward doesn't match that day, and a time repeated when they go back
+// matches twice.
+func (c cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			// Adding the minutes instead of normalizing the hour with
+			// time.Date visits both of the hours repeated when the clocks
+			// go back.
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// matchesDay reports whether the day of t matches the day of month and the
+// day of week fields.
+func (c cronSchedule) matchesDay(t time.Time) bool {
+	day := c.day&(1<<t.Day()) != 0
+	weekday := c.weekday&(1<<int(t.Weekday())) != 0
+
+	switch {
+	case c.dayAny:
+		return weekday
+	case c.weekdayAny:
+		return day
+	default:
+		return day || weekday
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// cronSet returns the bit set of the values.
+func cronSet(values ...int) uint64 {
+	var set uint64
+	for _, v := range values {
+		set |= 1 << v
+	}
+	return set
+}
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field     string
+		low, high int
+		want      uint64
+		wantErr   bool
+	}{
+		{field: "*", low: 0, high: 6, want: cronSet(0, 1, 2, 3, 4, 5, 6)},
+		{field: "5", low: 0, high: 59, want: cronSet(5)},
+		{field: "1,15,30", low: 0, high: 59, want: cronSet(1, 15, 30)},
+		{field: "1-5", low: 0, high: 7, want: cronSet(1, 2, 3, 4, 5)},
+		{field: "*/15", low: 0, high: 59, want: cronSet(0, 15, 30, 45)},
+		{field: "5/15", low: 0, high: 59, want: cronSet(5, 20, 35, 50)},
+		{field: "10-20/5", low: 0, high: 59, want: cronSet(10, 15, 20)},
+		{field: "1-3,22-23/2", low: 0, high: 23, want: cronSet(1, 2, 3, 22)},
+		{field: "*/5", low: 1, high: 12, want: cronSet(1, 6, 11)},
+		{field: "7", low: 0, high: 7, want: cronSet(7)},
+		{field: "0", low: 1, high: 31, wantErr: true},
+		{field: "32", low: 1, high: 31, wantErr: true},
+		{field: "5-1", low: 0, high: 59, wantErr: true},
+		{field: "*/0", low: 0, high: 59, wantErr: true},
+		{field: "*/x", low: 0, high: 59, wantErr: true},
+		{field: "a", low: 0, high: 59, wantErr: true},
+		{field: "1-", low: 0, high: 59, wantErr: true},
+		{field: "1,,2", low: 0, high: 59, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCronField(tt.field, tt.low, tt.high)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseCronField(%q) = %b, want an error", tt.field, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCronField(%q): %v", tt.field, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseCronField(%q) = %b, want %b", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestCronScheduleUnmarshal(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: ""},
+		{expr: "0 6,12,18,23 * * 1-5"},
+		{expr: "*/15 * * * *"},
+		{expr: "0 0 29 2 *"},
+		{expr: "0 0 30 2 *", wantErr: true},
+		{expr: "0 0 31 4,6,9,11 *", wantErr: true},
+		{expr: "0 0 * *", wantErr: true},
+		{expr: "0 0 * * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "0 24 * * *", wantErr: true},
+		{expr: "0 0 * 13 *", wantErr: true},
+		{expr: "0 0 * * 8", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var c cronSchedule
+		err := c.UnmarshalText([]byte(tt.expr))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalText(%q): err = %v, want error %v", tt.expr, err, tt.wantErr)
+		}
+		if err == nil && c.IsSet() != (tt.expr != "") {
+			t.Errorf("UnmarshalText(%q): IsSet = %v", tt.expr, c.IsSet())
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The offsets tell CET (+01:00) from CEST (+02:00) on the days the
+	// clocks change.
+	parse := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04 -07:00", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.In(berlin)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want string
+	}{
+		{"next minute", "* * * * *", "2025-03-14 09:30 +01:00", "2025-03-14 09:31 +01:00"},
+		{"step", "*/15 * * * *", "2025-03-14 09:31 +01:00", "2025-03-14 09:45 +01:00"},
+		{"step from a start", "5/15 * * * *", "2025-03-14 09:36 +01:00", "2025-03-14 09:50 +01:00"},
+		{"list of hours", "0 6,12,18,23 * * *", "2025-03-14 12:00 +01:00", "2025-03-14 18:00 +01:00"},
+		{"next day", "0 6,12,18,23 * * *", "2025-03-14 23:00 +01:00", "2025-03-15 06:00 +01:00"},
+		{"weekdays skip the weekend", "0 7 * * 1-5", "2025-03-14 08:00 +01:00", "2025-03-17 07:00 +01:00"},
+		{"Sunday as 7", "0 9 * * 7", "2025-03-14 08:00 +01:00", "2025-03-16 09:00 +01:00"},
+		{"Sunday as 0", "0 9 * * 0", "2025-03-14 08:00 +01:00", "2025-03-16 09:00 +01:00"},
+		{"next month", "0 0 1 * *", "2025-03-14 08:00 +01:00", "2025-04-01 00:00 +02:00"},
+		{"next year", "0 0 1 1 *", "2025-03-14 08:00 +01:00", "2026-01-01 00:00 +01:00"},
+		{"31st skips short months", "0 0 31 * *", "2025-03-31 08:00 +02:00", "2025-05-31 00:00 +02:00"},
+		{"leap day", "0 0 29 2 *", "2025-03-01 00:00 +01:00", "2028-02-29 00:00 +01:00"},
+		// Friday the 14th matches the day of week, the 20th the day of month.
+		{"day of month or day of week", "0 0 20 * 5", "2025-03-13 00:00 +01:00", "2025-03-14 00:00 +01:00"},
+		{"day of month or day of week, day of month", "0 0 20 * 5", "2025-03-18 00:00 +01:00", "2025-03-20 00:00 +01:00"},
+		// A field starting with * doesn't restrict the day, like in cron.
+		{"day of month with a step", "0 0 */10 * 1", "2025-03-14 00:00 +01:00", "2025-03-17 00:00 +01:00"},
+		{"only the day of month", "0 0 20 * *", "2025-03-14 00:00 +01:00", "2025-03-20 00:00 +01:00"},
+		{"never", "0 0 30 2 *", "2025-03-14 00:00 +01:00", ""},
+
+		// The clocks go forward from 02:00 to 03:00 on March 30, 2025.
+		{"hourly across the gap", "0 * * * *", "2025-03-30 01:30 +01:00", "2025-03-30 03:00 +02:00"},
+		{"skipped time", "30 2 * * *", "2025-03-30 00:00 +01:00", "2025-03-31 02:30 +02:00"},
+		{"after the gap", "30 3 * * *", "2025-03-30 00:00 +01:00", "2025-03-30 03:30 +02:00"},
+		{"every 15 minutes across the gap", "*/15 * * * *", "2025-03-30 01:50 +01:00", "2025-03-30 03:00 +02:00"},
+		// The clocks go back from 03:00 to 02:00 on October 26, 2025.
+		{"repeated time, first", "30 2 * * *", "2025-10-26 00:00 +02:00", "2025-10-26 02:30 +02:00"},
+		{"repeated time, second", "30 2 * * *", "2025-10-26 02:30 +02:00", "2025-10-26 02:30 +01:00"},
+		{"repeated time, next day", "30 2 * * *", "2025-10-26 02:30 +01:00", "2025-10-27 02:30 +01:00"},
+		{"hourly across the repeated hour", "0 * * * *", "2025-10-26 02:30 +02:00", "2025-10-26 02:00 +01:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c cronSchedule
+			if tt.want != "" {
+				if err := c.UnmarshalText([]byte(tt.expr)); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				// UnmarshalText rejects expressions that never match.
+				c = cronSchedule{minute: cronSet(0), hour: cronSet(0), day: cronSet(30), month: cronSet(2), weekdayAny: true, expr: tt.expr}
+			}
+
+			got := c.Next(parse(tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next = %v, want none", got)
+				}
+				return
+			}
+			if want := parse(tt.want); !got.Equal(want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got.Format("2006-01-02 15:04 -07:00"), want.Format("2006-01-02 15:04 -07:00"))
+			}
+			if got.Location() != berlin {
+				t.Errorf("Next is in %v, want the location of t", got.Location())
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// defaultRefreshInterval is used if no refresh interval is configured.
+const defaultRefreshInterval = 15 * time.Minute
+
+// runDaemon keeps the process running and updates the display every refresh
+// interval, or at the times of the refresh cron schedule, if the data
+// changed. The data sources are refreshed in the background by a DataCache
+// at their own intervals, so a slow source never delays an update. Failed
+// updates are logged and retried with the next refresh.
+func runDaemon(ctx context.Context, cfg config, location *time.Location, logger *slog.Logger) error {
+	interval := cfg.Interval()
+
+	epd, err := openDisplay(cfg, logger)
+	if err != nil {
+		return withExitCode(exitDisplay, fmt.Errorf("failed to connect to display: %w", err))
+	}
+
+	if cfg.Metrics.Listen != "" {
+		if err = serveMetrics(ctx, cfg.Metrics.Listen); err != nil {
+			return withExitCode(exitConfig, err)
+		}
+	}
+
+	cache := NewDataCache(cfg, location)
+	cache.Start(ctx)
+
+	// triggered requests an update before the interval elapsed.
+	triggered := make(chan struct{}, 1)
+	var mqtt *MQTTTrigger
+	if cfg.MQTT.Broker != "" {
+		mqtt = NewMQTTTrigger(cfg.MQTT, triggered)
+		mqtt.SetState(haSensorDisplay, haDisplaySleeping)
+		go mqtt.Run(ctx)
+	}
+
+	_ = sdNotify("STATUS=Fetching data")
+	if err = cache.WaitReady(ctx); err != nil {
+		return nil
+	}
+
+	_ = sdNotify("READY=1")
+
+	// The display is only refreshed if the data of a source changed since
+	// the last update, or the hour or the day changed, so the hourly
+	// forecast and the dates are never stale. Triggered updates are always
+	// shown.
+	var shownVersion uint64
+	var shownAt time.Time
+	var trigger bool
+
+	for {
+		// Wait for the end of the quiet hours and update right away.
+		if now := time.Now().In(location); cfg.Schedule.QuietHours.Contains(now) {
+			if err = deepCleanIfDue(ctx, epd, cfg, now); err != nil {
+				slog.Error("failed to deep clean display", "error", err)
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			end := cfg.Schedule.QuietHours.End(now)
+			slog.Info("quiet hours, pausing the updates", "until", end)
+			_ = sdNotify("STATUS=Paused until " + end.Format(time.TimeOnly))
+			if err = sleepContext(ctx, end.Sub(now)); err != nil {
+				return nil
+			}
+
+			// The cache fetches every source when the window ends, show
+			// their new data rather than the one from before the window.
+			_ = sdNotify("STATUS=Fetching data")
+			if err = cache.WaitFetched(ctx, end); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		version := cache.Version()
+		if !trigger && !shownAt.IsZero() && version == shownVersion && sameHour(shownAt, time.Now()) {
+			slog.Info("no data changed, skipping the update")
+		} else {
+			refreshStart := time.Now()
+			mqtt.SetState(haSensorDisplay, haDisplayOn)
+			err = refreshDisplay(ctx, epd, cfg, cache)
+			mqtt.SetState(haSensorDisplay, haDisplaySleeping)
+			metrics.observePhase(phaseRefresh, time.Since(refreshStart), err)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				slog.Error("failed to update dashboard", "error", err)
+				_ = sdNotify("STATUS=" + err.Error())
+				mqtt.SetState(haSensorLastError, err.Error())
+			} else {
+				mqtt.SetState(haSensorLastRefresh, refreshStart.Format(time.RFC3339))
+				mqtt.SetState(haSensorLastError, haNoError)
+				shownVersion, shownAt = version, refreshStart
+				_ = sdNotify("STATUS=Display updated at " + time.Now().Format(time.TimeOnly))
+			}
+		}
+
+		next := time.Now().Add(interval)
+		if cfg.RefreshCron.IsSet() {
+			next = cfg.RefreshCron.Next(time.Now().In(location))
+		}
+		slog.Info("next scheduled refresh", "at", next.Format(time.DateTime))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(next)):
+			trigger = false
+		case <-triggered:
+			trigger = true
+		}
+	}
+}
+
+// sameHour reports whether a and b are in the same hour of the same day.
+func sameHour(a, b time.Time) bool {
+	return daysUntil(a, b) == 0 && a.Truncate(time.Hour).Equal(b.Truncate(time.Hour))
+}
+
+// refreshDisplay renders the cached data and shows it on the display. The
+// panel is left in deep sleep between updates.
+func refreshDisplay(ctx context.Context, epd Display, cfg config, cache *DataCache) error {
+	dashboardConfig, err := newDashboardConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	var canvas *gg.Context
+	data, renderErr := cache.Snapshot()
+	if renderErr == nil {
+		canvas, renderErr = renderDashboard(data, dashboardConfig)
+	}
+
+	return presentAndSleep(ctx, epd, canvas, renderErr, dashboardConfig)
+}
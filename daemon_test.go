@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRefreshDisplaySleeps(t *testing.T) {
+	shortenDisplaySettleTime(t)
+	client := mockWeatherClient("testdata/weather")
+	daily, err := fetchDailyWeather(context.Background(), fixtureLocation, "Europe/Berlin", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hourly, err := fetchHourlyWeather(context.Background(), fixtureLocation, "Europe/Berlin", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		weather bool
+		calls   []string
+		err     error
+	}{
+		{name: "success", ctx: context.Background(), weather: true, calls: []string{"Wake", "Clear", "Display", "Sleep"}},
+		// Without weather, the error screen is shown.
+		{name: "no weather", ctx: context.Background(), calls: []string{"Wake", "Clear", "Display", "Sleep"}, err: errNoWeather},
+		{name: "cancelled", ctx: cancelled, weather: true, calls: []string{"Wake", "Sleep"}, err: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newTestCache(newFakeClock())
+			if tt.weather {
+				cache.dailyWeather, cache.hourlyWeather = daily, hourly
+			}
+
+			epd := &fakeDisplay{}
+			err := refreshDisplay(tt.ctx, epd, config{Timezone: "Europe/Berlin"}, cache)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if !slices.Equal(epd.calls, tt.calls) {
+				t.Errorf("got calls %v, want %v", epd.calls, tt.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/fogleman/gg"
+)
+
+// dashboardCanvas is the drawing context of GenerateDashboard. With the
+// debug overlay enabled, it records the bounds of the strings and images
+// drawn through it and the areas of the sections, so drawDebugOverlay can
+// outline them when the layout is done.
+type dashboardCanvas struct {
+	*gg.Context
+
+	debug bool
+	boxes []debugBox
+}
+
+// debugBox is a rectangle outlined by the debug overlay.
+type debugBox struct {
+	x, y, w, h float64
+	color      color.Color
+}
+
+// Colors of the debug overlay. Sections are outlined in a different color
+// than the elements inside them.
+var (
+	debugElementColor = ColorRed
+	debugSectionColor = ColorGreen
+)
+
+// newDashboardCanvas creates a canvas of the given size. debug enables the overlay.
+func newDashboardCanvas(width, height int, debug bool) *dashboardCanvas {
+	return &dashboardCanvas{Context: gg.NewContext(width, height), debug: debug}
+}
+
+// DrawStringAnchored draws s like gg.Context.DrawStringAnchored and records its bounds.
+func (dc *dashboardCanvas) DrawStringAnchored(s string, x, y, ax, ay float64) {
+	dc.Context.DrawStringAnchored(s, x, y, ax, ay)
+
+	if dc.debug {
+		w, h := dc.MeasureString(s)
+		// gg moves the baseline by ay times the height, the glyphs are above it.
+		dc.boxes = append(dc.boxes, debugBox{x - ax*w, y + ay*h - h, w, h, debugElementColor})
+	}
+}
+
+// DrawImageAnchored draws im like gg.Context.DrawImageAnchored and records its bounds.
+func (dc *dashboardCanvas) DrawImageAnchored(im image.Image, x, y int, ax, ay float64) {
+	dc.Context.DrawImageAnchored(im, x, y, ax, ay)
+
+	if dc.debug {
+		w, h := float64(im.Bounds().Dx()), float64(im.Bounds().Dy())
+		dc.boxes = append(dc.boxes, debugBox{float64(x) - ax*w, float64(y) - ay*h, w, h, debugElementColor})
+	}
+}
+
+// section records the vertical area from top to bottom allocated to a section.
+func (dc *dashboardCanvas) section(top, bottom float64) {
+	if dc.debug {
+		dc.boxes = append(dc.boxes, debugBox{0, top, float64(dc.Width()), bottom - top, debugSectionColor})
+	}
+}
+
+// drawDebugOverlay outlines the recorded boxes with 1px lines on top of the dashboard.
+func (dc *dashboardCanvas) drawDebugOverlay() {
+	dc.SetLineWidth(1)
+	for _, box := range dc.boxes {
+		dc.SetColor(box.color)
+		dc.DrawRectangle(box.x+0.5, box.y+0.5, box.w, box.h)
+		dc.Stroke()
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// deepCleanPeriod is how often the daemon runs a deep clean of the panel:
+// "daily", "weekly" or "monthly".
+type deepCleanPeriod int
+
+// UnmarshalText parses the period from the config file.
+func (p *deepCleanPeriod) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "":
+		*p = 0
+	case "daily":
+		*p = 1
+	case "weekly":
+		*p = 7
+	case "monthly":
+		*p = 30
+	default:
+		return fmt.Errorf("invalid deep clean period: %s (expected daily, weekly or monthly)", string(text))
+	}
+	return nil
+}
+
+// Due reports whether a deep clean is due on the day of now if the last
+// one ran at last. Whole days are counted, so a clean at the start of the
+// quiet hours is due at the same time a period later.
+func (p deepCleanPeriod) Due(last, now time.Time) bool {
+	if p == 0 {
+		return false
+	}
+	return last.IsZero() || daysUntil(last, now) >= int(p)
+}
+
+// defaultStateFile is the file of daemonState if none is configured.
+const defaultStateFile = "epd-state.json"
+
+// stateMu serializes the updates of the state file by the quote fetcher
+// and the deep clean.
+var stateMu sync.Mutex
+
+// daemonState is kept between runs of the daemon.
+type daemonState struct {
+	LastDeepClean time.Time `json:"last_deep_clean"`
+	// RecentQuotes are the hashes of the last quotes, the newest last.
+	RecentQuotes []string `json:"recent_quotes,omitempty"`
+}
+
+// loadDaemonState reads the state from path. A missing file is an empty state.
+func loadDaemonState(path string) (daemonState, error) {
+	var state daemonState
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+
+	return state, nil
+}
+
+// save writes the state to path. Like the dashboard, it is written to a
+// temporary file that is renamed, so a crash can't leave a partial state.
+func (s daemonState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	err = writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+
+	return nil
+}
+
+// updateDaemonState reads the state from path, changes it with update and
+// writes it back. Other updates wait until it is written.
+func updateDaemonState(path string, update func(state *daemonState)) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	state, err := loadDaemonState(path)
+	if err != nil {
+		return err
+	}
+	update(&state)
+
+	return state.save(path)
+}
+
+// deepClean wakes the display up, runs a deep clean and puts it back to sleep.
+func deepClean(ctx context.Context, epd Display) error {
+	defer epd.Sleep()
+
+	slog.Info("starting deep clean of the display")
+	start := time.Now()
+
+	if err := epd.Wake(); err != nil {
+		return fmt.Errorf("failed to wake up display: %w", err)
+	}
+	if err := epd.DeepClean(ctx); err != nil {
+		return fmt.Errorf("failed to deep clean display: %w", err)
+	}
+
+	slog.Info("finished deep clean of the display", "duration", time.Since(start))
+
+	return nil
+}
+
+// deepCleanIfDue runs a deep clean if the configured period has passed since
+// the last one in the state file. It is called at the start of the quiet
+// hours, so the blank screen afterwards goes unnoticed.
+func deepCleanIfDue(ctx context.Context, epd Display, cfg config, now time.Time) error {
+	if cfg.Display.DeepClean == 0 {
+		return nil
+	}
+
+	path := cfg.StateFile()
+	state, err := loadDaemonState(path)
+	if err != nil {
+		return err
+	}
+	if !cfg.Display.DeepClean.Due(state.LastDeepClean, now) {
+		return nil
+	}
+
+	if err = deepClean(ctx, epd); err != nil {
+		return err
+	}
+
+	// The quotes may have changed the state during the deep clean.
+	return updateDaemonState(path, func(state *daemonState) {
+		state.LastDeepClean = now
+	})
+}
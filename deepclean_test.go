@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDeepCleanIfDue(t *testing.T) {
+	berlin := loadBerlin(t)
+	now := time.Date(2024, 3, 15, 22, 0, 0, 0, berlin)
+	errPanel := errors.New("panel unavailable")
+	cleaning := []string{"Wake", "DeepClean", "Sleep"}
+
+	tests := []struct {
+		name   string
+		period deepCleanPeriod
+		last   time.Time
+		errs   map[string]error
+		calls  []string
+		// cleaned is whether the time of the clean is saved.
+		cleaned bool
+		err     error
+	}{
+		{name: "disabled", period: 0, last: now.AddDate(0, -2, 0)},
+		{name: "never cleaned", period: 7, calls: cleaning, cleaned: true},
+		{name: "weekly due", period: 7, last: now.AddDate(0, 0, -7), calls: cleaning, cleaned: true},
+		// Whole days are counted, a later time on the day doesn't matter.
+		{name: "weekly due later in the day", period: 7, last: now.AddDate(0, 0, -7).Add(time.Hour), calls: cleaning, cleaned: true},
+		{name: "weekly not due", period: 7, last: now.AddDate(0, 0, -6)},
+		{name: "daily due", period: 1, last: now.AddDate(0, 0, -1), calls: cleaning, cleaned: true},
+		{name: "daily not due", period: 1, last: now.Add(-time.Hour)},
+		{name: "monthly not due", period: 30, last: now.AddDate(0, 0, -29)},
+		// A failed clean is retried at the next start of the quiet hours.
+		{name: "failed clean", period: 7, last: now.AddDate(0, 0, -8), errs: map[string]error{"DeepClean": errPanel}, calls: cleaning, err: errPanel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			cfg.Display.DeepClean = tt.period
+			cfg.Display.StateFile = filepath.Join(t.TempDir(), "state.json")
+
+			quotes := []string{"a", "b"}
+			err := updateDaemonState(cfg.StateFile(), func(state *daemonState) {
+				state.LastDeepClean = tt.last
+				state.RecentQuotes = quotes
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			epd := &fakeDisplay{errs: tt.errs}
+			err = deepCleanIfDue(context.Background(), epd, cfg, now)
+			if !errors.Is(err, tt.err) || (err == nil) != (tt.err == nil) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if !slices.Equal(epd.calls, tt.calls) {
+				t.Errorf("got calls %v, want %v", epd.calls, tt.calls)
+			}
+
+			state, err := loadDaemonState(cfg.StateFile())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.last
+			if tt.cleaned {
+				want = now
+			}
+			if !state.LastDeepClean.Equal(want) {
+				t.Errorf("got last deep clean %v, want %v", state.LastDeepClean, want)
+			}
+			if !slices.Equal(state.RecentQuotes, quotes) {
+				t.Errorf("got recent quotes %v, want them kept", state.RecentQuotes)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"image"
+	"log/slog"
+)
+
+// Display is the e-paper panel the dashboard is shown on. It is implemented
+// by Epd and, for development without the hardware, by EpdSimulator.
+type Display interface {
+	// Wake wakes the display up and prepares it for an update.
+	Wake() error
+	// Clear clears the display to white.
+	Clear(ctx context.Context) error
+	// Display shows the image.
+	Display(ctx context.Context, img image.Image) error
+	// DeepClean drives all pixels through every color to reduce ghosting.
+	DeepClean(ctx context.Context) error
+	// Sleep puts the display into deep sleep.
+	Sleep()
+}
+
+// openDisplay connects to the panel, or to the simulator if -simulate is set.
+func openDisplay(cfg config, logger *slog.Logger) (Display, error) {
+	if *simulate != "" {
+		return newSimulator(*simulate, logger)
+	}
+
+	speed, err := cfg.SPISpeed()
+	if err != nil {
+		return nil, err
+	}
+	return New(pin(dcPin), pin(csPin), pin(resetPin), pin(busyPin), speed, logger)
+}
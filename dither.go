@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+)
+
+// defaultDitherSize is the size of the Bayer matrix if none is configured.
+const defaultDitherSize = 4
+
+// ditherSize is the size of the Bayer matrix of the grayscale mode: 2, 4 or 8.
+// Larger matrices show more shades of gray in a coarser pattern.
+type ditherSize int
+
+// UnmarshalText validates the matrix size from the config file.
+func (s *ditherSize) UnmarshalText(text []byte) error {
+	size, err := strconv.Atoi(string(text))
+	if err != nil || (size != 2 && size != 4 && size != 8) {
+		return fmt.Errorf("invalid dither size: %s (expected 2, 4 or 8)", string(text))
+	}
+
+	*s = ditherSize(size)
+
+	return nil
+}
+
+// Or returns the size, or fallback if it is not set.
+func (s ditherSize) Or(fallback int) int {
+	if s == 0 {
+		return fallback
+	}
+	return int(s)
+}
+
+// bayerMatrix returns the Bayer threshold matrix of the given size, which
+// must be a power of two. It contains every value from 0 to size*size-1.
+func bayerMatrix(size int) [][]int {
+	if size <= 1 {
+		return [][]int{{0}}
+	}
+
+	half := bayerMatrix(size / 2)
+	matrix := make([][]int, size)
+	for y := range matrix {
+		matrix[y] = make([]int, size)
+		for x := range matrix[y] {
+			// The quadrants are offset by 0, 2, 3 and 1 of the smaller matrix.
+			offset := [2][2]int{{0, 2}, {3, 1}}[y*2/size][x*2/size]
+			matrix[y][x] = 4*half[y%(size/2)][x%(size/2)] + offset
+		}
+	}
+	return matrix
+}
+
+// ditherOrdered converts img to grayscale and dithers it with a Bayer matrix
+// of the given size, so the result only contains black and white. The panel
+// shows the pattern as shades of gray, which suits photos and charts better
+// than snapping every pixel to the closest of its colors.
+func ditherOrdered(img image.Image, size int) *image.Paletted {
+	matrix := bayerMatrix(size)
+	cells := size * size
+
+	bounds := img.Bounds()
+	dithered := image.NewPaletted(bounds, color.Palette{ColorBlack, ColorWhite})
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			gray := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+
+			// The threshold of a cell is in the middle of its share of 0-255.
+			threshold := (matrix[y%size][x%size]*2 + 1) * 255 / (cells * 2)
+			if int(gray) > threshold {
+				dithered.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	return dithered
+}
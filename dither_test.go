@@ -0,0 +1,63 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// grayRamp returns an image with a column of size pixels for every gray
+// level from black to white, so each column covers the Bayer matrix.
+func grayRamp(size int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 256*size, 2*size))
+	for y := range img.Bounds().Dy() {
+		for x := range img.Bounds().Dx() {
+			img.SetGray(x, y, color.Gray{Y: uint8(x / size)})
+		}
+	}
+	return img
+}
+
+func TestDitherOrderedHistogram(t *testing.T) {
+	for _, size := range []int{2, 4, 8} {
+		cells := size * size
+		ramp := grayRamp(size)
+		dithered := ditherOrdered(ramp, size)
+
+		// A shade is off by at most half a step of the matrix, plus the
+		// rounding of the thresholds.
+		tolerance := 1/float64(2*cells) + 1/255.0
+		shades := make(map[int]bool)
+		previous, total := 0, 0
+		for level := range 256 {
+			white := 0
+			for y := range ramp.Bounds().Dy() {
+				for x := level * size; x < (level+1)*size; x++ {
+					if dithered.ColorIndexAt(x, y) == 1 {
+						white++
+					}
+				}
+			}
+			total += white
+			shades[white] = true
+
+			ratio := float64(white) / float64(2*cells)
+			if want := float64(level) / 255; math.Abs(ratio-want) > tolerance {
+				t.Errorf("size %d, gray %d: got %.3f white, want %.3f", size, level, ratio, want)
+			}
+			if white < previous {
+				t.Errorf("size %d, gray %d: got fewer white pixels than at gray %d", size, level, level-1)
+			}
+			previous = white
+		}
+
+		// Every cell of the matrix adds a shade to black and white.
+		if len(shades) != cells+1 {
+			t.Errorf("size %d: got %d shades, want %d", size, len(shades), cells+1)
+		}
+		if ratio := float64(total) / float64(256*2*cells); math.Abs(ratio-0.5) > 1/255.0 {
+			t.Errorf("size %d: got %.3f white over the ramp, want 0.5", size, ratio)
+		}
+	}
+}
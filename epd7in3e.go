@@ -8,10 +8,12 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"log/slog"
 	"time"
 
 	"periph.io/x/conn/v3"
@@ -29,6 +31,41 @@ const (
 	EPD_HEIGHT int = 480
 )
 
+// busyTimeout is the maximum time to wait for the panel to become idle.
+const busyTimeout = 30 * time.Second
+
+// errBusyTimeout is returned if the panel does not become idle in time.
+var errBusyTimeout = errors.New("epd: waitUntilIdle timed out")
+
+// errNotReady is returned if the panel is updated without Init.
+var errNotReady = errors.New("epd: display is not initialized")
+
+// powerState is the state of the controller as far as the driver knows.
+type powerState int
+
+const (
+	// powerOff is the state after New and after a failed Init.
+	powerOff powerState = iota
+	// powerReady is the state after Init, the panel can be updated.
+	powerReady
+	// powerSleeping is the state after Sleep. Only a reset wakes the
+	// controller up, which restores the default of every register, so
+	// Init must run again.
+	powerSleeping
+)
+
+// String returns the name of the state.
+func (s powerState) String() string {
+	switch s {
+	case powerReady:
+		return "ready"
+	case powerSleeping:
+		return "sleeping"
+	default:
+		return "off"
+	}
+}
+
 const (
 	PANEL_SETTING                  byte = 0x00
 	POWER_SETTING                  byte = 0x01
@@ -69,34 +106,6 @@ const (
 	VCM_DC_SETTING                 byte = 0x82
 )
 
-var (
-	ColorBlack  = color.RGBA{0x00, 0x00, 0x00, 0xff}
-	ColorWhite  = color.RGBA{0xff, 0xff, 0xff, 0xff}
-	ColorYellow = color.RGBA{0xff, 0xff, 0x00, 0xff}
-	ColorRed    = color.RGBA{0xff, 0x00, 0x00, 0xff}
-	ColorBlue   = color.RGBA{0x00, 0x00, 0xff, 0xff}
-	ColorGreen  = color.RGBA{0x00, 0xff, 0x00, 0xff}
-)
-
-// ColorPalette with the 7 colors supported by the panel
-var ColorPalette = color.Palette{
-	ColorBlack,
-	ColorWhite,
-	ColorYellow,
-	ColorRed,
-	ColorBlue,
-	ColorGreen,
-}
-
-var ColorPaletteBinary = []uint8{
-	0x00, // BLACK
-	0x01, // WHITE
-	0x02, // YELLOW
-	0x03, // RED
-	0x05, // BLUE
-	0x06, // GREEN
-}
-
 // Epd is a handle to the display controller.
 type Epd struct {
 	c          conn.Conn
@@ -106,17 +115,19 @@ type Epd struct {
 	busy       gpio.PinIO
 	widthByte  int
 	heightByte int
-
-	black  int
-	white  int
-	yellow int
-	red    int
-	blue   int
-	green  int
+	log        *slog.Logger
+	// buf is the packed image, reused by every update.
+	buf []byte
+	// state tells whether the panel can be updated.
+	state powerState
+	// busyTimeout limits waitUntilIdle, it is shorter in the tests.
+	busyTimeout time.Duration
 }
 
-// New returns a Epd object that communicates over SPI to the display controller.
-func New(dcPin, csPin, rstPin, busyPin string) (*Epd, error) {
+// New returns a Epd object that communicates over SPI to the display controller
+// with a clock of at most speed.
+// The logger receives debug output of the driver, slog.Default() is used if nil.
+func New(dcPin, csPin, rstPin, busyPin string, speed physic.Frequency, logger *slog.Logger) (*Epd, error) {
 	if _, err := host.Init(); err != nil {
 		return nil, err
 	}
@@ -171,12 +182,26 @@ func New(dcPin, csPin, rstPin, busyPin string) (*Epd, error) {
 		return nil, err
 	}
 
-	c, err := port.Connect(5*physic.MegaHertz, spi.Mode0, 8)
+	c, err := port.Connect(speed, spi.Mode0, 8)
 	if err != nil {
 		port.Close()
 		return nil, err
 	}
 
+	e := NewWithInterfaces(c, dc, cs, rst, busy, logger)
+	e.log.Debug("connected to SPI port", "port", port.String(), "speed", speed.String())
+
+	return e, nil
+}
+
+// NewWithInterfaces returns a Epd object that uses already configured
+// connection and pins, e.g. fakes that record the transmitted bytes. Unlike
+// New, it doesn't initialize the periph host.
+func NewWithInterfaces(c conn.Conn, dc, cs, rst gpio.PinOut, busy gpio.PinIO, logger *slog.Logger) *Epd {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	var widthByte, heightByte int
 
 	if EPD_WIDTH%8 == 0 {
@@ -188,23 +213,18 @@ func New(dcPin, csPin, rstPin, busyPin string) (*Epd, error) {
 	heightByte = EPD_HEIGHT
 
 	e := &Epd{
-		c:          c,
-		dc:         dc,
-		cs:         cs,
-		rst:        rst,
-		busy:       busy,
-		widthByte:  widthByte,
-		heightByte: heightByte,
-
-		black:  0x000000,
-		white:  0xffffff,
-		yellow: 0x00ffff,
-		red:    0x0000ff,
-		blue:   0xff0000,
-		green:  0x00ff00,
+		c:           c,
+		dc:          dc,
+		cs:          cs,
+		rst:         rst,
+		busy:        busy,
+		widthByte:   widthByte,
+		heightByte:  heightByte,
+		log:         logger,
+		busyTimeout: busyTimeout,
 	}
 
-	return e, nil
+	return e
 }
 
 // Reset can be also used to awaken the device.
@@ -231,38 +251,59 @@ func (e *Epd) sendData(data ...byte) {
 	e.cs.Out(gpio.High)
 }
 
-func (e *Epd) waitUntilIdle() {
-	timeout := time.After(30 * time.Second)
+// waitUntilIdle blocks until the panel is idle. It is intentionally not
+// context-aware: an operation in progress must always be waited for.
+func (e *Epd) waitUntilIdle() error {
+	timeout := time.After(e.busyTimeout)
 	for {
 		select {
 		case <-timeout:
-			panic("epd: waitUntilIdle timed out")
+			return errBusyTimeout
 		default:
 			if e.busy.Read() != gpio.Low {
-				return
+				return nil
 			}
 			time.Sleep(100 * time.Millisecond)
 		}
 	}
 }
-func (e *Epd) turnOnDisplay() {
+
+// turnOnDisplay refreshes the panel with the transmitted data and powers it off.
+// Once started, the refresh always runs to completion.
+func (e *Epd) turnOnDisplay() error {
 	e.sendCommand(POWER_ON)
-	e.waitUntilIdle()
+	if err := e.waitUntilIdle(); err != nil {
+		return err
+	}
 
 	e.sendCommand(DISPLAY_REFRESH)
 	e.sendData(PANEL_SETTING)
-	e.waitUntilIdle()
+	refreshErr := e.waitUntilIdle()
+
+	// Power off even if the refresh timed out.
+	if err := e.powerOff(); err != nil {
+		return err
+	}
+
+	return refreshErr
+}
 
+// powerOff turns off the panel's power supply.
+func (e *Epd) powerOff() error {
 	e.sendCommand(POWER_OFF)
 	e.sendData(PANEL_SETTING)
-	e.waitUntilIdle()
+	return e.waitUntilIdle()
 }
 
 // Init initializes the display config.
-// It should be only used when you put the device to sleep and need to re-init the device.
-func (e *Epd) Init() {
+// It must be called before the first update and after every Sleep.
+func (e *Epd) Init() error {
+	e.state = powerOff
+
 	e.Reset()
-	e.waitUntilIdle()
+	if err := e.waitUntilIdle(); err != nil {
+		return err
+	}
 
 	time.Sleep(30 * time.Millisecond)
 
@@ -328,106 +369,146 @@ func (e *Epd) Init() {
 	e.sendData(0x2F)
 
 	e.sendCommand(POWER_ON)
-	e.waitUntilIdle()
+	if err := e.waitUntilIdle(); err != nil {
+		return err
+	}
+
+	e.state = powerReady
+
+	return nil
+}
+
+// Wake wakes the display up for an update. The controller only leaves deep
+// sleep by a hardware reset, which also clears every register, so a sleeping
+// display goes through the full sequence of Init. A display that is awake
+// already is left as it is.
+func (e *Epd) Wake() error {
+	if e.state == powerReady {
+		return nil
+	}
+	return e.Init()
+}
+
+// checkReady returns an error if the panel can't be updated in its state.
+// Data sent to a sleeping or uninitialized controller is lost silently.
+func (e *Epd) checkReady() error {
+	if e.state != powerReady {
+		return fmt.Errorf("%w: the display is %s", errNotReady, e.state)
+	}
+	return nil
 }
 
 // Clear clears the screen.
-func (e *Epd) Clear() {
+// If ctx is cancelled before the refresh started, the panel is powered off
+// without refreshing and the context's error is returned.
+func (e *Epd) Clear(ctx context.Context) error {
+	return e.ClearColor(ctx, ColorWhite)
+}
+
+// ClearColor fills the screen with c, which must be a color of ColorPalette.
+// If ctx is cancelled before the refresh started, the panel is powered off
+// without refreshing and the context's error is returned.
+func (e *Epd) ClearColor(ctx context.Context, c color.Color) error {
+	if err := e.checkReady(); err != nil {
+		return err
+	}
+
+	code := ColorPaletteBinary[ColorPalette.Index(c)]
+	fill := code<<4 | code
+
 	e.sendCommand(DATA_START_TRANSMISSION_1)
 
 	for j := 0; j < e.heightByte; j++ {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(err, e.powerOff())
+		}
+
 		for i := 0; i < e.widthByte; i++ {
 			for k := 0; k < 4; k++ {
-				e.sendData(0x11)
+				e.sendData(fill)
 			}
 		}
 	}
 
-	e.turnOnDisplay()
+	return e.turnOnDisplay()
 }
 
-// getBuffer converts an image to a byte buffer compatible with the 7-color display.
-func getBuffer(img image.Image) []byte {
-
-	// Check if we need to rotate the image
-	var imageTemp image.Image
-	if img.Bounds().Dx() == EPD_WIDTH && img.Bounds().Dy() == EPD_HEIGHT {
-		imageTemp = img
-	} else if img.Bounds().Dx() == EPD_HEIGHT && img.Bounds().Dy() == EPD_WIDTH {
-		imageTemp = rotateImage90(img)
-	} else {
-		fmt.Printf("Invalid image dimensions: %d x %d, expected %d x %d\n",
-			img.Bounds().Dx(), img.Bounds().Dy(), EPD_WIDTH, EPD_HEIGHT)
-		return nil
+// DeepClean reduces ghosting by driving every pixel through all colors:
+// the screen is filled black, white, with bars of every color and white
+// again. It takes several minutes, every step is a full refresh.
+func (e *Epd) DeepClean(ctx context.Context) error {
+	steps := []func() error{
+		func() error { return e.ClearColor(ctx, ColorBlack) },
+		func() error { return e.ClearColor(ctx, ColorWhite) },
+		func() error { return e.DisplayRaw(ctx, colorBars()) },
+		func() error { return e.ClearColor(ctx, ColorWhite) },
 	}
 
-	// Convert the source image to the 7 colors, dithering if needed
-	image7Color := quantizeImage(imageTemp, ColorPalette)
-
-	// Pack the 4 bits of color into a single byte to transfer to the panel
-	buf := make([]byte, EPD_WIDTH*EPD_HEIGHT/2)
-	idx := 0
-	for i := 0; i < len(image7Color.Pix); i += 2 {
-		col1 := ColorPaletteBinary[image7Color.Pix[i]]
-		col2 := ColorPaletteBinary[image7Color.Pix[i+1]]
-
-		buf[idx] = (col1 << 4) | col2
-		idx++
+	for i, step := range steps {
+		e.log.Debug("deep clean", "step", i+1, "of", len(steps))
+		if err := step(); err != nil {
+			return err
+		}
 	}
 
-	return buf
+	return nil
 }
 
-// rotateImage90 rotates an image 90 degrees clockwise.
-func rotateImage90(img image.Image) image.Image {
-	bounds := img.Bounds()
-	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dy(), bounds.Dx()))
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			dst.Set(bounds.Max.Y-y-1, x, img.At(x, y))
-		}
+// Display sends the image to the display.
+// If ctx is cancelled before the refresh started, the panel is powered off
+// without refreshing and the context's error is returned.
+func (e *Epd) Display(ctx context.Context, img image.Image) error {
+	if err := e.checkReady(); err != nil {
+		return err
 	}
-	return dst
-}
-
-// quantizeImage converts an image to a quantized version using the given palette.
-func quantizeImage(img image.Image, palette color.Palette) *image.Paletted {
-	bounds := img.Bounds()
-	quantized := image.NewPaletted(bounds, palette)
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			originalColor := img.At(x, y)
-			closestColor := palette.Convert(originalColor)
-			quantized.Set(x, y, closestColor)
-		}
+	// Convert the image to a byte buffer
+	start := time.Now()
+	if e.buf == nil {
+		e.buf = make([]byte, packedSize)
 	}
+	if err := packInto(e.buf, img, PackOptions{}); err != nil {
+		return fmt.Errorf("failed to convert image to buffer: %w", err)
+	}
+	e.log.Debug("converted image to buffer", "bytes", len(e.buf), "duration", time.Since(start))
 
-	return quantized
+	return e.DisplayRaw(ctx, e.buf)
 }
 
-// Display sends the image to the display.
-func (e *Epd) Display(img image.Image) {
-	e.sendCommand(DATA_START_TRANSMISSION_1)
-
-	// Convert the image to a byte buffer
-	buf := getBuffer(img)
-	if buf == nil {
-		fmt.Println("Failed to convert image to buffer")
-		return
+// DisplayRaw sends a buffer created by PackImage to the display, e.g. one
+// that was packed on another machine.
+// If ctx is cancelled before the refresh started, the panel is powered off
+// without refreshing and the context's error is returned.
+func (e *Epd) DisplayRaw(ctx context.Context, buf []byte) error {
+	if err := e.checkReady(); err != nil {
+		return err
+	}
+	if len(buf) != packedSize {
+		return fmt.Errorf("invalid buffer size: %d bytes, expected %d", len(buf), packedSize)
 	}
 
+	e.sendCommand(DATA_START_TRANSMISSION_1)
+
 	// Send the buffer to the display
+	rowBytes := EPD_WIDTH / 2
 	for i := 0; i < len(buf); i++ {
+		if i%rowBytes == 0 {
+			if err := ctx.Err(); err != nil {
+				return errors.Join(err, e.powerOff())
+			}
+		}
 		e.sendData(buf[i])
 	}
 
-	e.turnOnDisplay()
+	e.log.Debug("refreshing display")
+	return e.turnOnDisplay()
 }
 
 // Sleep puts the display in power-saving mode.
-// You can use Reset() to awaken and Init() to re-initialize the display.
+// Call Wake to wake it up, the reset it starts with is the only way out of
+// deep sleep and clears the register settings.
 func (e *Epd) Sleep() {
 	e.sendCommand(DEEP_SLEEP)
 	e.sendData(0xA5)
+	e.state = powerSleeping
 }
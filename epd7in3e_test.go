@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3"
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/physic"
+)
+
+// fakeTx is a command sent to the controller with the data that followed it.
+type fakeTx struct {
+	cmd  byte
+	data []byte
+}
+
+func (tx fakeTx) String() string {
+	return fmt.Sprintf("0x%02X % X", tx.cmd, tx.data)
+}
+
+// fakeBus records everything the driver sends: the commands with their
+// data, and every level a pin is set to.
+type fakeBus struct {
+	txs []fakeTx
+	// pins are the levels set with Out, by pin name.
+	pins map[string][]gpio.Level
+	// busy is the level read from the BUSY pin, gpio.High is idle.
+	busy gpio.Level
+	// dc is the level of the DC pin, which tells commands from data.
+	dc gpio.Level
+}
+
+// fakeConn is a conn.Conn that records the bytes written to the bus.
+type fakeConn struct {
+	bus *fakeBus
+}
+
+func (c *fakeConn) String() string { return "fake" }
+
+func (c *fakeConn) Duplex() conn.Duplex { return conn.Half }
+
+func (c *fakeConn) Tx(w, r []byte) error {
+	b := c.bus
+	if b.dc == gpio.Low {
+		for _, cmd := range w {
+			b.txs = append(b.txs, fakeTx{cmd: cmd})
+		}
+		return nil
+	}
+	if len(b.txs) == 0 {
+		return fmt.Errorf("data %X sent before a command", w)
+	}
+	last := &b.txs[len(b.txs)-1]
+	last.data = append(last.data, w...)
+	return nil
+}
+
+// fakePin is a gpio.PinIO that records the levels it is set to.
+type fakePin struct {
+	name string
+	bus  *fakeBus
+}
+
+func (p *fakePin) String() string                        { return p.name }
+func (p *fakePin) Halt() error                           { return nil }
+func (p *fakePin) Name() string                          { return p.name }
+func (p *fakePin) Number() int                           { return -1 }
+func (p *fakePin) Function() string                      { return "" }
+func (p *fakePin) In(gpio.Pull, gpio.Edge) error         { return nil }
+func (p *fakePin) WaitForEdge(time.Duration) bool        { return false }
+func (p *fakePin) Pull() gpio.Pull                       { return gpio.PullNoChange }
+func (p *fakePin) DefaultPull() gpio.Pull                { return gpio.PullNoChange }
+func (p *fakePin) PWM(gpio.Duty, physic.Frequency) error { return nil }
+
+func (p *fakePin) Read() gpio.Level {
+	return p.bus.busy
+}
+
+func (p *fakePin) Out(l gpio.Level) error {
+	if p.name == "DC" {
+		p.bus.dc = l
+	}
+	p.bus.pins[p.name] = append(p.bus.pins[p.name], l)
+	return nil
+}
+
+var _ gpio.PinIO = (*fakePin)(nil)
+
+// newFakeEpd returns a driver that talks to a fakeBus with an idle panel.
+func newFakeEpd() (*Epd, *fakeBus) {
+	bus := &fakeBus{pins: make(map[string][]gpio.Level), busy: gpio.High}
+	pin := func(name string) *fakePin { return &fakePin{name: name, bus: bus} }
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewWithInterfaces(&fakeConn{bus: bus}, pin("DC"), pin("CS"), pin("RST"), pin("BUSY"), logger), bus
+}
+
+// reset forgets everything recorded so far.
+func (b *fakeBus) reset() {
+	b.txs = nil
+	clear(b.pins)
+}
+
+func TestInitSequence(t *testing.T) {
+	e, bus := newFakeEpd()
+	if err := e.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []fakeTx{
+		{0xAA, []byte{0x49, 0x55, 0x20, 0x08, 0x09, 0x18}},
+		{POWER_SETTING, []byte{0x3F}},
+		{PANEL_SETTING, []byte{0x5F, 0x69}},
+		{POWER_OFF_SEQUENCE_SETTING, []byte{0x00, 0x54, 0x00, 0x44}},
+		{POWER_ON_MEASURE, []byte{0x40, 0x1F, 0x1F, 0x2C}},
+		{BOOSTER_SOFT_START, []byte{0x6F, 0x1F, 0x17, 0x49}},
+		{DEEP_SLEEP, []byte{0x6F, 0x1F, 0x1F, 0x22}},
+		{PLL_CONTROL, []byte{0x03}},
+		{VCOM_AND_DATA_INTERVAL_SETTING, []byte{0x3F}},
+		{TCON_SETTING, []byte{0x02, 0x00}},
+		{TCON_RESOLUTION, []byte{0x03, 0x20, 0x01, 0xE0}},
+		{AUTO_MEASUREMENT_VCOM, []byte{0x01}},
+		{VCM_DC_SETTING, []byte{0x2F}},
+		{POWER_ON, nil},
+	}
+	if len(bus.txs) != len(want) {
+		t.Fatalf("Init sent %d commands, want %d: %v", len(bus.txs), len(want), bus.txs)
+	}
+	for i := range want {
+		if bus.txs[i].cmd != want[i].cmd || !bytes.Equal(bus.txs[i].data, want[i].data) {
+			t.Errorf("command %d = %v, want %v", i, bus.txs[i], want[i])
+		}
+	}
+
+	// The hardware reset pulses RST low.
+	wantRST := []gpio.Level{gpio.High, gpio.Low, gpio.High}
+	if fmt.Sprint(bus.pins["RST"]) != fmt.Sprint(wantRST) {
+		t.Errorf("RST = %v, want %v", bus.pins["RST"], wantRST)
+	}
+}
+
+// checkRefresh checks that txs end with the refresh of the panel.
+func checkRefresh(t *testing.T, txs []fakeTx) {
+	t.Helper()
+
+	want := []fakeTx{
+		{POWER_ON, nil},
+		{DISPLAY_REFRESH, []byte{0x00}},
+		{POWER_OFF, []byte{0x00}},
+	}
+	if len(txs) != len(want) {
+		t.Fatalf("refresh sent %v, want %v", txs, want)
+	}
+	for i := range want {
+		if txs[i].cmd != want[i].cmd || !bytes.Equal(txs[i].data, want[i].data) {
+			t.Errorf("refresh command %d = %v, want %v", i, txs[i], want[i])
+		}
+	}
+}
+
+func TestClearBufferSize(t *testing.T) {
+	e, bus := newFakeEpd()
+	if err := e.Init(); err != nil {
+		t.Fatal(err)
+	}
+	bus.reset()
+
+	if err := e.Clear(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bus.txs) == 0 || bus.txs[0].cmd != DATA_START_TRANSMISSION_1 {
+		t.Fatalf("Clear didn't start with DATA_START_TRANSMISSION_1: %v", bus.txs)
+	}
+	data := bus.txs[0].data
+	if len(data) != packedSize {
+		t.Errorf("Clear sent %d bytes, want %d", len(data), packedSize)
+	}
+	for i, b := range data {
+		if b != 0x11 {
+			t.Errorf("byte %d is 0x%02X, want white 0x11", i, b)
+			break
+		}
+	}
+	checkRefresh(t, bus.txs[1:])
+}
+
+func TestDisplayFraming(t *testing.T) {
+	e, bus := newFakeEpd()
+	if err := e.Init(); err != nil {
+		t.Fatal(err)
+	}
+	bus.reset()
+
+	// The left half is red, the right half black.
+	img := image.NewRGBA(image.Rect(0, 0, EPD_WIDTH, EPD_HEIGHT))
+	draw.Draw(img, image.Rect(0, 0, EPD_WIDTH/2, EPD_HEIGHT), image.NewUniform(ColorRed), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(EPD_WIDTH/2, 0, EPD_WIDTH, EPD_HEIGHT), image.NewUniform(ColorBlack), image.Point{}, draw.Src)
+
+	if err := e.Display(context.Background(), img); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bus.txs) == 0 || bus.txs[0].cmd != DATA_START_TRANSMISSION_1 {
+		t.Fatalf("Display didn't start with DATA_START_TRANSMISSION_1: %v", bus.txs)
+	}
+	data := bus.txs[0].data
+	if len(data) != packedSize {
+		t.Fatalf("Display sent %d bytes, want %d", len(data), packedSize)
+	}
+	rowBytes := EPD_WIDTH / 2
+	for _, i := range []int{0, rowBytes/2 - 1, len(data) - rowBytes} {
+		if data[i] != 0x33 {
+			t.Errorf("byte %d is 0x%02X, want red 0x33", i, data[i])
+		}
+	}
+	for _, i := range []int{rowBytes / 2, rowBytes - 1, len(data) - 1} {
+		if data[i] != 0x00 {
+			t.Errorf("byte %d is 0x%02X, want black 0x00", i, data[i])
+		}
+	}
+	checkRefresh(t, bus.txs[1:])
+}
+
+func TestSleepPayload(t *testing.T) {
+	e, bus := newFakeEpd()
+	if err := e.Init(); err != nil {
+		t.Fatal(err)
+	}
+	bus.reset()
+
+	e.Sleep()
+
+	want := fakeTx{DEEP_SLEEP, []byte{0xA5}}
+	if len(bus.txs) != 1 || bus.txs[0].cmd != want.cmd || !bytes.Equal(bus.txs[0].data, want.data) {
+		t.Errorf("Sleep sent %v, want %v", bus.txs, want)
+	}
+}
+
+func TestPowerStateTransitions(t *testing.T) {
+	e, bus := newFakeEpd()
+	ctx := context.Background()
+	buf := make([]byte, packedSize)
+	img := image.NewRGBA(image.Rect(0, 0, EPD_WIDTH, EPD_HEIGHT))
+
+	updates := map[string]func() error{
+		"Clear":      func() error { return e.Clear(ctx) },
+		"Display":    func() error { return e.Display(ctx, img) },
+		"DisplayRaw": func() error { return e.DisplayRaw(ctx, buf) },
+		"DeepClean":  func() error { return e.DeepClean(ctx) },
+	}
+	// expectRefused checks that every update is refused without sending anything.
+	expectRefused := func(state string) {
+		t.Helper()
+		for name, update := range updates {
+			bus.reset()
+			err := update()
+			if !errors.Is(err, errNotReady) || !strings.Contains(err.Error(), "the display is "+state) {
+				t.Errorf("%s while %s: err = %v, want errNotReady", name, state, err)
+			}
+			if len(bus.txs) != 0 {
+				t.Errorf("%s while %s sent %v", name, state, bus.txs)
+			}
+		}
+	}
+	// expectAccepted checks that every update refreshes the panel.
+	expectAccepted := func() {
+		t.Helper()
+		for name, update := range updates {
+			if err := update(); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+
+	if e.state != powerOff {
+		t.Errorf("new driver is %s, want off", e.state)
+	}
+	expectRefused("off")
+
+	if err := e.Init(); err != nil {
+		t.Fatal(err)
+	}
+	expectAccepted()
+
+	// A cancelled update powers the panel off, but it stays initialized.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := e.Clear(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled Clear: err = %v", err)
+	}
+	if e.state != powerReady {
+		t.Errorf("after a cancelled update the display is %s, want ready", e.state)
+	}
+
+	e.Sleep()
+	expectRefused("sleeping")
+
+	// Wake wakes the controller up again with the reset and the full
+	// initialization, the register settings are lost in deep sleep.
+	bus.reset()
+	if err := e.Wake(); err != nil {
+		t.Fatal(err)
+	}
+	woken := bus.txs
+	bus.reset()
+	if err := e.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(woken, bus.txs, func(a, b fakeTx) bool { return a.cmd == b.cmd && bytes.Equal(a.data, b.data) }) {
+		t.Errorf("Wake sent %v, want the sequence of Init %v", woken, bus.txs)
+	}
+	expectAccepted()
+
+	// Waking up a display that is awake sends nothing.
+	bus.reset()
+	if err := e.Wake(); err != nil {
+		t.Fatal(err)
+	}
+	if len(bus.txs) != 0 {
+		t.Errorf("Wake of an awake display sent %v", bus.txs)
+	}
+
+	// A panel that stays busy fails Init and can't be updated.
+	e.busyTimeout = 50 * time.Millisecond
+	bus.busy = gpio.Low
+	if err := e.Init(); !errors.Is(err, errBusyTimeout) {
+		t.Errorf("Init of a busy panel: err = %v, want errBusyTimeout", err)
+	}
+	bus.busy = gpio.High
+	expectRefused("off")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// GenerateErrorScreen creates a minimal image that shows the date, a warning
+// sign and the error message. It is displayed if the dashboard could not be
+// generated, so the failure does not go unnoticed.
+func GenerateErrorScreen(renderErr error, config *DashboardConfig) (*gg.Context, error) {
+	if config == nil {
+		config = NewDefaultConfig()
+	}
+
+	dc := gg.NewContext(config.Width, config.Height)
+
+	// Background
+	dc.SetColor(color.White)
+	dc.DrawRectangle(0, 0, float64(config.Width), float64(config.Height))
+	dc.Fill()
+
+	// Frame
+	dc.SetColor(color.Black)
+	dc.DrawRectangle(
+		float64(config.Padding),
+		float64(config.Padding),
+		float64(config.Width-2*config.Padding),
+		float64(config.Height-2*config.Padding),
+	)
+	dc.SetLineWidth(2)
+	dc.Stroke()
+
+	// Heading
+	err := setFont(dc, FontBold, FontSizeS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set heading font: %w", err)
+	}
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(
+		localeDate(time.Now(), config.Locale, config.DateFormat),
+		float64(config.Width/2),
+		float64(config.Padding+32),
+		0.5, 0.5,
+	)
+
+	// Warning sign
+	offsetTop := 220.0
+	size := 160.0
+	centerX := float64(config.Width / 2)
+
+	dc.MoveTo(centerX, offsetTop-size/2)
+	dc.LineTo(centerX+size/2, offsetTop+size/2*0.8)
+	dc.LineTo(centerX-size/2, offsetTop+size/2*0.8)
+	dc.ClosePath()
+	dc.SetColor(ColorRed)
+	dc.Fill()
+
+	err = setFont(dc, FontBold, FontSizeL*2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set warning sign font: %w", err)
+	}
+	dc.SetColor(ColorWhite)
+	dc.DrawStringAnchored("!", centerX, offsetTop+size/2*0.8-12, 0.5, 0)
+
+	// Title
+	offsetTop += size/2 + 70
+
+	err = setFont(dc, FontBold, FontSizeM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set title font: %w", err)
+	}
+
+	title := "Dashboard konnte nicht aktualisiert werden"
+	if config.Locale == LocaleEnglish {
+		title = "Dashboard could not be updated"
+	}
+
+	dc.SetColor(color.Black)
+	dc.DrawStringWrapped(
+		title,
+		centerX,
+		offsetTop,
+		0.5, 0,
+		float64(config.Width-4*config.Padding),
+		1.3,
+		gg.AlignCenter,
+	)
+
+	// Error message
+	offsetTop += 100
+
+	err = setFont(dc, FontRegular, FontSizeSM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set error message font: %w", err)
+	}
+
+	dc.DrawStringWrapped(
+		renderErr.Error(),
+		float64(config.Padding*2),
+		offsetTop,
+		0, 0,
+		float64(config.Width-4*config.Padding),
+		1.5,
+		gg.AlignLeft,
+	)
+
+	// Time of the failure
+	err = setFont(dc, FontRegular, FontSizeXS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set footer font: %w", err)
+	}
+
+	dc.DrawStringAnchored(
+		config.TimeFormat.Clock(time.Now()),
+		float64(config.Width-config.Padding*2),
+		float64(config.Height-config.Padding*2),
+		1, 0,
+	)
+
+	return dc, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"net/http"
+	"strings"
+)
+
+// exchangeRatesEndpoint is the daily reference rates feed of the European
+// Central Bank. The rates are published around 16:00 CET on working days.
+var exchangeRatesEndpoint = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+
+// exchangeRateConfig is an entry of [[exchange_rates]] in the config.
+type exchangeRateConfig struct {
+	// Base is the currency the rates are quoted in, EUR if empty
+	Base string `toml:"base"`
+	// Targets are the currencies shown, e.g. ["USD", "GBP"]
+	Targets []string `toml:"targets"`
+}
+
+// exchangeRate is the price of one unit of Base in Currency.
+type exchangeRate struct {
+	Base     string
+	Currency string
+	Rate     float64
+}
+
+// ecbEnvelope is the structure of the ECB feed.
+type ecbEnvelope struct {
+	Cube struct {
+		Cube struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+// FetchExchangeRates fetches the current ECB reference rates and returns the
+// price of one unit of base in each of the targets. The ECB quotes all rates
+// in euros, other bases are converted through the euro.
+func FetchExchangeRates(ctx context.Context, client *http.Client, base string, targets []string) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, exchangeRatesEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch exchange rates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch exchange rates: unexpected status %s", resp.Status)
+	}
+
+	var envelope ecbEnvelope
+	if err = xml.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode exchange rates: %w", err)
+	}
+
+	euroRates := map[string]float64{"EUR": 1}
+	for _, rate := range envelope.Cube.Cube.Rates {
+		euroRates[rate.Currency] = rate.Rate
+	}
+
+	base = strings.ToUpper(base)
+	baseRate, ok := euroRates[base]
+	if !ok {
+		return nil, fmt.Errorf("unknown currency: %s", base)
+	}
+
+	rates := make(map[string]float64, len(targets))
+	for _, target := range targets {
+		target = strings.ToUpper(target)
+		targetRate, ok := euroRates[target]
+		if !ok {
+			return nil, fmt.Errorf("unknown currency: %s", target)
+		}
+		rates[target] = targetRate / baseRate
+	}
+
+	return rates, nil
+}
+
+// fetchConfiguredExchangeRates fetches the rates of all [[exchange_rates]]
+// entries in the order of the config.
+func fetchConfiguredExchangeRates(ctx context.Context, client *http.Client, entries []exchangeRateConfig) ([]exchangeRate, error) {
+	var result []exchangeRate
+	for _, entry := range entries {
+		base := strings.ToUpper(entry.Base)
+		if base == "" {
+			base = "EUR"
+		}
+
+		rates, err := FetchExchangeRates(ctx, client, base, entry.Targets)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, target := range entry.Targets {
+			target = strings.ToUpper(target)
+			result = append(result, exchangeRate{Base: base, Currency: target, Rate: rates[target]})
+		}
+	}
+	return result, nil
+}
+
+// exchangeRatesLabel formats the rates in a single row, e.g. "USD 1.08  GBP 0.86".
+func exchangeRatesLabel(rates []exchangeRate) string {
+	pairs := make([]string, 0, len(rates))
+	for _, rate := range rates {
+		pairs = append(pairs, fmt.Sprintf("%s %.2f", rate.Currency, rate.Rate))
+	}
+	return strings.Join(pairs, "  ")
+}
+
+// drawExchangeRates draws the rates in a single row in the top left corner of the frame.
+func drawExchangeRates(dc *dashboardCanvas, config *DashboardConfig) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return err
+	}
+
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(
+		exchangeRatesLabel(config.ExchangeRates),
+		float64(config.Padding+8),
+		float64(config.Padding+20),
+		0, 0,
+	)
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"image"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// placeholderRune replaces runes that neither the font nor the fallback
+// font contain (e.g., emoji if no fallback font is configured).
+const placeholderRune = '□'
+
+// fallbackFace is a font face that draws runes missing in its font with the
+// fallback face. Measuring and drawing go through the same lookup, so the
+// layout stays consistent. Without a fallback face, missing runes are drawn
+// as placeholderRune.
+type fallbackFace struct {
+	font.Face
+	font *opentype.Font
+
+	fallback     font.Face
+	fallbackFont *opentype.Font
+
+	buf sfnt.Buffer
+}
+
+// newFallbackFace wraps face, which was created from f. The fallback face
+// and its font may be nil.
+func newFallbackFace(face font.Face, f *opentype.Font, fallback font.Face, fallbackFont *opentype.Font) *fallbackFace {
+	return &fallbackFace{Face: face, font: f, fallback: fallback, fallbackFont: fallbackFont}
+}
+
+// resolve returns the face that draws r and the rune to draw. Invisible
+// formatting runes such as variation selectors and zero width joiners are
+// skipped (ok is false).
+func (f *fallbackFace) resolve(r rune) (face font.Face, glyph rune, ok bool) {
+	if f.has(f.font, r) {
+		return f.Face, r, true
+	}
+	if unicode.Is(unicode.Variation_Selector, r) || unicode.Is(unicode.Join_Control, r) {
+		return nil, r, false
+	}
+	if f.fallback != nil && f.has(f.fallbackFont, r) {
+		return f.fallback, r, true
+	}
+	return f.Face, placeholderRune, true
+}
+
+// has reports whether fnt contains a glyph for r.
+func (f *fallbackFace) has(fnt *opentype.Font, r rune) bool {
+	index, err := fnt.GlyphIndex(&f.buf, r)
+	return err == nil && index != 0
+}
+
+// Glyph implements font.Face.
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	face, glyph, ok := f.resolve(r)
+	if !ok {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+	return face.Glyph(dot, glyph)
+}
+
+// GlyphBounds implements font.Face.
+func (f *fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	face, glyph, ok := f.resolve(r)
+	if !ok {
+		return fixed.Rectangle26_6{}, 0, false
+	}
+	return face.GlyphBounds(glyph)
+}
+
+// GlyphAdvance implements font.Face.
+func (f *fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	face, glyph, ok := f.resolve(r)
+	if !ok {
+		return 0, false
+	}
+	return face.GlyphAdvance(glyph)
+}
+
+// Kern implements font.Face. Only pairs from the font itself are kerned.
+func (f *fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if !f.has(f.font, r0) || !f.has(f.font, r1) {
+		return 0
+	}
+	return f.Face.Kern(r0, r1)
+}
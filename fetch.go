@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ophusdev/openmeteogo"
+	"golang.org/x/sync/errgroup"
+)
+
+// fetchTimeout is the maximum time all remote sources may take together.
+const fetchTimeout = 60 * time.Second
+
+// dashboardData holds everything fetched from the remote sources.
+type dashboardData struct {
+	Appointments  []*Appointment
+	DailyWeather  *openmeteogo.DailyWeatherResponse
+	HourlyWeather *openmeteogo.HourlyWeatherResponse
+	Quote         quote
+	// AppointmentTotal is the number of appointments in the horizon,
+	// including the ones over the limit.
+	AppointmentTotal int
+	// QuoteErr is set if the quote could not be fetched. The dashboard
+	// is rendered without a quote in this case.
+	QuoteErr error
+	// Updated is the time the weather was fetched.
+	Updated time.Time
+	// Network is the status of the WiFi, nil if it is not shown or could not be read.
+	Network *NetworkStatus
+	// Steps is the step count of today, nil if it is not shown or could not be fetched.
+	Steps *stepCount
+	// Sleep is the most recent sleep, nil if it is not shown or could not be read.
+	Sleep *SleepSummary
+	// ExchangeRates are the configured exchange rates, empty if they could not be fetched.
+	ExchangeRates []exchangeRate
+	// Pollen are the pollen levels, empty if they are not shown or could not be fetched.
+	Pollen []PollenEntry
+	// Todos are the open tasks, empty if they are not shown or could not be fetched.
+	Todos []TodoItem
+	// Departures are the next departures from the transit stop, empty if
+	// they are not shown or could not be read. Some may have passed by the
+	// time they are shown.
+	Departures []Departure
+	// Word is the word of the day, nil if it is not shown or could not be fetched.
+	Word *WordEntry
+	// AirQuality is the current air quality, nil if it is not shown or could not be fetched.
+	AirQuality *AirQuality
+	// HistoricalWeather is the daily weather of the past days, nil if it is
+	// not shown or could not be fetched.
+	HistoricalWeather *openmeteogo.DailyWeatherResponse
+	// Locations are the daily forecasts of the other weather locations.
+	// Locations that could not be fetched are missing.
+	Locations []locationWeather
+}
+
+// locationWeather is the daily forecast of one of the other weather locations.
+type locationWeather struct {
+	Name  string
+	Daily *openmeteogo.DailyWeatherResponse
+}
+
+// weatherOptions returns the options shared by all weather requests. The
+// times of the responses are in timezone.
+func weatherOptions(timezone string) openmeteogo.Options {
+	return openmeteogo.Options{
+		Timezone:          openmeteogo.OpenMeteoConst(timezone),
+		TemperatureUnit:   openmeteogo.TemperatureUnitCelsius,
+		PrecipitationUnit: openmeteogo.PrecipitationUnitMm,
+		TimeFormat:        openmeteogo.TimeFormatIso8601,
+	}
+}
+
+// sourceFetchers are the functions fetchData fetches the calendars, the
+// weather and the quote with. The tests replace them.
+type sourceFetchers struct {
+	appointments  func(ctx context.Context, cfg config, client *http.Client, location *time.Location) ([]*Appointment, int, error)
+	dailyWeather  func(ctx context.Context, location weatherLocation, timezone string, client *http.Client) (*openmeteogo.DailyWeatherResponse, error)
+	hourlyWeather func(ctx context.Context, location weatherLocation, timezone string, client *http.Client) (*openmeteogo.HourlyWeatherResponse, error)
+	quote         func(ctx context.Context, cfg config, client *http.Client) (quote, error)
+}
+
+// defaultFetchers fetch the sources from their servers.
+var defaultFetchers = sourceFetchers{
+	appointments:  fetchAppointments,
+	dailyWeather:  fetchDailyWeather,
+	hourlyWeather: fetchHourlyWeather,
+	quote:         fetchNewQuote,
+}
+
+// fetchData fetches the calendars, the weather and the quote concurrently.
+// A failing calendar or weather source aborts the remaining fetches, a
+// failing quote does not.
+func fetchData(ctx context.Context, cfg config, location *time.Location) (*dashboardData, error) {
+	return defaultFetchers.fetchData(ctx, cfg, location)
+}
+
+// fetchData fetches the data with the fetchers f.
+func (f sourceFetchers) fetchData(ctx context.Context, cfg config, location *time.Location) (*dashboardData, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	client := buildHTTPClient(cfg)
+	defer client.CloseIdleConnections()
+
+	var data dashboardData
+	locations := cfg.WeatherLocations()
+	primary := locations[0]
+
+	g, gctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		defer logDuration("fetched calendars", time.Now())
+
+		appointments, total, err := f.appointments(gctx, cfg, client, location)
+		if err != nil {
+			return err
+		}
+		data.Appointments = appointments
+		data.AppointmentTotal = total
+		return nil
+	})
+
+	g.Go(func() error {
+		defer logDuration("fetched daily weather", time.Now())
+
+		dailyWeather, err := f.dailyWeather(gctx, primary, cfg.Timezone, client)
+		if err != nil {
+			return err
+		}
+		data.DailyWeather = dailyWeather
+		return nil
+	})
+
+	g.Go(func() error {
+		defer logDuration("fetched hourly weather", time.Now())
+
+		hourlyWeather, err := f.hourlyWeather(gctx, primary, cfg.Timezone, client)
+		if err != nil {
+			return err
+		}
+		data.HourlyWeather = hourlyWeather
+		return nil
+	})
+
+	// The other weather locations are optional.
+	others := make([]*openmeteogo.DailyWeatherResponse, len(locations)-1)
+	for i, place := range locations[1:] {
+		g.Go(func() error {
+			daily, err := f.dailyWeather(gctx, place, cfg.Timezone, client)
+			if err != nil {
+				slog.Warn("failed to fetch weather of location", "location", place.Name, "error", err)
+				return nil
+			}
+			others[i] = daily
+			return nil
+		})
+	}
+
+	// The quote is optional, so its error must not cancel the other sources.
+	// A pinned quote is not fetched at all.
+	if pinned, ok := cfg.PinnedQuote(); ok {
+		data.Quote = pinned
+	} else {
+		g.Go(func() error {
+			defer logDuration("fetched quote", time.Now())
+
+			data.Quote, data.QuoteErr = f.quote(gctx, cfg, client)
+			return nil
+		})
+	}
+
+	// The network status is optional as well.
+	if cfg.Layout.ShowNetworkStatus || cfg.Layout.ShowWiFiSignal {
+		g.Go(func() error {
+			status, err := FetchNetworkStatus(gctx)
+			if err != nil {
+				slog.Warn("failed to fetch network status", "error", err)
+				return nil
+			}
+			data.Network = &status
+			return nil
+		})
+	}
+
+	// The exchange rates are optional as well.
+	if len(cfg.ExchangeRates) > 0 {
+		g.Go(func() error {
+			rates, err := fetchConfiguredExchangeRates(gctx, client, cfg.ExchangeRates)
+			if err != nil {
+				slog.Warn("failed to fetch exchange rates", "error", err)
+				return nil
+			}
+			data.ExchangeRates = rates
+			return nil
+		})
+	}
+
+	// The pollen levels are optional as well.
+	if pollen, _ := cfg.PollenSource(client); pollen != nil {
+		g.Go(func() error {
+			entries, err := pollen.FetchPollenCount(gctx, primary.Latitude, primary.Longitude)
+			if err != nil {
+				slog.Warn("failed to fetch pollen", "error", err)
+				return nil
+			}
+			data.Pollen = entries
+			return nil
+		})
+	}
+
+	// The air quality is optional as well.
+	if cfg.Layout.ShowAQI {
+		g.Go(func() error {
+			quality, err := FetchAirQuality(gctx, client, primary.Latitude, primary.Longitude)
+			if err != nil {
+				slog.Warn("failed to fetch air quality", "error", err)
+				return nil
+			}
+			data.AirQuality = &quality
+			return nil
+		})
+	}
+
+	// The historical weather is optional as well, the forecast is shown without it.
+	if cfg.Weather.HistoricalDays > 0 {
+		g.Go(func() error {
+			history, err := FetchHistoricalWeather(gctx, cfg, client)
+			if err != nil {
+				slog.Warn("failed to fetch historical weather", "error", err)
+				return nil
+			}
+			data.HistoricalWeather = history
+			return nil
+		})
+	}
+
+	// The step count is optional as well.
+	if fitness, _ := cfg.FitnessSource(client); fitness != nil {
+		g.Go(func() error {
+			steps, goal, err := fitness.FetchStepCount(gctx, time.Now().In(location))
+			if err != nil {
+				slog.Warn("failed to fetch step count", "error", err)
+				return nil
+			}
+			data.Steps = &stepCount{Steps: steps, Goal: goal}
+			return nil
+		})
+	}
+
+	// The sleep is optional as well.
+	if sleep := cfg.SleepSource(); sleep != nil {
+		g.Go(func() error {
+			summary, err := sleep.FetchSleep(gctx)
+			if err != nil {
+				slog.Warn("failed to read sleep", "error", err)
+				return nil
+			}
+			data.Sleep = &summary
+			return nil
+		})
+	}
+
+	// The todos are optional as well.
+	if todos, _ := cfg.TodoSource(client, location); todos != nil {
+		g.Go(func() error {
+			items, err := todos.FetchTodos(gctx)
+			if err != nil {
+				slog.Warn("failed to fetch todos", "error", err)
+				return nil
+			}
+			data.Todos = items
+			return nil
+		})
+	}
+
+	// The departures are optional as well.
+	if transit := cfg.TransitSource(client, location); transit != nil {
+		g.Go(func() error {
+			departures, err := transit.FetchDepartures(gctx, time.Now(), cfg.MaxDepartures())
+			if err != nil {
+				slog.Warn("failed to read departures", "error", err)
+				return nil
+			}
+			data.Departures = departures
+			return nil
+		})
+	}
+
+	// The word of the day is optional as well.
+	if word, _ := cfg.WordSource(client, location); word != nil {
+		g.Go(func() error {
+			entry, err := word.FetchWord(gctx)
+			if err != nil {
+				slog.Warn("failed to fetch word of the day", "error", err)
+				return nil
+			}
+			data.Word = &entry
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	for i, daily := range others {
+		if daily != nil {
+			data.Locations = append(data.Locations, locationWeather{Name: locations[i+1].Name, Daily: daily})
+		}
+	}
+	data.Updated = time.Now()
+
+	return &data, nil
+}
+
+// fetchAppointments fetches the appointments of the configured calendars and
+// their total number.
+func fetchAppointments(ctx context.Context, cfg config, client *http.Client, location *time.Location) ([]*Appointment, int, error) {
+	appointments, total, err := buildAppointments(ctx, cfg.GetCalendars(client), cfg.AppointmentsUntil(time.Now().In(location)), location, cfg.PreferEventCategoryAsTag, cfg.AppointmentCount())
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build appointments: %w", err)
+	}
+
+	return appointments, total, nil
+}
+
+// fetchDailyWeather fetches the daily forecast for the next 8 days.
+func fetchDailyWeather(ctx context.Context, location weatherLocation, timezone string, httpClient *http.Client) (*openmeteogo.DailyWeatherResponse, error) {
+	client := openmeteogo.NewClient(httpClient)
+
+	dailyOpts := &openmeteogo.DailyOptions{
+		Latitude:     location.Latitude,
+		Longitude:    location.Longitude,
+		ForecastDays: 8,
+		Options:      weatherOptions(timezone),
+		Daily: &[]openmeteogo.OpenMeteoConst{
+			openmeteogo.DailyWeatherCode,
+			openmeteogo.DailyTemperature2mMax,
+			openmeteogo.DailyTemperature2mMin,
+			openmeteogo.DailySunrise,
+			openmeteogo.DailySunset,
+			openmeteogo.DailyPrecipitationSum,
+			openmeteogo.DailyPrecipitationProbabilityMax,
+		},
+	}
+
+	dailyWeather, err := client.DailyWeather.Forecast(ctx, dailyOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch daily weather: %w", err)
+	}
+
+	return dailyWeather, nil
+}
+
+// fetchHourlyWeather fetches the hourly forecast for today and tomorrow.
+func fetchHourlyWeather(ctx context.Context, location weatherLocation, timezone string, httpClient *http.Client) (*openmeteogo.HourlyWeatherResponse, error) {
+	client := openmeteogo.NewClient(httpClient)
+
+	hourlyOpts := &openmeteogo.HourlyOptions{
+		Latitude:     location.Latitude,
+		Longitude:    location.Longitude,
+		ForecastDays: 2,
+		Options:      weatherOptions(timezone),
+		Hourly: &[]openmeteogo.OpenMeteoConst{
+			openmeteogo.HourlyWeathercode,
+			openmeteogo.HourlyTemperature2m,
+			openmeteogo.HourlyPrecipitation,
+			openmeteogo.HourlyPrecipitationProbability,
+			openmeteogo.HourlySurfacePressure,
+		},
+	}
+
+	hourlyWeather, err := client.HourlyWeather.Forecast(ctx, hourlyOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch hourly weather: %w", err)
+	}
+
+	return hourlyWeather, nil
+}
+
+// buildHTTPClient returns the client of the remote sources. It uses the
+// proxy_url of the [network] table, or the proxy of the environment if
+// none is configured, and the TLS settings, timeout and User-Agent of the
+// table. All requests share its connections.
+func buildHTTPClient(cfg config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// The proxy and the TLS settings were validated at startup.
+	if proxyURL, _ := cfg.ProxyURL(); proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if tlsConfig, _ := cfg.TLSConfig(); tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	userAgent := cfg.Network.UserAgent
+	if userAgent == "" {
+		userAgent = "epd-dashboard/" + Version
+	}
+
+	return &http.Client{
+		Transport: &userAgentTransport{base: transport, userAgent: userAgent},
+		Timeout:   cfg.Network.Timeout.Or(defaultHTTPTimeout),
+	}
+}
+
+// defaultHTTPTimeout is the timeout of a single request if none is configured.
+const defaultHTTPTimeout = 30 * time.Second
+
+// userAgentTransport identifies the dashboard to the remote sources. The
+// User-Agent of a request is only set if it has none.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+// RoundTrip sets the User-Agent on a copy of req, a RoundTripper must not
+// modify the request.
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// logDuration logs the time elapsed since start. It is meant to be deferred.
+func logDuration(msg string, start time.Time) {
+	slog.Info(msg, "duration", time.Since(start))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ophusdev/openmeteogo"
+)
+
+func TestBuildHTTPClientUserAgent(t *testing.T) {
+	agents := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents <- r.Header.Get("User-Agent")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		configure string
+		header    string
+		want      string
+	}{
+		{name: "default", want: "epd-dashboard/" + Version},
+		{name: "configured", configure: "dashboard-at-home/1.0", want: "dashboard-at-home/1.0"},
+		{name: "set by the request", configure: "dashboard-at-home/1.0", header: "ical-client", want: "ical-client"},
+	}
+
+	for _, tt := range tests {
+		var cfg config
+		cfg.Network.UserAgent = tt.configure
+		client := buildHTTPClient(cfg)
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.header != "" {
+			req.Header.Set("User-Agent", tt.header)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		resp.Body.Close()
+
+		if got := <-agents; got != tt.want {
+			t.Errorf("%s: User-Agent = %q, want %q", tt.name, got, tt.want)
+		}
+		// The transport must not modify the request of the caller.
+		if got := req.Header.Get("User-Agent"); got != tt.header {
+			t.Errorf("%s: the request's User-Agent changed to %q", tt.name, got)
+		}
+	}
+}
+
+func TestBuildHTTPClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	var cfg config
+	cfg.Network.Timeout = tomlDuration{50 * time.Millisecond}
+	client := buildHTTPClient(cfg)
+
+	start := time.Now()
+	_, err := client.Get(srv.URL)
+	if err == nil {
+		t.Fatal("a request to a hanging server succeeded")
+	}
+	var netErr interface{ Timeout() bool }
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the request timed out after %v, want 50ms", elapsed)
+	}
+
+	// A context deadline before the timeout ends the request earlier.
+	cfg.Network.Timeout = tomlDuration{time.Minute}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = buildHTTPClient(cfg).Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline of the context", err)
+	}
+
+	// Without a configured timeout, the default is used.
+	if got := buildHTTPClient(config{}).Timeout; got != defaultHTTPTimeout {
+		t.Errorf("default timeout = %v, want %v", got, defaultHTTPTimeout)
+	}
+}
+
+// delayedFetchers returns fetchers that take the given time, or return
+// early if the context is cancelled.
+func delayedFetchers(appointments, daily, hourly, quoteDelay time.Duration) sourceFetchers {
+	return sourceFetchers{
+		appointments: func(ctx context.Context, cfg config, client *http.Client, location *time.Location) ([]*Appointment, int, error) {
+			return nil, 0, sleepContext(ctx, appointments)
+		},
+		dailyWeather: func(ctx context.Context, location weatherLocation, timezone string, client *http.Client) (*openmeteogo.DailyWeatherResponse, error) {
+			return &openmeteogo.DailyWeatherResponse{}, sleepContext(ctx, daily)
+		},
+		hourlyWeather: func(ctx context.Context, location weatherLocation, timezone string, client *http.Client) (*openmeteogo.HourlyWeatherResponse, error) {
+			return &openmeteogo.HourlyWeatherResponse{}, sleepContext(ctx, hourly)
+		},
+		quote: func(ctx context.Context, cfg config, client *http.Client) (quote, error) {
+			return quote{Text: "Quote"}, sleepContext(ctx, quoteDelay)
+		},
+	}
+}
+
+func TestFetchDataConcurrently(t *testing.T) {
+	var cfg config
+	cfg.Weather.Locations = []weatherLocation{{Name: "Home"}, {Name: "Office"}}
+	f := delayedFetchers(200*time.Millisecond, 150*time.Millisecond, 100*time.Millisecond, 300*time.Millisecond)
+
+	// One after the other, the sources would take 900ms.
+	start := time.Now()
+	data, err := f.fetchData(t.Context(), cfg, time.UTC)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed < 300*time.Millisecond || elapsed > 450*time.Millisecond {
+		t.Errorf("fetching took %v, want about the 300ms of the slowest source", elapsed)
+	}
+	if data.Quote.Text != "Quote" || len(data.Locations) != 1 {
+		t.Errorf("got quote %q and %d locations, want every source", data.Quote.Text, len(data.Locations))
+	}
+}
+
+func TestFetchDataAbortsOnWeatherError(t *testing.T) {
+	f := delayedFetchers(time.Second, time.Second, 0, time.Second)
+	f.hourlyWeather = func(ctx context.Context, location weatherLocation, timezone string, client *http.Client) (*openmeteogo.HourlyWeatherResponse, error) {
+		time.Sleep(50 * time.Millisecond)
+		return nil, errors.New("weather unavailable")
+	}
+
+	start := time.Now()
+	_, err := f.fetchData(t.Context(), config{}, time.UTC)
+	elapsed := time.Since(start)
+	if err == nil || err.Error() != "weather unavailable" {
+		t.Fatalf("got error %v, want the weather error", err)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("fetching took %v, want the other sources to be cancelled", elapsed)
+	}
+}
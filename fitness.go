@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FitnessSource provides the daily step count.
+type FitnessSource interface {
+	// FetchStepCount returns the steps of the date and the daily goal.
+	FetchStepCount(ctx context.Context, date time.Time) (steps, goal int, err error)
+}
+
+// fitbitEndpoint is the base URL of the Fitbit Web API.
+var fitbitEndpoint = "https://api.fitbit.com"
+
+// FitbitSource reads the step count from the Fitbit Web API.
+type FitbitSource struct {
+	// AccessToken is an OAuth 2.0 token with the activity scope
+	AccessToken string
+	// Client fetches the activity summary
+	Client *http.Client
+}
+
+// NewFitbitSource creates a Fitbit source authenticating with the access token.
+func NewFitbitSource(accessToken string, client *http.Client) *FitbitSource {
+	return &FitbitSource{AccessToken: accessToken, Client: client}
+}
+
+// fitbitActivityResponse is the part of the daily activity summary we use.
+type fitbitActivityResponse struct {
+	Goals struct {
+		Steps int `json:"steps"`
+	} `json:"goals"`
+	Summary struct {
+		Steps int `json:"steps"`
+	} `json:"summary"`
+}
+
+// FetchStepCount fetches the daily activity summary of the date.
+func (f *FitbitSource) FetchStepCount(ctx context.Context, date time.Time) (int, int, error) {
+	url := fmt.Sprintf("%s/1/user/-/activities/date/%s.json", fitbitEndpoint, date.Format(time.DateOnly))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+f.AccessToken)
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch step count: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("failed to fetch step count: unexpected status %s", resp.Status)
+	}
+
+	var response fitbitActivityResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode step count: %w", err)
+	}
+
+	return response.Summary.Steps, response.Goals.Steps, nil
+}
+
+// stepCount is the progress towards the daily step goal.
+type stepCount struct {
+	Steps int
+	Goal  int
+}
+
+// fitnessHeight is the height of the step count widget below the appointments.
+const fitnessHeight = 40
+
+// drawStepCount draws the progress towards the step goal as a bar with the
+// numbers below it, e.g. "6 234 / 10 000 Schritte".
+func drawStepCount(dc *dashboardCanvas, rect image.Rectangle, steps stepCount, locale Locale) error {
+	progress := 0.0
+	if steps.Goal > 0 {
+		progress = min(float64(steps.Steps)/float64(steps.Goal), 1)
+	}
+	drawProgressBar(dc, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+12), progress, ColorGreen)
+
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return err
+	}
+
+	label := fmt.Sprintf("%s / %s Schritte", groupDigits(steps.Steps, " "), groupDigits(steps.Goal, " "))
+	if locale == LocaleEnglish {
+		label = fmt.Sprintf("%s / %s steps", groupDigits(steps.Steps, ","), groupDigits(steps.Goal, ","))
+	}
+
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(label, float64(rect.Min.X+rect.Dx()/2), float64(rect.Max.Y), 0.5, 0)
+
+	return nil
+}
+
+// drawProgressBar draws a rounded bar into rect that is filled to progress (0 to 1) with fill.
+func drawProgressBar(dc *dashboardCanvas, rect image.Rectangle, progress float64, fill color.Color) {
+	x, y := float64(rect.Min.X), float64(rect.Min.Y)
+	w, h := float64(rect.Dx()), float64(rect.Dy())
+
+	if progress > 0 {
+		dc.SetColor(fill)
+		dc.DrawRoundedRectangle(x, y, max(w*progress, h), h, h/2)
+		dc.Fill()
+	}
+
+	dc.SetColor(color.Black)
+	dc.SetLineWidth(1.5)
+	dc.DrawRoundedRectangle(x, y, w, h, h/2)
+	dc.Stroke()
+}
+
+// groupDigits formats n with sep between groups of three digits.
+func groupDigits(n int, sep string) string {
+	digits := strconv.Itoa(n)
+	if n < 0 {
+		return "-" + groupDigits(-n, sep)
+	}
+
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(digit)
+	}
+	return b.String()
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+// GarbageSchedule is an entry of [[garbage_schedule]] in the config.
+type GarbageSchedule struct {
+	// Type is the displayed name, e.g. "Restmüll" or "Gelber Sack"
+	Type string `toml:"type"`
+	// Weekdays are the days of the pickup, e.g. ["Monday"]
+	Weekdays []tomlWeekday `toml:"weekdays"`
+	// Weeks limits the pickups to odd or even ISO weeks for bi-weekly pickups
+	Weeks garbageWeeks `toml:"weeks"`
+	// Color is the color of the dot shown if there is no icon
+	Color tomlColor `toml:"color"`
+	// Icon is the name of an icon in icons/garbage, e.g. "paper" for garbage/paper.png
+	Icon string `toml:"icon"`
+}
+
+// GarbageConfig are the pickup schedules of all waste types.
+type GarbageConfig []GarbageSchedule
+
+// GarbageEvent is a pickup of a waste type.
+type GarbageEvent struct {
+	Type  string
+	Date  time.Time
+	Color color.Color
+	Icon  string
+}
+
+// tomlWeekday is a weekday in the config, e.g. "Monday".
+type tomlWeekday struct {
+	weekday time.Weekday
+}
+
+// UnmarshalText parses an English weekday name.
+func (w *tomlWeekday) UnmarshalText(text []byte) error {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), string(text)) {
+			w.weekday = day
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid weekday: %s (expected e.g. Monday)", string(text))
+}
+
+// garbageWeeks selects the ISO weeks of a pickup.
+type garbageWeeks string
+
+const (
+	garbageWeeksAll  garbageWeeks = ""
+	garbageWeeksOdd  garbageWeeks = "odd"
+	garbageWeeksEven garbageWeeks = "even"
+)
+
+// UnmarshalText validates the weeks of a pickup.
+func (w *garbageWeeks) UnmarshalText(text []byte) error {
+	switch garbageWeeks(text) {
+	case garbageWeeksAll, garbageWeeksOdd, garbageWeeksEven:
+		*w = garbageWeeks(text)
+	default:
+		return fmt.Errorf("invalid garbage weeks: %s (expected odd or even)", string(text))
+	}
+	return nil
+}
+
+// matches reports whether the schedule has a pickup on the day of t.
+func (s GarbageSchedule) matches(t time.Time) bool {
+	if !slices.ContainsFunc(s.Weekdays, func(w tomlWeekday) bool { return w.weekday == t.Weekday() }) {
+		return false
+	}
+
+	_, week := t.ISOWeek()
+	switch s.Weeks {
+	case garbageWeeksOdd:
+		return week%2 == 1
+	case garbageWeeksEven:
+		return week%2 == 0
+	default:
+		return true
+	}
+}
+
+// garbageLookahead is the number of days NextGarbagePickups searches. Two
+// weeks cover every weekly and bi-weekly schedule.
+const garbageLookahead = 14
+
+// NextGarbagePickups returns the next n pickups from the day of now on,
+// sorted by date and in the order of the config on the same day.
+func NextGarbagePickups(cfg GarbageConfig, now time.Time, n int) []GarbageEvent {
+	var events []GarbageEvent
+	for day := range garbageLookahead {
+		date := time.Date(now.Year(), now.Month(), now.Day()+day, 0, 0, 0, 0, now.Location())
+		for _, schedule := range cfg {
+			if !schedule.matches(date) {
+				continue
+			}
+
+			events = append(events, GarbageEvent{
+				Type:  schedule.Type,
+				Date:  date,
+				Color: schedule.Color.color,
+				Icon:  schedule.Icon,
+			})
+			if len(events) == n {
+				return events
+			}
+		}
+	}
+	return events
+}
+
+// Layout of the garbage widget.
+const (
+	garbageRowHeight = 20
+	garbageIconSize  = 16
+)
+
+// dueGarbagePickups returns the pickups of today and tomorrow.
+func dueGarbagePickups(cfg GarbageConfig, now time.Time) []GarbageEvent {
+	var due []GarbageEvent
+	for _, event := range NextGarbagePickups(cfg, now, 2*len(cfg)) {
+		if daysUntil(now, event.Date) <= 1 {
+			due = append(due, event)
+		}
+	}
+	return due
+}
+
+// garbageHeight returns the height of the garbage widget with n pickups.
+func garbageHeight(n int) int {
+	return n * garbageRowHeight
+}
+
+// drawGarbagePickups draws a row per pickup into rect, e.g. "Morgen: Papier",
+// with the icon of the waste type or a dot in its color.
+func drawGarbagePickups(dc *dashboardCanvas, rect image.Rectangle, events []GarbageEvent, now time.Time, locale Locale) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXS)
+	if err != nil {
+		return err
+	}
+
+	for i, event := range events {
+		centerY := rect.Min.Y + i*garbageRowHeight + garbageRowHeight/2
+
+		err = drawGarbageIcon(dc, event, image.Point{X: rect.Min.X + garbageIconSize/2, Y: centerY})
+		if err != nil {
+			return err
+		}
+
+		day := "Heute"
+		switch {
+		case daysUntil(now, event.Date) > 0 && locale == LocaleEnglish:
+			day = "Tomorrow"
+		case daysUntil(now, event.Date) > 0:
+			day = "Morgen"
+		case locale == LocaleEnglish:
+			day = "Today"
+		}
+
+		dc.SetColor(color.Black)
+		dc.DrawStringAnchored(day+": "+event.Type, float64(rect.Min.X+garbageIconSize+8), float64(centerY), 0, 0.35)
+	}
+
+	return nil
+}
+
+// drawGarbageIcon draws the icon of the event centered at center, tinted in
+// its color if one is set, or a dot in its color if the icons contain none.
+func drawGarbageIcon(dc *dashboardCanvas, event GarbageEvent, center image.Point) error {
+	var tint color.Color
+	if event.Color != nil && event.Color != (color.RGBA{}) {
+		tint = event.Color
+	}
+
+	if event.Icon != "" {
+		err := addImage(dc, "garbage/"+event.Icon+".png", center, garbageIconSize, garbageIconSize, 0.5, 0.5, tint)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to draw garbage icon: %w", err)
+		}
+	}
+
+	if tint == nil {
+		tint = color.Black
+	}
+
+	dc.SetColor(tint)
+	dc.DrawCircle(float64(center.X), float64(center.Y), garbageIconSize/2-2)
+	dc.Fill()
+
+	return nil
+}
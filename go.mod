@@ -1,25 +1,32 @@
 module epd
 
-go 1.24
+go 1.24.0
 
 require (
-	golang.org/x/image v0.26.0 // indirect
+	golang.org/x/image v0.26.0
 	periph.io/x/host/v3 v3.8.5
 )
 
 require (
 	github.com/BurntSushi/toml v1.5.0
 	github.com/arran4/golang-ical v0.3.2
+	github.com/eclipse/paho.mqtt.golang v1.5.1
 	github.com/fogleman/gg v1.3.0
 	github.com/go-analyze/charts v0.5.21
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
-	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 	github.com/ophusdev/openmeteogo v0.3.0
+	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c
+	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef
+	github.com/teambition/rrule-go v1.8.2
+	golang.org/x/sync v0.17.0
 	periph.io/x/conn/v3 v3.7.2
 )
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/go-analyze/bulk v0.1.0 // indirect
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/google/go-querystring v1.1.0 // indirect
+	github.com/gorilla/websocket v1.5.3 // indirect
+	golang.org/x/net v0.44.0 // indirect
+	golang.org/x/text v0.29.0 // indirect
 )
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"archive/zip"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Departure is a departure of a bus or train from the stop.
+type Departure struct {
+	Time time.Time
+	// Route is the short name of the line, e.g. "S1"
+	Route    string
+	Headsign string
+}
+
+// defaultGTFSTTL is the age after which the GTFS feed is downloaded again.
+const defaultGTFSTTL = 24 * time.Hour
+
+// GTFSStaticSource reads the departures from a stop in the static schedule
+// of a GTFS feed (https://gtfs.org). The zip file is downloaded to
+// CacheFile and only downloaded again once it is older than TTL.
+type GTFSStaticSource struct {
+	// URL is the zip file of the feed
+	URL string
+	// StopID is the stop_id of the stop, departures of its platforms are included
+	StopID string
+	// CacheFile keeps the downloaded feed
+	CacheFile string
+	TTL       time.Duration
+	// Location is the timezone of the schedule
+	Location *time.Location
+	// Client downloads the feed
+	Client *http.Client
+
+	mu       sync.Mutex
+	schedule *gtfsSchedule
+	// modTime is the time of the cache file the schedule was read from.
+	modTime time.Time
+}
+
+// NewGTFSStaticSource creates a source of the departures from the stop.
+func NewGTFSStaticSource(url, stopID, cacheFile string, ttl time.Duration, location *time.Location, client *http.Client) *GTFSStaticSource {
+	return &GTFSStaticSource{URL: url, StopID: stopID, CacheFile: cacheFile, TTL: ttl, Location: location, Client: client}
+}
+
+// FetchDepartures returns the next n departures after now.
+func (s *GTFSStaticSource) FetchDepartures(ctx context.Context, now time.Time, n int) ([]Departure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.download(ctx, now); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(s.CacheFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GTFS feed: %w", err)
+	}
+	// The feed is only parsed again after it was downloaded again.
+	if s.schedule == nil || !info.ModTime().Equal(s.modTime) {
+		schedule, err := readGTFSSchedule(s.CacheFile, s.StopID)
+		if err != nil {
+			return nil, err
+		}
+		s.schedule, s.modTime = schedule, info.ModTime()
+	}
+
+	return s.schedule.next(now.In(s.Location), n), nil
+}
+
+// download downloads the feed unless the cache file is younger than the
+// TTL. If the download fails, an older cache file is used with a warning.
+func (s *GTFSStaticSource) download(ctx context.Context, now time.Time) error {
+	info, err := os.Stat(s.CacheFile)
+	if err == nil && now.Sub(info.ModTime()) < s.TTL {
+		return nil
+	}
+	cached := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read GTFS feed: %w", err)
+	}
+
+	err = s.downloadTo(ctx, s.CacheFile)
+	if err != nil && cached {
+		slog.Warn("failed to update GTFS feed, using the old one", "error", err)
+		return nil
+	}
+
+	return err
+}
+
+// downloadTo writes the feed to path. It is written to a temporary file
+// first, so an interrupted download doesn't replace the old feed.
+func (s *GTFSStaticSource) downloadTo(ctx context.Context, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download GTFS feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download GTFS feed: unexpected status %s", resp.Status)
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to write GTFS feed: %w", err)
+	}
+	_, err = io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to download GTFS feed: %w", err)
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write GTFS feed: %w", err)
+	}
+
+	return nil
+}
+
+// gtfsSchedule is the part of a GTFS feed with the departures from a stop.
+type gtfsSchedule struct {
+	// stopTimes are the departures from the stop as the time after the
+	// start of the service day, which may be later than 24:00.
+	stopTimes []gtfsStopTime
+	trips     map[string]gtfsTrip
+	// services are the weekly services of calendar.txt by service_id.
+	services map[string]gtfsService
+	// exceptions are the services added (true) or removed (false) on a
+	// date (YYYYMMDD) in calendar_dates.txt.
+	exceptions map[string]map[string]bool
+}
+
+type gtfsStopTime struct {
+	tripID    string
+	departure time.Duration
+}
+
+type gtfsTrip struct {
+	route     string
+	serviceID string
+	headsign  string
+}
+
+type gtfsService struct {
+	// weekdays are the days the service runs, starting with Sunday.
+	weekdays   [7]bool
+	start, end string
+}
+
+// active reports whether the service runs on the service day.
+func (s *gtfsSchedule) active(serviceID string, day time.Time) bool {
+	date := day.Format("20060102")
+	if active, ok := s.exceptions[date][serviceID]; ok {
+		return active
+	}
+
+	service, ok := s.services[serviceID]
+	return ok && service.weekdays[day.Weekday()] && service.start <= date && date <= service.end
+}
+
+// next returns the first n departures after now. Trips of the previous
+// service day that run after midnight are included.
+func (s *gtfsSchedule) next(now time.Time, n int) []Departure {
+	var departures []Departure
+	for offset := -1; offset <= 1; offset++ {
+		day := now.AddDate(0, 0, offset)
+		// GTFS times are counted from noon minus 12 hours, which differs
+		// from midnight on the days the clocks change.
+		start := time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, now.Location()).Add(-12 * time.Hour)
+
+		for _, stopTime := range s.stopTimes {
+			trip, ok := s.trips[stopTime.tripID]
+			if !ok || !s.active(trip.serviceID, day) {
+				continue
+			}
+
+			t := start.Add(stopTime.departure)
+			if t.Before(now) {
+				continue
+			}
+			departures = append(departures, Departure{Time: t, Route: trip.route, Headsign: trip.headsign})
+		}
+	}
+
+	slices.SortFunc(departures, func(a, b Departure) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	return departures[:min(len(departures), n)]
+}
+
+// readGTFSSchedule reads the departures from the stop and its platforms
+// from the GTFS zip file at path.
+func readGTFSSchedule(path, stopID string) (*gtfsSchedule, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GTFS feed: %w", err)
+	}
+	defer zr.Close()
+
+	schedule := &gtfsSchedule{
+		trips:      make(map[string]gtfsTrip),
+		services:   make(map[string]gtfsService),
+		exceptions: make(map[string]map[string]bool),
+	}
+
+	// Stations have the stop_times at their platforms.
+	stops := map[string]bool{stopID: true}
+	err = readGTFSFile(&zr.Reader, "stops.txt", false, func(get func(string) string) error {
+		if get("parent_station") == stopID {
+			stops[get("stop_id")] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	trips := make(map[string]bool)
+	err = readGTFSFile(&zr.Reader, "stop_times.txt", true, func(get func(string) string) error {
+		if !stops[get("stop_id")] {
+			return nil
+		}
+		departure := get("departure_time")
+		if departure == "" {
+			departure = get("arrival_time")
+		}
+		// Stops without a time are interpolated by the agency's software.
+		if departure == "" {
+			return nil
+		}
+		d, err := parseGTFSTime(departure)
+		if err != nil {
+			return err
+		}
+		schedule.stopTimes = append(schedule.stopTimes, gtfsStopTime{tripID: get("trip_id"), departure: d})
+		trips[get("trip_id")] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make(map[string]string)
+	err = readGTFSFile(&zr.Reader, "routes.txt", false, func(get func(string) string) error {
+		routes[get("route_id")] = cmp.Or(get("route_short_name"), get("route_long_name"))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&zr.Reader, "trips.txt", true, func(get func(string) string) error {
+		if trips[get("trip_id")] {
+			schedule.trips[get("trip_id")] = gtfsTrip{
+				route:     routes[get("route_id")],
+				serviceID: get("service_id"),
+				headsign:  get("trip_headsign"),
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A feed needs calendar.txt, calendar_dates.txt or both.
+	weekdays := [7]string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+	err = readGTFSFile(&zr.Reader, "calendar.txt", false, func(get func(string) string) error {
+		service := gtfsService{start: get("start_date"), end: get("end_date")}
+		for i, day := range weekdays {
+			service.weekdays[i] = get(day) == "1"
+		}
+		schedule.services[get("service_id")] = service
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&zr.Reader, "calendar_dates.txt", false, func(get func(string) string) error {
+		date := get("date")
+		if schedule.exceptions[date] == nil {
+			schedule.exceptions[date] = make(map[string]bool)
+		}
+		schedule.exceptions[date][get("service_id")] = get("exception_type") == "1"
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(schedule.stopTimes) == 0 {
+		return nil, fmt.Errorf("no departures from stop %s in the GTFS feed", stopID)
+	}
+
+	return schedule, nil
+}
+
+// readGTFSFile calls row for every row of the CSV file name in the zip
+// file. get returns the value of a column by its name, empty if the file
+// doesn't have it. A missing file is an error only if it is required.
+func readGTFSFile(zr *zip.Reader, name string, required bool, row func(get func(string) string) error) error {
+	f, err := zr.Open(name)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s of GTFS feed: %w", name, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.ReuseRecord = true
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read %s of GTFS feed: %w", name, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s of GTFS feed: %w", name, err)
+		}
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if err = row(get); err != nil {
+			return fmt.Errorf("invalid row in %s of GTFS feed: %w", name, err)
+		}
+	}
+}
+
+// parseGTFSTime parses a GTFS time like "25:10:00" as the time after the
+// start of the service day.
+func parseGTFSTime(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time: %s", s)
+	}
+
+	var values [3]int
+	for i, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("invalid time: %s", s)
+		}
+		values[i] = value
+	}
+
+	return time.Duration(values[0])*time.Hour + time.Duration(values[1])*time.Minute + time.Duration(values[2])*time.Second, nil
+}
+
+// upcomingDepartures returns the first n departures that haven't passed at now.
+func upcomingDepartures(departures []Departure, now time.Time, n int) []Departure {
+	var upcoming []Departure
+	for _, departure := range departures {
+		if len(upcoming) < n && !departure.Time.Before(now) {
+			upcoming = append(upcoming, departure)
+		}
+	}
+	return upcoming
+}
+
+// Layout of the departures widget.
+const (
+	departureRowHeight  = 22
+	departureTimeWidth  = 52
+	departureRouteWidth = 40.0
+)
+
+// departuresHeight returns the height of the departures widget with n departures.
+func departuresHeight(n int) int {
+	return n * departureRowHeight
+}
+
+// drawDepartures draws a row per departure into rect: the time, the line in
+// a badge like the tags of the appointments, the destination and the
+// minutes until the departure.
+func drawDepartures(dc *dashboardCanvas, rect image.Rectangle, departures []Departure, now time.Time, timeFormat TimeFormat) error {
+	for i, departure := range departures {
+		baseline := float64(rect.Min.Y + (i+1)*departureRowHeight - 6)
+		left := float64(rect.Min.X)
+
+		err := setFont(dc.Context, FontBold, FontSizeXXS)
+		if err != nil {
+			return err
+		}
+		dc.SetColor(color.Black)
+		dc.DrawStringAnchored(timeFormat.Clock(departure.Time), left, baseline, 0, 0)
+
+		err = setFont(dc.Context, FontBold, FontSizeXXXS)
+		if err != nil {
+			return err
+		}
+		routeLeft := left + departureTimeWidth
+		dc.SetColor(ColorBlue)
+		dc.DrawRoundedRectangle(routeLeft, baseline-14, departureRouteWidth, 18, 4)
+		dc.Fill()
+		dc.SetColor(ColorWhite)
+		dc.DrawStringAnchored(fitString(dc.Context, departure.Route, departureRouteWidth-4), routeLeft+departureRouteWidth/2, baseline-5, 0.5, 0.35)
+
+		err = setFont(dc.Context, FontRegular, FontSizeXXS)
+		if err != nil {
+			return err
+		}
+		minutes := fmt.Sprintf("%d min", max(int(departure.Time.Sub(now).Minutes()), 0))
+		minutesW, _ := dc.MeasureString(minutes)
+		headsignLeft := routeLeft + departureRouteWidth + 10
+
+		dc.SetColor(color.Black)
+		dc.DrawStringAnchored(fitString(dc.Context, departure.Headsign, float64(rect.Max.X)-headsignLeft-minutesW-10), headsignLeft, baseline, 0, 0)
+		dc.DrawStringAnchored(minutes, float64(rect.Max.X), baseline, 1, 0)
+	}
+
+	return nil
+}
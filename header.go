@@ -0,0 +1,83 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"time"
+)
+
+// Layout of the header.
+const (
+	// headerProgressWidth is the width of the day of year bar below the date
+	headerProgressWidth  = 140
+	headerProgressHeight = 3
+)
+
+// dayOfYearProgress returns the elapsed fraction of the year of t in whole
+// days, counting the day of t, so that December 31 is 1 in leap years as well.
+func dayOfYearProgress(t time.Time) float64 {
+	last := time.Date(t.Year(), time.December, 31, 0, 0, 0, 0, t.Location())
+	return float64(t.YearDay()) / float64(last.YearDay())
+}
+
+// renderHeader draws the weekday in red above the date, the day of year
+// bar below it and the clocks of the time zones. The day is that of now in
+// the dashboard's location.
+func renderHeader(dc *dashboardCanvas, config *DashboardConfig, now time.Time) error {
+	if config.Location != nil {
+		now = now.In(config.Location)
+	}
+
+	// The clocks below it need some room.
+	headingTop := config.Padding + 32
+	if len(config.Clocks) > 0 {
+		headingTop -= 6
+	}
+
+	// A date format with the weekday doesn't need it twice.
+	if !strings.Contains(config.DateFormat, "Monday") {
+		err := setFont(dc.Context, FontBold, FontSizeXXS)
+		if err != nil {
+			return err
+		}
+
+		weekday := days[now.Weekday()]
+		if config.Locale == LocaleEnglish {
+			weekday = now.Weekday().String()
+		}
+		dc.SetColor(ColorRed)
+		dc.DrawStringAnchored(weekday, float64(config.Width/2), float64(headingTop-14), 0.5, 0)
+	}
+
+	err := setFont(dc.Context, FontBold, FontSizeS)
+	if err != nil {
+		return err
+	}
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(
+		localeDate(now, config.Locale, config.DateFormat),
+		float64(config.Width/2),
+		float64(headingTop),
+		0.5, 0.5,
+	)
+
+	if config.ShowDayProgress {
+		bar := image.Rect(
+			config.Width/2-headerProgressWidth/2,
+			headingTop+17,
+			config.Width/2+headerProgressWidth/2,
+			headingTop+17+headerProgressHeight,
+		)
+		drawProgressBar(dc, bar, dayOfYearProgress(now), ColorRed)
+	}
+
+	if len(config.Clocks) > 0 {
+		err = drawClocks(dc, config, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
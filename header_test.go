@@ -0,0 +1,37 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDayOfYearProgress(t *testing.T) {
+	berlin := loadBerlin(t)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want float64
+	}{
+		{"January 1", time.Date(2025, time.January, 1, 0, 0, 0, 0, berlin), 1.0 / 365},
+		{"January 1 of a leap year", time.Date(2024, time.January, 1, 12, 0, 0, 0, berlin), 1.0 / 366},
+		{"February 29", time.Date(2024, time.February, 29, 12, 0, 0, 0, berlin), 60.0 / 366},
+		{"March 1 of a common year", time.Date(2025, time.March, 1, 12, 0, 0, 0, berlin), 60.0 / 365},
+		{"March 1 of a leap year", time.Date(2024, time.March, 1, 12, 0, 0, 0, berlin), 61.0 / 366},
+		{"December 30 of a leap year", time.Date(2024, time.December, 30, 12, 0, 0, 0, berlin), 365.0 / 366},
+		{"December 31", time.Date(2025, time.December, 31, 0, 0, 0, 0, berlin), 1},
+		{"end of December 31", time.Date(2025, time.December, 31, 23, 59, 59, 0, berlin), 1},
+		{"December 31 of a leap year", time.Date(2024, time.December, 31, 12, 0, 0, 0, berlin), 1},
+		{"December 31 of a century", time.Date(2100, time.December, 31, 12, 0, 0, 0, berlin), 1},
+		{"December 31 of 2000", time.Date(2000, time.December, 31, 12, 0, 0, 0, berlin), 1},
+		// The day is that of the location of t.
+		{"December 31 in UTC is January 1 in Berlin", time.Date(2024, time.December, 31, 23, 30, 0, 0, time.UTC).In(berlin), 1.0 / 365},
+	}
+
+	for _, tt := range tests {
+		if got := dayOfYearProgress(tt.t); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s: dayOfYearProgress = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net/http"
+
+	"github.com/ophusdev/openmeteogo"
+)
+
+// maxHistoricalDays is the number of past days that fit into the history chart.
+const maxHistoricalDays = 10
+
+// FetchHistoricalWeather fetches the daily weather of the last
+// historical_days days at the first weather location. Today is not included.
+func FetchHistoricalWeather(ctx context.Context, cfg config, httpClient *http.Client) (*openmeteogo.DailyWeatherResponse, error) {
+	primary := cfg.WeatherLocations()[0]
+	opts := &openmeteogo.DailyOptions{
+		Latitude:     primary.Latitude,
+		Longitude:    primary.Longitude,
+		ForecastDays: 0,
+		PastDays:     cfg.Weather.HistoricalDays,
+		Options:      weatherOptions(cfg.Timezone),
+		Daily: &[]openmeteogo.OpenMeteoConst{
+			openmeteogo.DailyWeatherCode,
+			openmeteogo.DailyTemperature2mMax,
+			openmeteogo.DailyTemperature2mMin,
+		},
+	}
+
+	response, err := openmeteogo.NewClient(httpClient).DailyWeather.Forecast(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch historical weather: %w", err)
+	}
+
+	return response, nil
+}
+
+// weatherCategoryColor returns the bar color of the weather code's category:
+// yellow for clear, black for clouds and fog, blue for rain, white for snow
+// and red for thunderstorms.
+func weatherCategoryColor(code *int32) color.Color {
+	if code == nil {
+		return color.Black
+	}
+
+	switch c := *code; {
+	case c <= 1:
+		return ColorYellow
+	case c <= 48:
+		return ColorBlack
+	case c >= 95:
+		return ColorRed
+	case c >= 71 && c <= 77, c == 85, c == 86:
+		return ColorWhite
+	default:
+		return ColorBlue
+	}
+}
+
+// Layout of the history chart.
+const (
+	// historyChartLabelWidth is the space left of the bars for the weekdays
+	historyChartLabelWidth = 36.0
+	// historyChartValueWidth is the space on each side of a bar for its temperature
+	historyChartValueWidth = 30.0
+)
+
+// renderHistoryChart draws a horizontal bar per day into rect, spanning from
+// the lowest to the highest temperature of the day on a scale shared by all
+// days. Days without temperatures are left empty.
+func renderHistoryChart(dc *dashboardCanvas, rect image.Rectangle, days WeatherForecast) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return fmt.Errorf("failed to set history chart font: %w", err)
+	}
+
+	low, high := days.MinTemp(), days.MaxTemp()
+	if low == nil || high == nil || len(days) == 0 {
+		return nil
+	}
+	// Keep single-temperature scales from dividing by zero.
+	scaleLow, scaleHigh := math.Floor(*low), math.Max(math.Ceil(*high), math.Floor(*low)+1)
+
+	left := float64(rect.Min.X) + historyChartLabelWidth + historyChartValueWidth
+	right := float64(rect.Max.X) - historyChartValueWidth
+	x := func(temperature float64) float64 {
+		return left + (temperature-scaleLow)/(scaleHigh-scaleLow)*(right-left)
+	}
+
+	rowHeight := float64(rect.Dy()) / float64(len(days))
+	barHeight := min(rowHeight*0.6, 14)
+
+	dc.SetLineWidth(1)
+	for i, day := range days {
+		center := float64(rect.Min.Y) + (float64(i)+0.5)*rowHeight
+
+		dc.SetColor(color.Black)
+		dc.DrawStringAnchored(day.Label, float64(rect.Min.X), center, 0, 0.35)
+
+		if day.TemperatureLow == nil || day.TemperatureHigh == nil {
+			continue
+		}
+		dayLow, dayHigh := x(*day.TemperatureLow), x(*day.TemperatureHigh)
+
+		// The outline keeps white and yellow bars visible.
+		dc.DrawRoundedRectangle(dayLow, center-barHeight/2, max(dayHigh-dayLow, barHeight), barHeight, barHeight/2)
+		dc.SetColor(weatherCategoryColor(day.WeatherCode))
+		dc.FillPreserve()
+		dc.SetColor(color.Black)
+		dc.Stroke()
+
+		dc.DrawStringAnchored(fmt.Sprintf("%d°", int(math.Round(*day.TemperatureLow))), dayLow-6, center, 1, 0.35)
+		dc.DrawStringAnchored(fmt.Sprintf("%d°", int(math.Round(*day.TemperatureHigh))), max(dayHigh, dayLow+barHeight)+6, center, 0, 0.35)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import "strings"
+
+// Sensors of the dashboard announced to Home Assistant by MQTTTrigger.
+const (
+	haSensorLastRefresh = "last_refresh"
+	haSensorLastError   = "last_error"
+	haSensorDisplay     = "display"
+)
+
+// haSensors are the sensors in the order they are announced.
+var haSensors = []string{haSensorLastRefresh, haSensorLastError, haSensorDisplay}
+
+// haNoError is the state of haSensorLastError after a successful refresh. An
+// empty retained message would delete the state on the broker instead.
+const haNoError = "none"
+
+// States of haSensorDisplay.
+const (
+	haDisplayOn       = "on"
+	haDisplaySleeping = "sleeping"
+)
+
+// haDiscoveryConfig is the config of a sensor of the Home Assistant MQTT
+// integration, published to its discovery topic.
+type haDiscoveryConfig struct {
+	Name        string   `json:"name"`
+	UniqueID    string   `json:"unique_id"`
+	ObjectID    string   `json:"object_id"`
+	StateTopic  string   `json:"state_topic"`
+	DeviceClass string   `json:"device_class,omitempty"`
+	Icon        string   `json:"icon,omitempty"`
+	Device      haDevice `json:"device"`
+}
+
+// haDevice groups the sensors of the dashboard into one device.
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+// nodeID returns the client ID as Home Assistant node ID, which may only
+// contain letters, digits, underscores and hyphens.
+func (t *MQTTTrigger) nodeID() string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, t.cfg.ClientID)
+}
+
+// discoveryTopic returns the topic of the discovery config of the sensor,
+// e.g. "homeassistant/sensor/epd-dashboard/display/config".
+func (t *MQTTTrigger) discoveryTopic(sensor string) string {
+	return t.cfg.DiscoveryPrefix + "/sensor/" + t.nodeID() + "/" + sensor + "/config"
+}
+
+// stateTopic returns the topic of the state of the sensor, e.g.
+// "epd-dashboard/display".
+func (t *MQTTTrigger) stateTopic(sensor string) string {
+	return t.nodeID() + "/" + sensor
+}
+
+// discoveryConfig returns the discovery config of the sensor.
+func (t *MQTTTrigger) discoveryConfig(sensor string) haDiscoveryConfig {
+	node := t.nodeID()
+
+	cfg := haDiscoveryConfig{
+		UniqueID:   node + "_" + sensor,
+		ObjectID:   node + "_" + sensor,
+		StateTopic: t.stateTopic(sensor),
+		Device: haDevice{
+			Identifiers:  []string{node},
+			Name:         "E-Ink Dashboard",
+			Manufacturer: "Waveshare",
+			Model:        "7.3\" e-Paper HAT (E)",
+			SWVersion:    Version,
+		},
+	}
+
+	switch sensor {
+	case haSensorLastRefresh:
+		cfg.Name = "Last refresh"
+		cfg.DeviceClass = "timestamp"
+	case haSensorLastError:
+		cfg.Name = "Last error"
+		cfg.Icon = "mdi:alert-circle-outline"
+	case haSensorDisplay:
+		cfg.Name = "Display"
+		cfg.Icon = "mdi:monitor"
+	}
+
+	return cfg
+}
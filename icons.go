@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// iconSource provides the icons by their path relative to the icons
+// directory (e.g., "weather/sun.png"). It defaults to the embedded icons
+// and is replaced by useIconsDir.
+var iconSource fs.FS = embeddedIcons()
+
+// embeddedIcons returns the icons embedded into the binary.
+func embeddedIcons() fs.FS {
+	icons, err := fs.Sub(iconsFS, "icons")
+	if err != nil {
+		panic(err) // "icons" is a valid path, so this cannot happen
+	}
+	return icons
+}
+
+// useIconsDir makes the icons in dir override the embedded ones.
+func useIconsDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open icons directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("failed to open icons directory: %s is not a directory", dir)
+	}
+
+	iconSource = newLayeredFS(os.DirFS(dir), embeddedIcons())
+
+	return nil
+}
+
+// weatherIconMappingPath is the file that replaces the icons of
+// weatherCodeInfo, e.g. for an icon theme with other file names.
+const weatherIconMappingPath = "weather/mapping.json"
+
+// useWeatherIconMapping replaces the icons of weatherCodeInfo with the
+// mapping file of the icons if there is one. The file is an object of icon
+// names to lists of weather codes, e.g. {"sunny": [0], "sunny-cloudy": [1, 2]}.
+func useWeatherIconMapping() error {
+	data, err := fs.ReadFile(iconSource, weatherIconMappingPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read weather icon mapping: %w", err)
+	}
+
+	var icons map[string][]int
+	if err = json.Unmarshal(data, &icons); err != nil {
+		return fmt.Errorf("failed to parse weather icon mapping: %w", err)
+	}
+
+	weatherIconsByCode = invertWeatherIcons(icons)
+
+	return nil
+}
+
+// layeredFS serves the files of override and falls back to base for
+// files that are missing in override.
+type layeredFS struct {
+	override fs.FS
+	base     fs.FS
+
+	mu     sync.Mutex
+	warned map[string]bool
+}
+
+// newLayeredFS creates a file system that prefers override over base.
+func newLayeredFS(override, base fs.FS) *layeredFS {
+	return &layeredFS{
+		override: override,
+		base:     base,
+		warned:   make(map[string]bool),
+	}
+}
+
+// Open opens the named file from override or, if it does not exist there,
+// from base. A missing override is logged only once per file, and only if
+// base has the file.
+func (l *layeredFS) Open(name string) (fs.File, error) {
+	f, err := l.override.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	f, err = l.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	if !l.warned[name] {
+		l.warned[name] = true
+		slog.Warn("icon not found in icons directory, using the embedded one", "icon", name)
+	}
+	l.mu.Unlock()
+
+	return f, nil
+}
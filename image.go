@@ -3,18 +3,23 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"io"
+	"io/fs"
+	"log/slog"
 	"math"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fogleman/gg"
 	"github.com/go-analyze/charts"
-	"github.com/golang/freetype/truetype"
-	"github.com/nfnt/resize"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
 )
 
 // fontName is the name of the font used in the dashboard image.
@@ -28,6 +33,10 @@ const (
 	FontRegular FontStyle = "SemiBold"
 	// FontBold represents the bold font style
 	FontBold FontStyle = "Bold"
+
+	// fontFallback is the optional font for runes missing in the others.
+	// It is not embedded and can only be loaded with useFontFiles.
+	fontFallback FontStyle = "Fallback"
 )
 
 // FontSize represents the size of a font in points
@@ -70,70 +79,170 @@ var days = [...]string{
 	"Samstag",
 }
 
-var weatherConditions = map[int]string{
-	0:  "Klarer Himmel",
-	1:  "Überwiegend klar",
-	2:  "Teilweise bewölkt",
-	3:  "Bedeckt",
-	45: "Nebel",
-	48: "Reif-Nebel",
-	51: "Leichter Nieselregen",
-	53: "Nieselregen",
-	55: "Starker Nieselregen",
-	56: "Leichter gefr. Nieselregen",
-	57: "Starker gefr. Nieselregen",
-	61: "Leichter Regen",
-	63: "Regen",
-	65: "Starker Regen",
-	66: "Leichter gefr. Regen",
-	67: "Leichter gefr. Regen",
-	71: "Leichter Schneefall",
-	73: "Schneefall",
-	75: "Starker Schneefall",
-	77: "Schneekörner",
-	80: "Leichter Regenschauer",
-	81: "Regenschauer",
-	82: "Starker Regenschauer",
-	85: "Leichter Schneeschauer",
-	86: "Starker Schneeschauer",
-	95: "Gewitter",
-	96: "Gewitter mit Hagel",
-	99: "Gewitter mit starkem Hagel",
-}
-
-var weatherIcons = map[string][]int{
-	"sunny":         {0},
-	"sunny-cloudy":  {1, 2},
-	"cloudy":        {3},
-	"foggy":         {45, 48},
-	"rainy-1":       {51, 61, 80},
-	"rainy-2":       {53, 63, 81},
-	"rainy-3":       {55, 65, 82},
-	"snow-and-rain": {56, 57, 66, 67},
-	"snowy-1":       {71, 85},
-	"snowy-2":       {73, 77},
-	"snowy-3":       {75, 86},
-	"stormy":        {95, 96, 99},
-}
-
-// localeDate formats a time.Time as a German date string (e.g., "1. Januar 2023")
-func localeDate(t time.Time) string {
-	return fmt.Sprintf("%d. %s %04d", t.Day(), months[t.Month()-1], t.Year())
+// weatherCode describes a WMO weather code returned by Open-Meteo.
+type weatherCode struct {
+	// Icon is the name of the icon in the weather directory, without ".png"
+	Icon        string
+	ConditionDE string
+	ConditionEN string
+}
+
+// weatherCodeInfo lists all WMO weather codes documented by Open-Meteo. Both
+// Icon and Condition read it, so the icon and the description of a code
+// can't get out of sync.
+var weatherCodeInfo = map[int]weatherCode{
+	0:  {"sunny", "Klarer Himmel", "Clear sky"},
+	1:  {"sunny-cloudy", "Überwiegend klar", "Mainly clear"},
+	2:  {"sunny-cloudy", "Teilweise bewölkt", "Partly cloudy"},
+	3:  {"cloudy", "Bedeckt", "Overcast"},
+	45: {"foggy", "Nebel", "Fog"},
+	48: {"foggy", "Reif-Nebel", "Rime fog"},
+	51: {"rainy-1", "Leichter Nieselregen", "Light drizzle"},
+	53: {"rainy-2", "Nieselregen", "Drizzle"},
+	55: {"rainy-3", "Starker Nieselregen", "Dense drizzle"},
+	56: {"snow-and-rain", "Leichter gefr. Nieselregen", "Light freezing drizzle"},
+	57: {"snow-and-rain", "Starker gefr. Nieselregen", "Dense freezing drizzle"},
+	61: {"rainy-1", "Leichter Regen", "Light rain"},
+	63: {"rainy-2", "Regen", "Rain"},
+	65: {"rainy-3", "Starker Regen", "Heavy rain"},
+	66: {"snow-and-rain", "Leichter gefr. Regen", "Light freezing rain"},
+	67: {"snow-and-rain", "Starker gefr. Regen", "Heavy freezing rain"},
+	71: {"snowy-1", "Leichter Schneefall", "Light snowfall"},
+	73: {"snowy-2", "Schneefall", "Snowfall"},
+	75: {"snowy-3", "Starker Schneefall", "Heavy snowfall"},
+	77: {"snowy-2", "Schneekörner", "Snow grains"},
+	80: {"rainy-1", "Leichter Regenschauer", "Light rain showers"},
+	81: {"rainy-2", "Regenschauer", "Rain showers"},
+	82: {"rainy-3", "Starker Regenschauer", "Violent rain showers"},
+	85: {"snowy-1", "Leichter Schneeschauer", "Light snow showers"},
+	86: {"snowy-3", "Starker Schneeschauer", "Heavy snow showers"},
+	95: {"stormy", "Gewitter", "Thunderstorm"},
+	96: {"stormy", "Gewitter mit Hagel", "Thunderstorm with hail"},
+	99: {"stormy", "Gewitter mit starkem Hagel", "Thunderstorm with heavy hail"},
+}
+
+// weatherDescriptions replace the descriptions of weatherCodeInfo in every
+// locale. They are read by useWeatherDescriptions.
+var weatherDescriptions = map[int]string{}
+
+// unknownConditions are shown for weather codes missing in weatherCodeInfo.
+var unknownConditions = map[Locale]string{
+	LocaleGerman:  "Unbekannt",
+	LocaleEnglish: "Unknown",
+}
+
+// warnedWeatherCodes are the unknown weather codes that were logged. The
+// dashboard is rendered every few minutes, so each code is logged once.
+var warnedWeatherCodes sync.Map
+
+// warnUnknownWeatherCode logs the first use of an unknown weather code.
+func warnUnknownWeatherCode(code int) {
+	if _, warned := warnedWeatherCodes.LoadOrStore(code, true); !warned {
+		slog.Warn("unknown weather code, using the fallback icon and condition", "code", code)
+	}
+}
+
+// weatherIconsByCode is the icon of every weather code. It defaults to the
+// icons of weatherCodeInfo and is replaced by useWeatherIconMapping if the
+// icons contain a mapping file.
+var weatherIconsByCode = defaultWeatherIcons()
+
+// defaultWeatherIcons returns the icons of weatherCodeInfo by code.
+func defaultWeatherIcons() map[int]string {
+	byCode := make(map[int]string, len(weatherCodeInfo))
+	for code, info := range weatherCodeInfo {
+		byCode[code] = info.Icon
+	}
+	return byCode
+}
+
+// invertWeatherIcons maps every weather code of icons to its icon.
+func invertWeatherIcons(icons map[string][]int) map[int]string {
+	byCode := make(map[int]string)
+	for icon, codes := range icons {
+		for _, code := range codes {
+			byCode[code] = icon
+		}
+	}
+	return byCode
+}
+
+// German short month names
+var shortMonths = [...]string{
+	"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez",
+}
+
+// localeDate formats a time.Time as a date string of the given locale.
+// If layout is empty, German dates look like "1. Januar 2023" and English dates like "January 1, 2023".
+// Otherwise layout is used as a Go time format (e.g., "02 January 2006"). In the German locale,
+// the month and day names of the layout are translated; English uses Go's built-in names.
+func localeDate(t time.Time, locale Locale, layout string) string {
+	if layout == "" {
+		if locale == LocaleEnglish {
+			return t.Format("January 2, 2006")
+		}
+		return fmt.Sprintf("%d. %s %04d", t.Day(), months[t.Month()-1], t.Year())
+	}
+
+	if locale == LocaleEnglish {
+		return t.Format(layout)
+	}
+
+	return formatGerman(t, layout)
+}
+
+// formatGerman formats t like t.Format, but with German month and day names.
+func formatGerman(t time.Time, layout string) string {
+	var sb strings.Builder
+
+	start := 0
+	for i := 0; i < len(layout); i++ {
+		var elem, value string
+		switch rest := layout[i:]; {
+		case strings.HasPrefix(rest, "January"):
+			elem, value = "January", months[t.Month()-1]
+		case strings.HasPrefix(rest, "Jan") && !startsWithLower(rest[3:]):
+			elem, value = "Jan", shortMonths[t.Month()-1]
+		case strings.HasPrefix(rest, "Monday"):
+			elem, value = "Monday", days[t.Weekday()]
+		case strings.HasPrefix(rest, "Mon") && !startsWithLower(rest[3:]):
+			elem, value = "Mon", LocaleGerman.WeekdayAbbreviations()[t.Weekday()]
+		default:
+			continue
+		}
+
+		// Format everything up to the name element with the regular layout.
+		sb.WriteString(t.Format(layout[start:i]))
+		sb.WriteString(value)
+
+		i += len(elem) - 1
+		start = i + 1
+	}
+	sb.WriteString(t.Format(layout[start:]))
+
+	return sb.String()
+}
+
+// startsWithLower reports whether s starts with a lower-case letter.
+// The time package does not treat "Jan" and "Mon" as names in that case (e.g., "Month").
+func startsWithLower(s string) bool {
+	return len(s) > 0 && 'a' <= s[0] && s[0] <= 'z'
 }
 
 // relativeDate formats a time.Time as a relative date string in German
 // If the date is today, it returns just the time (e.g., "15:04")
 // If the date is tomorrow, it returns "Morgen, 15:04"
 // Otherwise, it returns the day of the week and time (e.g., "Montag, 15:04")
-func relativeDate(t time.Time) string {
-	now := time.Now()
-	dayDiff := t.Sub(now).Hours() / 24
+// The days are counted from now, the time of day is formatted according
+// to timeFormat.
+func relativeDate(now, t time.Time, timeFormat TimeFormat) string {
+	dayDiff := daysUntil(now, t)
 	if dayDiff == 0 {
-		return t.Format("15:04")
+		return timeFormat.Clock(t)
 	}
 
 	if dayDiff == 1 {
-		return "Morgen, " + t.Format("15:04")
+		return "Morgen, " + timeFormat.Clock(t)
 	}
 
 	// All-day events.
@@ -141,7 +250,7 @@ func relativeDate(t time.Time) string {
 		return fmt.Sprintf("%s", days[t.Weekday()])
 	}
 
-	return fmt.Sprintf("%s, %s", days[t.Weekday()], t.Format("15:04"))
+	return fmt.Sprintf("%s, %s", days[t.Weekday()], timeFormat.Clock(t))
 }
 
 // Appointment represents a calendar appointment with a title and start time
@@ -164,8 +273,18 @@ const (
 	DefaultHeight = 800
 	// DefaultPadding is the default padding around elements in pixels
 	DefaultPadding = 20
+	// footerTop is the position of the quote footer
+	footerTop = 630
+	// defaultAppointmentTitleMaxChars is the default length of the appointment titles in the list
+	defaultAppointmentTitleMaxChars = 25
 )
 
+// appointmentTitleLines is the number of lines of a wrapped appointment title.
+const appointmentTitleLines = 2
+
+// forecastItems is the number of hours or days shown by the forecast graph.
+const forecastItems = 7
+
 // DashboardConfig holds configuration options for the dashboard
 type DashboardConfig struct {
 	// Width is the width of the dashboard in pixels
@@ -174,14 +293,144 @@ type DashboardConfig struct {
 	Height int
 	// Padding is the padding around elements in pixels
 	Padding int
+	// Locale is the language of the dashboard
+	Locale Locale
+	// Location is the configured timezone. The weather times are in it.
+	Location *time.Location
+	// DateFormat is a Go time format for the date heading, see localeDate
+	DateFormat string
+	// TimeFormat selects the 24-hour or 12-hour clock for displayed times
+	TimeFormat TimeFormat
+	// WeekdayAbbreviations are the short weekday names, starting with Sunday
+	WeekdayAbbreviations [7]string
+	// ShowVersion renders the version label even for development builds
+	ShowVersion bool
+	// AppointmentView selects between the list and the week grid
+	AppointmentView AppointmentView
+	// EmptyAppointments selects what the list shows without appointments
+	EmptyAppointments EmptyAppointments
+	// ShowEventCountdown shows the time until the next appointment and the
+	// appointments of the next hours below their date
+	ShowEventCountdown bool
+	// WrapAppointmentTitles wraps long titles in the list onto a second line
+	// instead of cutting them off after AppointmentTitleMaxChars
+	WrapAppointmentTitles bool
+	// AppointmentTitleMaxChars is the number of characters after which
+	// appointment titles in the list are cut off (default 25)
+	AppointmentTitleMaxChars int
+	// QuoteMaxChars is the number of characters after which the quote is cut off, 0 for no limit
+	QuoteMaxChars int
+	// ShowMiniMonth draws a calendar of the current month below the list
+	ShowMiniMonth bool
+	// RainChart replaces the forecast graph with the chance of rain of the next hours
+	RainChart bool
+	// HistoricalDays replaces the forecast graph with the temperature ranges
+	// of the past days
+	HistoricalDays int
+	// History are the past days, the forecast is shown if it is empty
+	History WeatherForecast
+	// ShowPressure draws the surface pressure and its trend below the sunrise and sunset
+	ShowPressure bool
+	// ShowAQI draws a badge with the air quality below the sunrise and sunset
+	ShowAQI bool
+	// AirQuality is the current air quality, nil if it could not be fetched
+	AirQuality *AirQuality
+	// SeparatorStyle selects how the lines between the sections are drawn
+	SeparatorStyle SeparatorStyle
+	// DebugOverlay outlines every string, image and section to check the
+	// layout. It is meant for development only.
+	DebugOverlay bool
+	// ShowNetworkStatus draws the SSID and signal of the WiFi in the top right corner
+	ShowNetworkStatus bool
+	// ShowWiFiSignal draws only the signal of the WiFi in the top right corner
+	ShowWiFiSignal bool
+	// Network is the status of the WiFi, nil if it could not be read
+	Network *NetworkStatus
+	// Steps is the progress towards the daily step goal, nil to hide it
+	Steps *stepCount
+	// Sleep is the last night's sleep, nil to hide it
+	Sleep *SleepSummary
+	// ExchangeRates are shown in the top left corner if set
+	ExchangeRates []exchangeRate
+	// Pollen are the pollen levels shown below the appointments, empty to hide them
+	Pollen []PollenEntry
+	// Todos are the open tasks shown below the appointments in this order, empty to hide them
+	Todos []TodoItem
+	// MaxTodos is the number of tasks shown
+	MaxTodos int
+	// Departures are the next departures from the transit stop, empty to hide them
+	Departures []Departure
+	// MaxDepartures is the number of departures shown
+	MaxDepartures int
+	// Word is the word of the day shown below the appointments, nil to hide it
+	Word *WordEntry
+	// WordReplacesQuote shows the word in the footer instead of the quote
+	WordReplacesQuote bool
+	// ShowYearProgress draws the elapsed part of the year below the appointments
+	ShowYearProgress bool
+	// ShowMonthProgress adds the elapsed part of the month to the year progress
+	ShowMonthProgress bool
+	// Grayscale dithers the image sent to the panel to black and white with
+	// a Bayer matrix of DitherSize
+	Grayscale  bool
+	DitherSize int
+	// MedicationSchedule are the medications from the config, the taken
+	// doses are read from MedicationTakenFile
+	MedicationSchedule  []MedicationConfig
+	MedicationLookahead time.Duration
+	MedicationTakenFile string
+	// PhotoDir is a directory of PNG and JPEG files, one of them is shown
+	// each day in PhotoRect. Empty to show none.
+	PhotoDir string
+	// PhotoRect is the area of the photo, the whole frame if it is empty
+	PhotoRect image.Rectangle
+	// ShowDayProgress draws the elapsed days of the year as a bar below the date
+	ShowDayProgress bool
+	// Clocks are the time zones shown below the heading
+	Clocks []Clock
+	// GarbageSchedule are the waste pickups from the config
+	GarbageSchedule GarbageConfig
+	// Medications are the doses of today and the lookahead shown below the appointments
+	Medications []MedicationDose
+	// Garbage are the pickups of today and tomorrow shown below the appointments
+	Garbage []GarbageEvent
+	// ShowRefreshTime renders the time of the update in the footer
+	ShowRefreshTime bool
+	// RefreshInterval is the expected time between two updates. The refresh
+	// time turns red if the data is older than twice the interval.
+	RefreshInterval time.Duration
+	// DataUpdated is the time the data was fetched
+	DataUpdated time.Time
+	// Now is the time the dashboard is drawn for, the current time if zero
+	Now time.Time
+	// OutputPath is the file the dashboard is written to, "-" for standard output
+	OutputPath string
+	// OutputHistory is the number of previous renders kept next to OutputPath
+	OutputHistory int
+	// ArchiveDir receives a timestamped copy of every rendered dashboard if set
+	ArchiveDir string
+	// ArchiveMaxAgeDays is the number of days archived images are kept (default 30)
+	ArchiveMaxAgeDays int
 	// Temperature is the temperature range to display
 	Temperature string
 	// Appointments is the list of appointments to display
 	Appointments []*Appointment
+	// AppointmentTotal is the number of appointments including the ones that
+	// were cut off, the list shows how many are hidden
+	AppointmentTotal int
 	// Quote is the quote of the day to display
 	Quote           quote
 	Weather         Weather
 	WeatherForecast WeatherForecast
+	// Locations are the other weather locations, shown with a line each
+	// below the weather
+	Locations []LocationWeather
+}
+
+// LocationWeather is today's weather at one of the other weather locations.
+type LocationWeather struct {
+	Name    string
+	Weather Weather
 }
 
 // Weather represents the weather data structure
@@ -195,40 +444,114 @@ type Weather struct {
 	Sunset                   time.Time
 	PrecipitationSum         *float64
 	PrecipitationProbability *float64
+	// Pressure is the surface pressure in hPa
+	Pressure *float64
+	// PressureChange is the change of the pressure in the last hours, nil if unknown
+	PressureChange *float64
 }
 
+// WeatherForecast is a list of hours or days, sorted by Timestamp.
 type WeatherForecast []Weather
 
+// MinTemp returns the lowest temperature of the forecast, nil if it has none.
+func (f WeatherForecast) MinTemp() *float64 {
+	var result *float64
+	for _, w := range f {
+		if w.TemperatureLow != nil && (result == nil || *w.TemperatureLow < *result) {
+			result = w.TemperatureLow
+		}
+	}
+	return result
+}
+
+// MaxTemp returns the highest temperature of the forecast, nil if it has none.
+func (f WeatherForecast) MaxTemp() *float64 {
+	var result *float64
+	for _, w := range f {
+		if w.TemperatureHigh != nil && (result == nil || *w.TemperatureHigh > *result) {
+			result = w.TemperatureHigh
+		}
+	}
+	return result
+}
+
+// TotalPrecipitation returns the sum of the precipitation of the forecast in mm.
+func (f WeatherForecast) TotalPrecipitation() float64 {
+	var total float64
+	for _, w := range f {
+		if w.PrecipitationSum != nil {
+			total += *w.PrecipitationSum
+		}
+	}
+	return total
+}
+
+// Icon returns the path of the icon of the weather code. Codes missing in
+// weatherIconsByCode use "weather/code-<code>.png" if the icons contain it, so an
+// icons directory can add them. Otherwise, and if the code is missing, the
+// unknown icon is used.
 func (w Weather) Icon() string {
 	if w.WeatherCode == nil {
-		return ""
+		return "weather/unknown.png"
 	}
-	for icon, codes := range weatherIcons {
-		for _, code := range codes {
-			if int(*w.WeatherCode) == code {
-				return fmt.Sprintf("icons/weather/%s.png", icon)
-			}
-		}
+
+	code := int(*w.WeatherCode)
+	if icon, ok := weatherIconsByCode[code]; ok {
+		return fmt.Sprintf("weather/%s.png", icon)
+	}
+
+	path := fmt.Sprintf("weather/code-%d.png", code)
+	f, err := iconSource.Open(path)
+	switch {
+	case err == nil:
+		f.Close()
+		return path
+	case !errors.Is(err, fs.ErrNotExist):
+		slog.Warn("failed to open weather icon", "icon", path, "error", err)
 	}
-	return "icons/weather/unknown.png"
+
+	warnUnknownWeatherCode(code)
+	return "weather/unknown.png"
 }
 
-func (w Weather) Condition() string {
+// Condition returns the description of the weather code in the locale,
+// the unknown condition if the code is missing.
+func (w Weather) Condition(locale Locale) string {
 	if w.WeatherCode == nil {
-		return ""
+		return unknownConditions[locale]
+	}
+
+	code := int(*w.WeatherCode)
+	if description, ok := weatherDescriptions[code]; ok {
+		return description
 	}
-	return weatherConditions[int(*w.WeatherCode)]
+	info, ok := weatherCodeInfo[code]
+	if !ok {
+		warnUnknownWeatherCode(code)
+		return unknownConditions[locale]
+	}
+	if locale == LocaleEnglish {
+		return info.ConditionEN
+	}
+	return info.ConditionDE
 }
 
 // NewDefaultConfig creates a new DashboardConfig with default values
 func NewDefaultConfig() *DashboardConfig {
 	return &DashboardConfig{
-		Width:        DefaultWidth,
-		Height:       DefaultHeight,
-		Padding:      DefaultPadding,
-		Appointments: []*Appointment{},
-		Quote:        quote{},
-		Weather:      Weather{},
+		Width:                    DefaultWidth,
+		Height:                   DefaultHeight,
+		Padding:                  DefaultPadding,
+		Locale:                   LocaleGerman,
+		TimeFormat:               TimeFormat24h,
+		WeekdayAbbreviations:     LocaleGerman.WeekdayAbbreviations(),
+		SeparatorStyle:           SeparatorSolid,
+		AppointmentTitleMaxChars: defaultAppointmentTitleMaxChars,
+		Appointments:             []*Appointment{},
+		Quote:                    quote{},
+		Weather:                  Weather{},
+		Location:                 time.Local,
+		ShowDayProgress:          true,
 	}
 }
 
@@ -239,9 +562,17 @@ func GenerateDashboard(config *DashboardConfig) (*gg.Context, error) {
 		config = NewDefaultConfig()
 	}
 
-	dc := gg.NewContext(config.Width, config.Height)
+	// The dates and times of every widget are in the dashboard's location.
+	now := config.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if config.Location != nil {
+		now = now.In(config.Location)
+	}
+	dc := newDashboardCanvas(config.Width, config.Height, config.DebugOverlay)
 
-	err := setFont(dc, FontRegular, FontSizeSM)
+	err := setFont(dc.Context, FontRegular, FontSizeSM)
 	if err != nil {
 		return nil, fmt.Errorf("failed to set initial font: %w", err)
 	}
@@ -263,19 +594,29 @@ func GenerateDashboard(config *DashboardConfig) (*gg.Context, error) {
 	dc.Stroke()
 
 	// Heading
-	err = setFont(dc, FontBold, FontSizeS)
+	err = renderHeader(dc, config, now)
 	if err != nil {
-		return nil, fmt.Errorf("failed to set heading font: %w", err)
+		return nil, fmt.Errorf("failed to draw header: %w", err)
+	}
+
+	// Network status
+	if (config.ShowNetworkStatus || config.ShowWiFiSignal) && config.Network != nil {
+		err = drawNetworkStatus(dc, config, *config.Network)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw network status: %w", err)
+		}
+	}
+
+	// Exchange rates
+	if len(config.ExchangeRates) > 0 {
+		err = drawExchangeRates(dc, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw exchange rates: %w", err)
+		}
 	}
-	dc.SetColor(color.Black)
-	dc.DrawStringAnchored(
-		localeDate(time.Now()),
-		float64(config.Width/2),
-		float64(config.Padding+32),
-		0.5, 0.5,
-	)
 
 	offsetTop := 70
+	dc.section(float64(config.Padding), float64(offsetTop))
 
 	// Weather Icon
 	imageWidth := 140
@@ -286,6 +627,7 @@ func GenerateDashboard(config *DashboardConfig) (*gg.Context, error) {
 		image.Point{X: config.Width/2 - imageWidth/2 - gap, Y: offsetTop},
 		imageWidth, 0,
 		.5, 0,
+		nil,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error adding weather icon: %w", err)
@@ -294,12 +636,12 @@ func GenerateDashboard(config *DashboardConfig) (*gg.Context, error) {
 	offsetTop += 52
 
 	// Weather Condition
-	err = setFont(dc, FontRegular, FontSizeSM)
+	err = setFont(dc.Context, FontRegular, FontSizeSM)
 	if err != nil {
 		return nil, fmt.Errorf("failed to set weather condition font: %w", err)
 	}
 
-	condition := weatherConditions[int(*config.Weather.WeatherCode)]
+	condition := config.Weather.Condition(config.Locale)
 	dc.SetColor(color.Black)
 	_, textH := dc.MeasureString(condition)
 
@@ -314,32 +656,66 @@ func GenerateDashboard(config *DashboardConfig) (*gg.Context, error) {
 	// Temperature
 	offsetTop += int(textH) + 7
 
-	err = setFont(dc, FontBold, FontSizeL)
+	err = setFont(dc.Context, FontBold, FontSizeL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to set temperature font: %w", err)
 	}
+
+	// Fall back to the forecast if the daily values are missing.
+	low, high := config.Weather.TemperatureLow, config.Weather.TemperatureHigh
+	if low == nil {
+		low = config.WeatherForecast.MinTemp()
+	}
+	if high == nil {
+		high = config.WeatherForecast.MaxTemp()
+	}
+
+	temperature := "–"
+	if low != nil && high != nil {
+		temperature = fmt.Sprintf("%d-%d°", int(*low), int(*high))
+	}
+
 	dc.SetColor(color.Black)
 	dc.DrawStringAnchored(
-		fmt.Sprintf("%d-%d°", int(*config.Weather.TemperatureLow), int(*config.Weather.TemperatureHigh)),
+		temperature,
 		offsetLeft,
 		float64(offsetTop),
 		0, 0,
 	)
 
+	// Precipitation of the forecast, next to the temperature
+	if precipitation := config.WeatherForecast.TotalPrecipitation(); precipitation > 0 {
+		temperatureW, _ := dc.MeasureString(temperature)
+
+		err = setFont(dc.Context, FontRegular, FontSizeXS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set precipitation font: %w", err)
+		}
+
+		dc.SetColor(ColorBlue)
+		dc.DrawStringAnchored(
+			fmt.Sprintf("%.1f mm", precipitation),
+			offsetLeft+temperatureW+12,
+			float64(offsetTop),
+			0, 0,
+		)
+	}
+
 	// Sunrise and Sunset
 	offsetTop += 32
-	err = setFont(dc, FontRegular, FontSizeXS)
+	err = setFont(dc.Context, FontRegular, FontSizeXS)
 	if err != nil {
 		return nil, fmt.Errorf("failed to set precipitation font: %w", err)
 	}
 
 	err = addImage(
 		dc,
-		"icons/weather/sun.png",
+		"weather/sun.png",
 		image.Point{X: int(offsetLeft), Y: offsetTop},
 		22, 0,
 		0.0,
 		1,
+		nil,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error adding parcipitation icon: %w", err)
@@ -347,123 +723,436 @@ func GenerateDashboard(config *DashboardConfig) (*gg.Context, error) {
 
 	dc.SetColor(color.Black)
 	dc.DrawStringAnchored(
-		fmt.Sprintf("↑ %s    ↓ %s", config.Weather.Sunrise.Format("15:04"), config.Weather.Sunset.Format("15:04")),
+		fmt.Sprintf("↑ %s    ↓ %s", config.TimeFormat.Clock(config.Weather.Sunrise), config.TimeFormat.Clock(config.Weather.Sunset)),
 		offsetLeft+30,
 		float64(offsetTop),
 		0, -.3,
 	)
 
-	// Forecast Graph
-	offsetTop += 24
+	// Pressure
+	rows := len(config.Locations)
+	if config.ShowPressure {
+		offsetTop += locationRowHeight
+		rows++
 
-	err = renderGraph(dc, offsetTop, config.Padding, config.WeatherForecast)
-	if err != nil {
-		return nil, fmt.Errorf("error rendering graph: %w", err)
+		err = drawPressure(dc, config.Weather, int(offsetLeft)+30, offsetTop)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw pressure: %w", err)
+		}
 	}
 
-	// Appointments
-	offsetTop = 370
+	// Air quality
+	if config.ShowAQI && config.AirQuality != nil {
+		offsetTop += locationRowHeight
+		rows++
 
-	err = drawHeading(dc, "Termine", offsetTop, config.Width, config.Padding)
-	if err != nil {
-		return nil, fmt.Errorf("failed to draw appointments heading: %w", err)
+		err = drawAirQuality(dc, *config.AirQuality, int(offsetLeft), offsetTop, config.Locale)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw air quality: %w", err)
+		}
 	}
 
-	offsetTop += 18
-	spacing := 14
+	// Other weather locations
+	for _, location := range config.Locations {
+		offsetTop += locationRowHeight
+		err = drawLocationWeather(dc, location, int(offsetLeft), offsetTop, config.Width-config.Padding*2-int(offsetLeft))
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw weather of %s: %w", location.Name, err)
+		}
+	}
 
-	tagWidth := 30.0
-	tagHeight := 20.0
+	// Forecast Graph, it gives up the height of the rows above.
+	offsetTop += 24
+	dc.section(70, float64(offsetTop))
+	shrink := locationRowHeight * rows
 
-	for _, appointment := range config.Appointments {
-		err = setFont(dc, FontBold, FontSizeXXS)
+	if config.HistoricalDays > 0 && len(config.History) > 0 {
+		rect := image.Rect(config.Padding*2, offsetTop+10, config.Width-config.Padding*2, offsetTop+140-shrink)
+		err = renderHistoryChart(dc, rect, config.History)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering history chart: %w", err)
+		}
+	} else if config.RainChart {
+		rect := image.Rect(config.Padding*2, offsetTop+10, config.Width-config.Padding*2, offsetTop+140-shrink)
+		err = renderRainChart(dc, rect, config.WeatherForecast, config.WeekdayAbbreviations)
 		if err != nil {
-			return nil, fmt.Errorf("failed to set appointment font: %w", err)
+			return nil, fmt.Errorf("error rendering rain chart: %w", err)
 		}
+	} else {
+		err = renderGraph(dc, offsetTop, config.Padding, graphHeight-shrink, config.WeatherForecast, config.WeekdayAbbreviations)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering graph: %w", err)
+		}
+	}
 
-		offsetTop += int(textH) + spacing
-		offsetLeft = float64(config.Padding * 2)
+	// Appointments, an empty list can give its space to the quote.
+	quoteTop, quoteFontSize := footerTop, FontSizeSM
+	if config.collapseAppointments() {
+		quoteTop, quoteFontSize = 370, FontSizeM
+	} else {
+		dc.section(float64(offsetTop), 370)
+		offsetTop = 370
+		dc.section(float64(offsetTop), footerTop)
 
-		dc.SetColor(appointment.Color)
-		dc.DrawRoundedRectangle(
-			offsetLeft,
-			float64(offsetTop)-(tagHeight-4),
-			tagWidth,
-			tagHeight,
-			4,
-		)
-		dc.Fill()
+		err = drawHeading(dc, "Termine", offsetTop, config.Width, config.Padding, config.SeparatorStyle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw appointments heading: %w", err)
+		}
 
-		dc.SetColor(ColorWhite)
-		dc.DrawStringAnchored(
-			appointment.Tag,
-			offsetLeft+tagWidth/2,
-			float64(offsetTop),
-			.5, -.1,
-		)
+		// The widgets are stacked from the bottom of the section upwards, the
+		// step count at the bottom. The ones that would reach into the
+		// heading are left out.
+		var widgets []stackedWidget
+		if config.Steps != nil {
+			widgets = append(widgets, stackedWidget{"step count", fitnessHeight, func(rect image.Rectangle) error {
+				return drawStepCount(dc, rect, *config.Steps, config.Locale)
+			}})
+		}
+		if config.Sleep != nil {
+			widgets = append(widgets, stackedWidget{"sleep", sleepHeight, func(rect image.Rectangle) error {
+				return drawSleep(dc, rect, *config.Sleep, config.Locale)
+			}})
+		}
+		if len(config.Medications) > 0 {
+			widgets = append(widgets, stackedWidget{"medications", medicationHeight(len(config.Medications)), func(rect image.Rectangle) error {
+				return drawMedications(dc, rect, config.Medications, now, config.TimeFormat)
+			}})
+		}
+		if len(config.Garbage) > 0 {
+			widgets = append(widgets, stackedWidget{"garbage pickups", garbageHeight(len(config.Garbage)), func(rect image.Rectangle) error {
+				return drawGarbagePickups(dc, rect, config.Garbage, now, config.Locale)
+			}})
+		}
+		if config.ShowYearProgress {
+			widgets = append(widgets, stackedWidget{"year progress", yearProgressHeight(config.ShowMonthProgress), func(rect image.Rectangle) error {
+				return drawYearProgress(dc, rect, now, config.ShowMonthProgress, config.Locale)
+			}})
+		}
+		if len(config.Pollen) > 0 {
+			widgets = append(widgets, stackedWidget{"pollen", pollenHeight(len(config.Pollen)), func(rect image.Rectangle) error {
+				return drawPollen(dc, rect, config.Pollen)
+			}})
+		}
+		if len(config.Todos) > 0 {
+			widgets = append(widgets, stackedWidget{"todos", todosHeight(len(config.Todos)), func(rect image.Rectangle) error {
+				return drawTodos(dc, rect, config.Todos, now, config.Locale)
+			}})
+		}
+		if len(config.Departures) > 0 {
+			widgets = append(widgets, stackedWidget{"departures", departuresHeight(len(config.Departures)), func(rect image.Rectangle) error {
+				return drawDepartures(dc, rect, config.Departures, now, config.TimeFormat)
+			}})
+		}
+		// The word of the day is stacked here unless it is in the footer.
+		if config.Word != nil && !config.WordReplacesQuote {
+			widgets = append(widgets, stackedWidget{"word", wordHeight, func(rect image.Rectangle) error {
+				return drawWord(dc, rect, *config.Word, FontSizeXS, 1)
+			}})
+		}
+
+		var sectionBottom int
+		sectionBottom, err = drawStackedWidgets(dc, config, widgets, offsetTop+stackedWidgetsTop, footerTop-8)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.AppointmentView == AppointmentViewWeekGrid {
+			err = drawWeek(dc, config, float64(offsetTop+stackedWidgetsTop), float64(sectionBottom), now)
+			if err != nil {
+				return nil, fmt.Errorf("failed to draw week: %w", err)
+			}
+		} else {
+			// The mini calendar takes the bottom of the remaining space.
+			listBottom := sectionBottom
+			if config.ShowMiniMonth && sectionBottom-miniMonthHeight < offsetTop+stackedWidgetsTop {
+				slog.Warn("not enough space for widget, skipping it", "widget", "mini month")
+			} else if config.ShowMiniMonth {
+				listBottom -= miniMonthHeight + 8
+
+				rect := image.Rect(
+					config.Padding*2,
+					sectionBottom-miniMonthHeight,
+					config.Width-config.Padding*2,
+					sectionBottom,
+				)
+				err = renderMiniMonth(dc, rect, now, eventDaysOf(config.Appointments, now), config.WeekdayAbbreviations)
+				if err != nil {
+					return nil, fmt.Errorf("failed to draw mini month: %w", err)
+				}
+			}
+
+			offsetTop += 18
+			spacing := 14
+
+			tagWidth := 30.0
+			tagHeight := 20.0
+
+			// A wrapped title makes its row taller by one line.
+			lineHeight := int(textH) + 6
+			titleLeft := float64(config.Padding*2) + tagWidth + 10
+
+			// Rows that are followed by hidden appointments leave room for the count.
+			total := max(config.AppointmentTotal, len(config.Appointments))
+			shown := 0
+
+			for i, appointment := range config.Appointments {
+				err = setFont(dc.Context, FontRegular, FontSizeSM)
+				if err != nil {
+					return nil, fmt.Errorf("failed to set appointment font: %w", err)
+				}
+
+				date := relativeDate(now, appointment.Start, config.TimeFormat)
+				titleLines := []string{limit(appointment.Title, config.AppointmentTitleMaxChars)}
+				if config.WrapAppointmentTitles {
+					dateW, _ := dc.MeasureString(date)
+					titleWidth := float64(config.Width-config.Padding*2) - dateW - 10 - titleLeft
+					titleLines = wrapString(dc.Context, appointment.Title, titleWidth, appointmentTitleLines)
+				}
+
+				// The countdown goes below the date, next to a wrapped title.
+				countdown := appointmentCountdown(config, i, now)
+				rowExtra := (len(titleLines) - 1) * lineHeight
+				if countdown != "" {
+					rowExtra = max(rowExtra, appointmentCountdownHeight)
+				}
+
+				rowBottom := offsetTop + int(textH) + spacing + rowExtra
+				if i+1 < total {
+					rowBottom += appointmentOverflowHeight
+				}
+				if rowBottom > listBottom {
+					break
+				}
+				offsetTop += int(textH) + spacing
+				rowTop := offsetTop
+				shown++
+				offsetLeft = float64(config.Padding * 2)
+
+				err = setFont(dc.Context, FontBold, FontSizeXXS)
+				if err != nil {
+					return nil, fmt.Errorf("failed to set appointment font: %w", err)
+				}
+
+				dc.SetColor(appointment.Color)
+				dc.DrawRoundedRectangle(
+					offsetLeft,
+					float64(offsetTop)-(tagHeight-4),
+					tagWidth,
+					tagHeight,
+					4,
+				)
+				dc.Fill()
+
+				dc.SetColor(ColorWhite)
+				dc.DrawStringAnchored(
+					fitString(dc.Context, appointment.Tag, tagWidth-4),
+					offsetLeft+tagWidth/2,
+					float64(offsetTop),
+					.5, -.1,
+				)
+
+				err = setFont(dc.Context, FontRegular, FontSizeSM)
+				if err != nil {
+					return nil, fmt.Errorf("failed to set appointment font: %w", err)
+				}
+
+				dc.SetColor(color.Black)
+				dc.DrawStringAnchored(
+					date,
+					float64(config.Width-config.Padding*2),
+					float64(offsetTop),
+					1, 0,
+				)
+
+				for i, line := range titleLines {
+					if i > 0 {
+						offsetTop += lineHeight
+					}
+					dc.DrawStringAnchored(line, titleLeft, float64(offsetTop), 0, 0)
+				}
+
+				if countdown != "" {
+					err = drawAppointmentCountdown(dc, config, countdown, rowTop+appointmentCountdownHeight)
+					if err != nil {
+						return nil, fmt.Errorf("failed to draw appointment countdown: %w", err)
+					}
+					offsetTop = max(offsetTop, rowTop+appointmentCountdownHeight)
+				}
+			}
+
+			if hidden := total - shown; hidden > 0 && shown > 0 {
+				err = drawAppointmentOverflow(dc, config, hidden, offsetTop+appointmentOverflowHeight)
+				if err != nil {
+					return nil, fmt.Errorf("failed to draw hidden appointments: %w", err)
+				}
+			}
+
+			if len(config.Appointments) == 0 {
+				err = drawNoAppointments(dc, config, offsetTop, listBottom)
+				if err != nil {
+					return nil, fmt.Errorf("failed to draw appointments placeholder: %w", err)
+				}
+			}
+		}
+	}
+
+	// Footer
+	offsetTop = quoteTop
+	dc.section(float64(offsetTop), float64(config.Height-config.Padding))
 
-		err = setFont(dc, FontRegular, FontSizeSM)
+	// Border
+	drawSeparator(dc.Context, config.SeparatorStyle, float64(2*config.Padding), float64(offsetTop)+10, float64(config.Width-4*config.Padding))
+
+	offsetTop += 30
+
+	if config.Word != nil && config.WordReplacesQuote {
+		rect := image.Rect(config.Padding*2, offsetTop, config.Width-config.Padding*2, config.Height-config.Padding*2)
+		err = drawWord(dc, rect, *config.Word, FontSizeM, 3)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw word: %w", err)
+		}
+	} else {
+		err = setFont(dc.Context, FontRegular, quoteFontSize)
 		if err != nil {
-			return nil, fmt.Errorf("failed to set appointment font: %w", err)
+			return nil, fmt.Errorf("failed to set quote font: %w", err)
 		}
 
-		offsetLeft += tagWidth + 10
+		quoteText := config.Quote.Text
+		if config.QuoteMaxChars > 0 && !config.Quote.Pinned {
+			quoteText = limit(quoteText, config.QuoteMaxChars)
+		}
 
+		lines := wrapString(dc.Context, quoteText, float64(config.Width-4*config.Padding), 0)
 		dc.SetColor(color.Black)
-		dc.DrawStringAnchored(
-			limit(appointment.Title, 25),
-			offsetLeft,
+
+		dc.DrawStringWrapped(
+			strings.Join(lines, "\n"),
+			float64(config.Padding*2),
 			float64(offsetTop),
 			0, 0,
+			float64(config.Width-4*config.Padding),
+			1.5,
+			gg.AlignLeft,
 		)
+		_, textH = dc.MeasureMultilineString(strings.Join(lines, "\n"), 1.5)
+
+		offsetTop += int(textH) + 35
 
 		dc.DrawStringAnchored(
-			relativeDate(appointment.Start),
+			config.Quote.Author,
 			float64(config.Width-config.Padding*2),
 			float64(offsetTop),
 			1, 0,
 		)
 	}
 
-	// Footer
-	offsetTop = 630
+	// Photo of the day, drawn over the other sections
+	if config.PhotoDir != "" {
+		rect := config.PhotoRect
+		if rect.Empty() {
+			rect = image.Rect(config.Padding+1, config.Padding+1, config.Width-config.Padding-1, config.Height-config.Padding-1)
+		}
+		// A missing photo must not keep the rest of the dashboard from being shown.
+		if err := drawPhoto(dc, rect, config.PhotoDir, now); err != nil {
+			slog.Warn("failed to draw photo", "error", err)
+		}
+	}
 
-	// Border
-	dc.SetColor(color.Black)
-	dc.DrawRectangle(float64(2*config.Padding), float64(offsetTop)+10, float64(config.Width-4*config.Padding), 2.0)
-	dc.Fill()
+	// Refresh time
+	if config.ShowRefreshTime {
+		err = drawRefreshTime(dc, config, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw refresh time: %w", err)
+		}
+	}
 
-	offsetTop += 30
+	// Version
+	if Version != "dev" || config.ShowVersion {
+		err = drawVersion(dc, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw version: %w", err)
+		}
+	}
 
-	lines := dc.WordWrap(config.Quote.Text, float64(config.Width-4*config.Padding))
+	if dc.debug {
+		dc.drawDebugOverlay()
+	}
+
+	return dc.Context, nil
+}
 
-	err = setFont(dc, FontRegular, FontSizeSM)
+// drawRefreshTime draws the time of the update in the bottom left corner of
+// the frame. It is drawn red if the data is stale.
+func drawRefreshTime(dc *dashboardCanvas, config *DashboardConfig, now time.Time) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
 	if err != nil {
-		return nil, fmt.Errorf("failed to set quote font: %w", err)
+		return err
 	}
+
 	dc.SetColor(color.Black)
+	if config.RefreshInterval > 0 && !config.DataUpdated.IsZero() && now.Sub(config.DataUpdated) > 2*config.RefreshInterval {
+		dc.SetColor(ColorRed)
+	}
 
-	dc.DrawStringWrapped(
-		config.Quote.Text,
+	dc.DrawStringAnchored(
+		refreshTimeLabel(config, now),
 		float64(config.Padding*2),
-		float64(offsetTop),
+		float64(config.Height-config.Padding-4),
 		0, 0,
-		float64(config.Width-4*config.Padding),
-		1.5,
-		gg.AlignLeft,
 	)
-	_, textH = dc.MeasureMultilineString(strings.Join(lines, "\n"), 1.5)
 
-	offsetTop += int(textH) + 35
+	return nil
+}
+
+// refreshTimeLabel returns the label of drawRefreshTime, with now in the
+// dashboard's location.
+func refreshTimeLabel(config *DashboardConfig, now time.Time) string {
+	if config.Location != nil {
+		now = now.In(config.Location)
+	}
 
+	if config.Locale == LocaleEnglish {
+		return "Updated: " + config.TimeFormat.Clock(now)
+	}
+	label := "Aktualisiert: " + config.TimeFormat.Clock(now)
+	if config.TimeFormat != TimeFormat12h {
+		label += " Uhr"
+	}
+	return label
+}
+
+// versionColor is light enough to be unobtrusive, but dark enough to
+// stay visible after quantization to the panel's palette.
+var versionColor = color.Gray{Y: 0x70}
+
+// versionLabel returns the version and, if set, the build date (e.g., "v1.2.3 2024-01-15").
+func versionLabel() string {
+	label := Version
+	if label != "dev" && !strings.HasPrefix(label, "v") {
+		label = "v" + label
+	}
+	if BuildDate != "" {
+		label += " " + BuildDate
+	}
+	return label
+}
+
+// drawVersion draws the version label in the bottom right corner of the frame
+func drawVersion(dc *dashboardCanvas, config *DashboardConfig) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return err
+	}
+
+	dc.SetColor(versionColor)
 	dc.DrawStringAnchored(
-		config.Quote.Author,
-		float64(config.Width-config.Padding*2),
-		float64(offsetTop),
+		versionLabel(),
+		float64(config.Width-config.Padding-4),
+		float64(config.Height-config.Padding-4),
 		1, 0,
 	)
 
-	return dc, nil
+	return nil
 }
 
 type GraphData struct {
@@ -472,8 +1161,18 @@ type GraphData struct {
 	Labels   []string
 }
 
-func renderGraph(dc *gg.Context, offsetTop, padding int, hourlyWeather WeatherForecast) error {
-	itemCount := 7
+// graphHeight is the height of the forecast graph.
+const graphHeight = 155
+
+// graphWidth is the width of the forecast graph, including the gap before
+// the first hour of the next day.
+const graphWidth = 430
+
+// graphDayGap is the gap before the first hour of the next day.
+const graphDayGap = 6
+
+func renderGraph(dc *dashboardCanvas, offsetTop, padding, height int, hourlyWeather WeatherForecast, weekdays [7]string) error {
+	itemCount := forecastItems
 
 	labels := make([]string, itemCount)
 	temps := make([]float64, itemCount)
@@ -492,6 +1191,13 @@ func renderGraph(dc *gg.Context, offsetTop, padding int, hourlyWeather WeatherFo
 		labels[i] = weather.Label
 	}
 
+	// The columns of the next day are moved right by graphDayGap.
+	width := graphWidth
+	next := nextDayIndex(hourlyWeather)
+	if next > 0 && next < itemCount {
+		width -= graphDayGap
+	}
+
 	data := GraphData{
 		TempData: temps,
 		RainData: rain,
@@ -519,21 +1225,30 @@ func renderGraph(dc *gg.Context, offsetTop, padding int, hourlyWeather WeatherFo
 	})
 
 	labelFontSize := 10.0
+	// A shorter graph has no room for five labels.
+	labelCount := 5
+	if height < graphHeight-locationRowHeight {
+		labelCount = 3
+	}
 
 	opt := charts.ChartOption{
 		Theme:  theme,
-		Width:  430,
-		Height: 155,
+		Width:  width,
+		Height: height,
 		XAxis: charts.XAxisOption{
 			Labels:         data.Labels,
 			LabelFontStyle: charts.FontStyle{FontSize: labelFontSize},
+			LabelCount:     itemCount,
+			// The columns are centered between the ticks, drawGraphDays
+			// relies on it.
+			BoundaryGap: charts.Ptr(true),
 		},
 		YAxis: []charts.YAxisOption{
 			{
 				Theme:          theme.WithYAxisSeriesColor(0),
 				LabelFontStyle: charts.FontStyle{FontSize: labelFontSize, FontColor: charts.ColorBlack},
 				ValueFormatter: func(f float64) string { return fmt.Sprintf("%.0f", roundFloat(f, 0)) },
-				LabelCount:     5,
+				LabelCount:     labelCount,
 			},
 			{
 				Theme:          theme.WithYAxisSeriesColor(1),
@@ -541,7 +1256,7 @@ func renderGraph(dc *gg.Context, offsetTop, padding int, hourlyWeather WeatherFo
 				Position:       "right",
 				ValueFormatter: func(f float64) string { return fmt.Sprintf("%.1f", roundFloat(f, 1)) },
 				Min:            charts.Ptr(0.0),
-				LabelCount:     5,
+				LabelCount:     labelCount,
 			},
 		},
 		SeriesList: append(tempSeries, rainSeries...),
@@ -562,26 +1277,274 @@ func renderGraph(dc *gg.Context, offsetTop, padding int, hourlyWeather WeatherFo
 		return err
 	}
 
+	if width < graphWidth {
+		weekday := weekdays[hourlyWeather[next].Timestamp.Weekday()]
+		if drawGraphDays(dc, img, padding+5, offsetTop, next, itemCount, weekday) {
+			return nil
+		}
+	}
+
 	dc.DrawImageAnchored(img, padding+5, offsetTop, 0, 0)
 	return nil
 }
 
+// drawGraphDays draws the graph img at x, y with the column of the first
+// hour of the next day and the ones after it moved right by graphDayGap. The
+// gap has a divider, the weekday is written above the column. It returns
+// false if the axis of the graph was not found.
+func drawGraphDays(dc *dashboardCanvas, img image.Image, x, y, next, columns int, weekday string) bool {
+	axis, ok := graphAxis(img)
+	if !ok {
+		return false
+	}
+
+	sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return false
+	}
+
+	// The ends of the axis are the outer ticks. The sub images keep the
+	// coordinates of img, so the left part stays in place.
+	bounds := img.Bounds()
+	split := axis.Min.X + axis.Dx()*next/columns
+	dc.DrawImage(sub.SubImage(image.Rect(bounds.Min.X, bounds.Min.Y, split, bounds.Max.Y)), x, y)
+	dc.DrawImage(sub.SubImage(image.Rect(split, bounds.Min.Y, bounds.Max.X, bounds.Max.Y)), x+graphDayGap, y)
+
+	// Continue the axis under the gap with the pixels left of the tick.
+	for row := axis.Min.Y; row < axis.Max.Y; row++ {
+		dc.SetColor(img.At(split-2, row))
+		dc.DrawRectangle(float64(x+split), float64(y+row), graphDayGap, 1)
+		dc.Fill()
+	}
+
+	dc.SetColor(color.Black)
+	dc.SetLineWidth(1)
+	divider := float64(x+split) + graphDayGap/2
+	dc.DrawLine(divider, float64(y+graphDayLabelHeight), divider, float64(y+axis.Min.Y))
+	dc.SetDash(1, 2)
+	dc.Stroke()
+	dc.SetDash()
+
+	if err := setFont(dc.Context, FontRegular, FontSizeXXXS); err != nil {
+		return true
+	}
+	dc.DrawStringAnchored(weekday, divider+3, float64(y), 0, 1)
+
+	return true
+}
+
+// graphDayLabelHeight is the height of the weekday above the graph.
+const graphDayLabelHeight = 14
+
+// graphAxis returns the x-axis of the rendered graph, the longest horizontal
+// line of the image. The line is one or two rows high, as it is anti-aliased.
+// ok is false if there is no line across half of the image.
+func graphAxis(img image.Image) (axis image.Rectangle, ok bool) {
+	bounds := img.Bounds()
+	runs := make([]image.Rectangle, 0, bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		var longest image.Rectangle
+		start := -1
+		for x := bounds.Min.X; x <= bounds.Max.X; x++ {
+			if x < bounds.Max.X && !isBlank(img.At(x, y)) {
+				if start < 0 {
+					start = x
+				}
+				continue
+			}
+			if start >= 0 && x-start > longest.Dx() {
+				longest = image.Rect(start, y, x, y+1)
+			}
+			start = -1
+		}
+		runs = append(runs, longest)
+
+		if longest.Dx() > axis.Dx() {
+			axis = longest
+		}
+	}
+
+	// Add the neighboring row of the anti-aliased line.
+	for _, row := range []int{axis.Min.Y - 1, axis.Max.Y} {
+		if row >= bounds.Min.Y && row < bounds.Max.Y && runs[row-bounds.Min.Y].Dx() >= axis.Dx()-2 {
+			axis = axis.Union(image.Rect(axis.Min.X, row, axis.Max.X, row+1))
+		}
+	}
+
+	return axis, axis.Dx() >= bounds.Dx()/2
+}
+
+// isBlank reports whether c is transparent or nearly white.
+func isBlank(c color.Color) bool {
+	r, g, b, a := c.RGBA()
+	return a < 0x8000 || r >= 0xe000 && g >= 0xe000 && b >= 0xe000
+}
+
+// nextDayIndex returns the index of the first hour of the forecast that
+// belongs to a later day than the first one, or -1 if there is none. Daily
+// forecasts have no such hour.
+func nextDayIndex(forecast WeatherForecast) int {
+	if len(forecast) < 2 || forecast[1].Timestamp.Sub(forecast[0].Timestamp) >= 24*time.Hour {
+		return -1
+	}
+
+	for i, weather := range forecast {
+		if daysUntil(forecast[0].Timestamp, weather.Timestamp) > 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// locationRowHeight is the height of the line of another weather location.
+const locationRowHeight = 24
+
+// drawLocationWeather draws the name, the icon and the temperature range of
+// another weather location in a line starting at x whose baseline is y.
+func drawLocationWeather(dc *dashboardCanvas, location LocationWeather, x, y, width int) error {
+	err := addImage(dc, location.Weather.Icon(), image.Point{X: x, Y: y}, 22, 0, 0, 1, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = setFont(dc.Context, FontRegular, FontSizeXS); err != nil {
+		return err
+	}
+
+	temperature := "–"
+	if low, high := location.Weather.TemperatureLow, location.Weather.TemperatureHigh; low != nil && high != nil {
+		temperature = fmt.Sprintf("%d-%d°", int(*low), int(*high))
+	}
+	tempW, _ := dc.MeasureString(temperature)
+
+	dc.SetColor(color.Black)
+	name := fitString(dc.Context, location.Name, float64(width-30)-tempW-10)
+	dc.DrawStringAnchored(name, float64(x+30), float64(y), 0, -.3)
+	dc.DrawStringAnchored(temperature, float64(x+width), float64(y), 1, -.3)
+
+	return nil
+}
+
+// pressureSteadyRange is the change in hPa within which the pressure counts
+// as steady and gets no trend arrow.
+const pressureSteadyRange = 1.0
+
+// drawPressure draws the pressure, e.g. "1013 hPa ↑", with its baseline at y.
+func drawPressure(dc *dashboardCanvas, weather Weather, x, y int) error {
+	if err := setFont(dc.Context, FontRegular, FontSizeXS); err != nil {
+		return err
+	}
+
+	text := "– hPa"
+	if weather.Pressure != nil {
+		text = fmt.Sprintf("%.0f hPa", *weather.Pressure)
+	}
+	if change := weather.PressureChange; change != nil {
+		switch {
+		case *change >= pressureSteadyRange:
+			text += " ↑"
+		case *change <= -pressureSteadyRange:
+			text += " ↓"
+		}
+	}
+
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(text, float64(x), float64(y), 0, -.3)
+
+	return nil
+}
+
 // limit limits the length of a string to a maximum number of characters
 func limit(s string, length int) string {
-	if len(s) > length {
-		s = s[:length] + "..."
+	if runes := []rune(s); len(runes) > length {
+		s = string(runes[:length]) + "..."
 	}
 	return s
 }
 
-// drawHeading draws a section heading with a line underneath
+// fitString shortens s rune by rune until it fits into maxWidth
+// with the current font face
+func fitString(dc *gg.Context, s string, maxWidth float64) string {
+	runes := []rune(s)
+	for len(runes) > 1 {
+		if w, _ := dc.MeasureString(string(runes)); w <= maxWidth {
+			break
+		}
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
+
+// wrapString word-wraps s into lines that fit into maxWidth with the
+// current font face. Words longer than a line are cut off. With maxLines
+// above 0, the rest of the text is shortened to fit into the last line and
+// marked with "...".
+func wrapString(dc *gg.Context, s string, maxWidth float64, maxLines int) []string {
+	lines := dc.WordWrap(s, maxWidth)
+
+	var rest string
+	if maxLines > 0 && len(lines) > maxLines {
+		rest = strings.Join(lines[maxLines-1:], " ")
+		lines = lines[:maxLines-1]
+	}
+
+	for i, line := range lines {
+		lines[i] = fitString(dc, line, maxWidth)
+	}
+
+	if rest != "" {
+		ellipsisW, _ := dc.MeasureString("...")
+		lines = append(lines, fitString(dc, rest, maxWidth-ellipsisW)+"...")
+	}
+
+	return lines
+}
+
+// stackedWidgetsTop is the space below the top of the appointments section
+// that the stacked widgets leave for the heading.
+const stackedWidgetsTop = 30
+
+// stackedWidget is a widget stacked at the bottom of the appointments section.
+type stackedWidget struct {
+	name   string
+	height int
+	draw   func(rect image.Rectangle) error
+}
+
+// drawStackedWidgets stacks the widgets upwards from bottom, 8 pixels apart,
+// and returns the top of the stack. Once a widget would reach above top, it
+// and all the following ones are skipped.
+func drawStackedWidgets(dc *dashboardCanvas, config *DashboardConfig, widgets []stackedWidget, top, bottom int) (int, error) {
+	for i, widget := range widgets {
+		if bottom-widget.height < top {
+			for _, skipped := range widgets[i:] {
+				slog.Warn("not enough space for widget, skipping it", "widget", skipped.name)
+			}
+			break
+		}
+
+		rect := image.Rect(config.Padding*2, bottom-widget.height, config.Width-config.Padding*2, bottom)
+		if err := widget.draw(rect); err != nil {
+			return 0, fmt.Errorf("failed to draw %s: %w", widget.name, err)
+		}
+
+		bottom -= widget.height + 8
+	}
+
+	return bottom, nil
+}
+
+// drawHeading draws a section heading with a separator of the given style underneath
 // It returns an error if setting the font fails
-func drawHeading(dc *gg.Context, text string, currentOffset int, width, padding int) error {
+func drawHeading(dc *dashboardCanvas, text string, currentOffset int, width, padding int, separator SeparatorStyle) error {
 	if dc == nil {
 		return fmt.Errorf("canvas is nil")
 	}
 
-	err := setFont(dc, FontBold, FontSizeS)
+	err := setFont(dc.Context, FontBold, FontSizeS)
 	if err != nil {
 		return fmt.Errorf("failed to set heading font: %w", err)
 	}
@@ -590,37 +1553,135 @@ func drawHeading(dc *gg.Context, text string, currentOffset int, width, padding
 	dc.DrawStringAnchored(text, float64(padding*2), float64(currentOffset), 0, 0)
 
 	// Border
-	dc.SetColor(color.Black)
-	dc.DrawRectangle(float64(2*padding), float64(currentOffset)+10, float64(width-4*padding), 2.0)
-	dc.Fill()
+	drawSeparator(dc.Context, separator, float64(2*padding), float64(currentOffset)+10, float64(width-4*padding))
 
 	return nil
 }
 
+// iconKey identifies a cached, resized icon.
+type iconKey struct {
+	path          string
+	width, height int
+}
+
+// iconCache holds the decoded and resized icons. The icons are not expected
+// to change while running, so entries are never invalidated. Failed loads are not cached.
+var iconCache = struct {
+	sync.Mutex
+	icons map[iconKey]image.Image
+}{
+	icons: make(map[iconKey]image.Image),
+}
+
 // addImage loads an image from a file, resizes it, and draws it on the canvas
 // at the specified position with the given anchor points
-func addImage(canvas *gg.Context, path string, point image.Point, width, height int, anchorX, anchorY float64) error {
+// If width or height is 0, the aspect ratio is preserved.
+// If tint is not nil, the image is drawn in the tint color (see tintImage).
+func addImage(canvas *dashboardCanvas, path string, point image.Point, width, height int, anchorX, anchorY float64, tint color.Color) error {
 	if canvas == nil {
 		return fmt.Errorf("canvas is nil")
 	}
 
-	templateFile, err := iconsFS.Open(path)
+	icon, err := loadIcon(path, width, height)
 	if err != nil {
-		return fmt.Errorf("failed to open image file %s: %w", path, err)
+		return err
 	}
-	defer templateFile.Close()
 
-	template, _, err := image.Decode(templateFile)
-	if err != nil {
-		return fmt.Errorf("failed to decode image %s: %w", path, err)
+	// Tint after resizing, so the edges of the resized icon are tinted as well.
+	if tint != nil {
+		icon = tintImage(icon, color.RGBAModel.Convert(tint).(color.RGBA))
 	}
 
-	template = resize.Resize(uint(width), uint(height), template, resize.Bicubic)
-	canvas.DrawImageAnchored(template, point.X, point.Y, anchorX, anchorY)
+	canvas.DrawImageAnchored(icon, point.X, point.Y, anchorX, anchorY)
 
 	return nil
 }
 
+// tintImage returns a copy of img with every pixel in the tint color,
+// keeping the alpha of the pixel. Black icons on a transparent background
+// become icons in the tint color.
+func tintImage(img image.Image, tint color.RGBA) image.Image {
+	bounds := img.Bounds()
+	tinted := image.NewRGBA(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+
+			// RGBA is premultiplied, so every channel is scaled by the alpha.
+			alpha := a >> 8
+			tinted.SetRGBA(x, y, color.RGBA{
+				R: uint8(uint32(tint.R) * alpha / 0xff),
+				G: uint8(uint32(tint.G) * alpha / 0xff),
+				B: uint8(uint32(tint.B) * alpha / 0xff),
+				A: uint8(alpha),
+			})
+		}
+	}
+
+	return tinted
+}
+
+// loadIcon returns the cached icon at path resized to width and height,
+// decoding and resizing it on first use. SVG icons are rendered at the
+// requested size, all other formats are decoded and scaled.
+func loadIcon(path string, width, height int) (image.Image, error) {
+	if width <= 0 && height <= 0 {
+		return nil, fmt.Errorf("invalid size for image %s: width or height must be set", path)
+	}
+
+	iconCache.Lock()
+	defer iconCache.Unlock()
+
+	key := iconKey{path: path, width: width, height: height}
+	if icon, ok := iconCache.icons[key]; ok {
+		return icon, nil
+	}
+
+	templateFile, err := iconSource.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image file %s: %w", path, err)
+	}
+	defer templateFile.Close()
+
+	var icon image.Image
+	if isSVG(path) {
+		icon, err = rasterizeSVG(templateFile, max(width, 0), max(height, 0), ColorPalette)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render image %s: %w", path, err)
+		}
+	} else {
+		template, _, err := image.Decode(templateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+		}
+		icon = resizeForPalette(template, max(width, 0), max(height, 0), ColorPalette)
+	}
+	iconCache.icons[key] = icon
+
+	return icon, nil
+}
+
+// fontFaceKey identifies a cached font face.
+type fontFaceKey struct {
+	style FontStyle
+	size  FontSize
+}
+
+// fontCache holds the parsed fonts and faces, so every font file is only
+// read and parsed once. Failed loads are not cached.
+var fontCache = struct {
+	sync.Mutex
+	fonts map[FontStyle]*opentype.Font
+	faces map[fontFaceKey]font.Face
+}{
+	fonts: make(map[FontStyle]*opentype.Font),
+	faces: make(map[fontFaceKey]font.Face),
+}
+
 // setFont sets the font face for the canvas with the specified style and size
 // It returns an error if the font cannot be loaded
 func setFont(canvas *gg.Context, style FontStyle, size FontSize) error {
@@ -628,28 +1689,122 @@ func setFont(canvas *gg.Context, style FontStyle, size FontSize) error {
 		return fmt.Errorf("canvas is nil")
 	}
 
+	face, err := loadFontFace(style, size)
+	if err != nil {
+		return err
+	}
+
+	canvas.SetFontFace(face)
+
+	return nil
+}
+
+// loadFontFace returns the cached font face for the style and size,
+// loading the font file on first use.
+func loadFontFace(style FontStyle, size FontSize) (font.Face, error) {
+	fontCache.Lock()
+	defer fontCache.Unlock()
+
+	key := fontFaceKey{style: style, size: size}
+	if face, ok := fontCache.faces[key]; ok {
+		return face, nil
+	}
+
+	f, ok := fontCache.fonts[style]
+	if !ok {
+		var err error
+		f, err = parseFont(style)
+		if err != nil {
+			return nil, err
+		}
+		fontCache.fonts[style] = f
+	}
+
+	face, err := newFontFace(f, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Runes missing in the font are drawn with the fallback font, if any.
+	var fallback font.Face
+	fallbackFont := fontCache.fonts[fontFallback]
+	if fallbackFont != nil {
+		fallback, err = newFontFace(fallbackFont, size)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	wrapped := newFallbackFace(face, f, fallback, fallbackFont)
+	fontCache.faces[key] = wrapped
+
+	return wrapped, nil
+}
+
+// newFontFace creates a face of the font with the given size.
+func newFontFace(f *opentype.Font, size FontSize) (font.Face, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size: float64(size),
+		DPI:  72,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %w", err)
+	}
+	return face, nil
+}
+
+// parseFont reads and parses the embedded font file of the style.
+func parseFont(style FontStyle) (*opentype.Font, error) {
 	fontPath := fmt.Sprintf("fonts/%s-%s.ttf", fontName, style)
 
 	fontFace, err := fontsFS.Open(fontPath)
 	if err != nil {
-		return fmt.Errorf("failed to open font file %s: %w", fontPath, err)
+		return nil, fmt.Errorf("failed to open font file %s: %w", fontPath, err)
 	}
+	defer fontFace.Close()
 
 	fontBytes, err := io.ReadAll(fontFace)
 	if err != nil {
-		return fmt.Errorf("failed to read font file %s: %w", fontPath, err)
+		return nil, fmt.Errorf("failed to read font file %s: %w", fontPath, err)
 	}
 
-	f, err := truetype.Parse(fontBytes)
+	f, err := opentype.Parse(fontBytes)
 	if err != nil {
-		return fmt.Errorf("failed to parse font file %s: %w", fontPath, err)
+		return nil, fmt.Errorf("failed to parse font file %s: %w", fontPath, err)
 	}
 
-	face := truetype.NewFace(f, &truetype.Options{
-		Size: float64(size),
-	})
+	return f, nil
+}
 
-	canvas.SetFontFace(face)
+// useFontFiles replaces the embedded fonts with TrueType or OpenType files
+// from disk. Styles without a file keep using the embedded font. The file
+// of fontFallback provides the runes that are missing in the other fonts.
+func useFontFiles(files map[FontStyle]string) error {
+	fonts := make(map[FontStyle]*opentype.Font, len(files))
+	for style, path := range files {
+		if path == "" {
+			continue
+		}
+
+		fontBytes, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read font file %s: %w", path, err)
+		}
+
+		f, err := opentype.Parse(fontBytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse font file %s: %w", path, err)
+		}
+		fonts[style] = f
+	}
+
+	fontCache.Lock()
+	defer fontCache.Unlock()
+
+	for style, f := range fonts {
+		fontCache.fonts[style] = f
+	}
+	clear(fontCache.faces)
 
 	return nil
 }
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden images in testdata/golden")
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// checkGolden compares img with the golden image testdata/golden/name.png.
+// With -update, it writes the golden image instead.
+func checkGolden(t *testing.T, name string, img image.Image) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "golden", name+".png")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err = png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open golden image, run the test with -update to create it: %v", err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bounds := img.Bounds()
+	if want.Bounds().Size() != bounds.Size() {
+		t.Fatalf("%s: got size %v, want %v", name, bounds.Size(), want.Bounds().Size())
+	}
+
+	var diff image.Rectangle
+	var count int
+	for y := range bounds.Dy() {
+		for x := range bounds.Dx() {
+			r1, g1, b1, a1 := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := want.At(want.Bounds().Min.X+x, want.Bounds().Min.Y+y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				diff = diff.Union(image.Rect(x, y, x+1, y+1))
+				count++
+			}
+		}
+	}
+	if count > 0 {
+		t.Errorf("%s: %d pixels differ from the golden image in %v, run the test with -update if the change is intended", name, count, diff)
+	}
+}
+
+// fixtureConfig returns a dashboard with every common section filled: the
+// weather with the hourly forecast, appointments and the quote.
+func fixtureConfig(now time.Time) *DashboardConfig {
+	cfg := NewDefaultConfig()
+	cfg.ShowRefreshTime = true
+	cfg.DataUpdated = now
+
+	cfg.Weather = Weather{
+		Timestamp:                now,
+		TemperatureLow:           ptr(3.5),
+		TemperatureHigh:          ptr(12.0),
+		WeatherCode:              ptr(int32(61)),
+		Sunrise:                  now.Add(-4 * time.Hour),
+		Sunset:                   now.Add(6 * time.Hour),
+		PrecipitationSum:         ptr(2.4),
+		PrecipitationProbability: ptr(60.0),
+	}
+	for i := range 6 {
+		t := now.Add(time.Duration(i) * time.Hour)
+		cfg.WeatherForecast = append(cfg.WeatherForecast, Weather{
+			Label:                    t.Format("15"),
+			Timestamp:                t,
+			TemperatureLow:           ptr(float64(i)),
+			TemperatureHigh:          ptr(float64(i) + 0.5),
+			WeatherCode:              ptr(int32(i)),
+			PrecipitationSum:         ptr(float64(i) / 3),
+			PrecipitationProbability: ptr(float64(i * 10)),
+		})
+	}
+
+	for i := range 5 {
+		cfg.Appointments = append(cfg.Appointments, &Appointment{
+			Title: "Zahnarzt mit einer sehr langen Beschreibung",
+			Start: now.Add(time.Duration(i*30) * time.Hour),
+			Tag:   "AB",
+			Color: ColorBlue,
+		})
+	}
+
+	cfg.Quote = quote{Text: "Man muss das Unmögliche versuchen, um das Mögliche zu erreichen.", Author: "Hermann Hesse"}
+
+	return cfg
+}
+
+// BenchmarkGenerateDashboard renders the fixture dashboard.
+//
+// Baseline on a single core of an Intel Xeon (go test -bench GenerateDashboard -benchmem):
+//
+//	BenchmarkGenerateDashboard    100    11933065 ns/op    4064592 B/op    63539 allocs/op
+func BenchmarkGenerateDashboard(b *testing.B) {
+	cfg := fixtureConfig(time.Date(2025, time.March, 14, 9, 30, 0, 0, time.UTC))
+
+	// The fonts and icons are loaded once and cached.
+	if _, err := GenerateDashboard(cfg); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := GenerateDashboard(cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDrawStackedWidgets(t *testing.T) {
+	config := &DashboardConfig{Width: 480, Padding: 10}
+
+	var drawn []string
+	widget := func(name string, height int) stackedWidget {
+		return stackedWidget{name, height, func(rect image.Rectangle) error {
+			if rect.Dy() != height {
+				t.Errorf("%s is %d pixels high, want %d", name, rect.Dy(), height)
+			}
+			drawn = append(drawn, name)
+			return nil
+		}}
+	}
+
+	// 100 + 8 + 60 + 8 leave 24 pixels for the third widget.
+	widgets := []stackedWidget{widget("steps", 100), widget("sleep", 60), widget("todos", 40), widget("word", 10)}
+	top, err := drawStackedWidgets(nil, config, widgets, 400, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"steps", "sleep"}; !slices.Equal(drawn, want) {
+		t.Errorf("drawn %v, want %v", drawn, want)
+	}
+	if top != 424 {
+		t.Errorf("top of the stack is %d, want 424", top)
+	}
+
+	// A widget may end right at the top.
+	drawn = nil
+	if top, _ = drawStackedWidgets(nil, config, widgets[:1], 500, 600); top != 492 || len(drawn) != 1 {
+		t.Errorf("drawn %v with top %d, want steps with top 492", drawn, top)
+	}
+}
+
+func TestRefreshTimeLabel(t *testing.T) {
+	berlin := loadBerlin(t)
+	now := time.Date(2025, time.March, 14, 22, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		locale   Locale
+		format   TimeFormat
+		location *time.Location
+		want     string
+	}{
+		{LocaleGerman, TimeFormat24h, berlin, "Aktualisiert: 23:30 Uhr"},
+		{LocaleGerman, TimeFormat24h, nil, "Aktualisiert: 22:30 Uhr"},
+		{LocaleEnglish, TimeFormat12h, berlin, "Updated: " + TimeFormat12h.Clock(now.In(berlin))},
+	}
+
+	for _, tt := range tests {
+		config := &DashboardConfig{Locale: tt.locale, TimeFormat: tt.format, Location: tt.location}
+		if got := refreshTimeLabel(config, now); got != tt.want {
+			t.Errorf("refreshTimeLabel(%s, %s, %v) = %q, want %q", tt.locale, tt.format, tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestWeatherCodeInfo(t *testing.T) {
+	for code, info := range weatherCodeInfo {
+		w := Weather{WeatherCode: ptr(int32(code))}
+
+		if info.Icon == "" {
+			t.Errorf("code %d: no icon", code)
+		} else if _, err := fs.Stat(embeddedIcons(), w.Icon()); err != nil {
+			t.Errorf("code %d: icon %s is not embedded: %v", code, w.Icon(), err)
+		}
+
+		for _, locale := range []Locale{LocaleGerman, LocaleEnglish} {
+			condition := w.Condition(locale)
+			if condition == "" || condition == unknownConditions[locale] {
+				t.Errorf("code %d: condition in %s = %q", code, locale, condition)
+			}
+		}
+	}
+}
+
+func TestUnknownWeatherCodeWarnsOnce(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+	warnedWeatherCodes.Clear()
+
+	// Every render asks for the icon and the condition again.
+	for _, code := range []int32{1234, 1234, 1235} {
+		w := Weather{WeatherCode: ptr(code)}
+		for range 3 {
+			if got := w.Icon(); got != "weather/unknown.png" {
+				t.Errorf("icon of code %d = %s, want the unknown icon", code, got)
+			}
+			if got := w.Condition(LocaleGerman); got != unknownConditions[LocaleGerman] {
+				t.Errorf("condition of code %d = %q, want the unknown condition", code, got)
+			}
+		}
+	}
+
+	if got := strings.Count(logs.String(), "unknown weather code"); got != 2 {
+		t.Errorf("got %d warnings, want one per code:\n%s", got, logs.String())
+	}
+}
+
+func TestRenderGraphNextDay(t *testing.T) {
+	tests := []struct {
+		name  string
+		start time.Time
+	}{
+		{name: "graph_same_day", start: time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC)},
+		{name: "graph_next_day", start: time.Date(2024, 3, 15, 21, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forecast WeatherForecast
+			for i := range forecastItems {
+				ts := tt.start.Add(time.Duration(i) * time.Hour)
+				forecast = append(forecast, Weather{
+					Label:            ts.Format("15"),
+					Timestamp:        ts,
+					TemperatureHigh:  ptr(10 - float64(i)),
+					PrecipitationSum: ptr(float64(i%3) * 0.4),
+				})
+			}
+
+			dc := newDashboardCanvas(graphWidth+20, graphHeight, false)
+			dc.SetColor(color.White)
+			dc.Clear()
+			if err := renderGraph(dc, 0, 0, graphHeight, forecast, LocaleGerman.WeekdayAbbreviations()); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.name, dc.Image())
+		})
+	}
+}
+
+func TestForecastSummary(t *testing.T) {
+	forecast := WeatherForecast{
+		{TemperatureLow: ptr(3.5), TemperatureHigh: ptr(8.0), PrecipitationSum: ptr(1.2)},
+		{TemperatureLow: ptr(-1.0), TemperatureHigh: ptr(12.5)},
+		{PrecipitationSum: ptr(0.3)},
+	}
+
+	if got := forecast.MinTemp(); got == nil || *got != -1 {
+		t.Errorf("MinTemp = %v, want -1", got)
+	}
+	if got := forecast.MaxTemp(); got == nil || *got != 12.5 {
+		t.Errorf("MaxTemp = %v, want 12.5", got)
+	}
+	if got := forecast.TotalPrecipitation(); got != 1.5 {
+		t.Errorf("TotalPrecipitation = %v, want 1.5", got)
+	}
+
+	var empty WeatherForecast
+	if empty.MinTemp() != nil || empty.MaxTemp() != nil || empty.TotalPrecipitation() != 0 {
+		t.Error("the summary of an empty forecast is not empty")
+	}
+}
+
+func TestWrapString(t *testing.T) {
+	dc := gg.NewContext(EPD_WIDTH, EPD_HEIGHT)
+	if err := setFont(dc, FontRegular, FontSizeSM); err != nil {
+		t.Fatal(err)
+	}
+	// The widths are measured, so the test doesn't depend on the font.
+	width := func(s string) float64 {
+		w, _ := dc.MeasureString(s)
+		return w
+	}
+
+	tests := []struct {
+		name     string
+		s        string
+		maxWidth float64
+		maxLines int
+		want     []string
+	}{
+		{name: "fits", s: "Zahnarzt", maxWidth: 300, maxLines: 2, want: []string{"Zahnarzt"}},
+		{
+			name:     "two lines",
+			s:        "Elternabend Klasse 3b Grundschule",
+			maxWidth: width("Elternabend Klasse 3b"),
+			maxLines: 2,
+			want:     []string{"Elternabend Klasse 3b", "Grundschule"},
+		},
+		{
+			name:     "unlimited lines",
+			s:        "Elternabend Klasse 3b Grundschule",
+			maxWidth: max(width("Elternabend"), width("Grundschule")),
+			want:     []string{"Elternabend", "Klasse 3b", "Grundschule"},
+		},
+		// A word longer than a line is cut off.
+		{
+			name:     "long word",
+			s:        "Donaudampfschifffahrt",
+			maxWidth: width("Donaudampf"),
+			maxLines: 2,
+			want:     []string{"Donaudampf"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapString(dc, tt.s, tt.maxWidth, tt.maxLines); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The rest of the text is shortened to fit into the last line.
+	maxWidth := width("Elternabend Klasse")
+	lines := wrapString(dc, "Elternabend Klasse 3b der Grundschule am Park", maxWidth, 2)
+	if len(lines) != 2 || lines[0] != "Elternabend Klasse" {
+		t.Fatalf("got %q, want two lines starting with %q", lines, "Elternabend Klasse")
+	}
+	if !strings.HasPrefix(lines[1], "3b der") || !strings.HasSuffix(lines[1], "...") {
+		t.Errorf("got last line %q, want the shortened rest with an ellipsis", lines[1])
+	}
+	for _, line := range lines {
+		if w := width(line); w > maxWidth {
+			t.Errorf("line %q is %.1f wide, want at most %.1f", line, w, maxWidth)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"time"
+
+	"github.com/arran4/golang-ical"
+)
+
+// jsonEvent is an event in the file of a JSON calendar, e.g.:
+//
+//	{"title": "Meeting", "start": "2024-01-15T14:00:00Z", "end": "2024-01-15T15:00:00Z", "tag": "Work", "color": "blue"}
+//
+// end, tag and color are optional. The tag and the color default to the
+// calendar's name and color.
+type jsonEvent struct {
+	Title string     `json:"title"`
+	Start time.Time  `json:"start"`
+	End   time.Time  `json:"end"`
+	Tag   string     `json:"tag"`
+	Color *tomlColor `json:"color"`
+}
+
+// JSONCalendar reads the events from a local JSON file with an array of
+// events instead of an iCal feed. It is useful without network access and
+// for events generated by other tools.
+type JSONCalendar struct {
+	Path  string
+	Name  string
+	Color color.Color
+
+	events  []jsonEvent
+	fetched bool
+}
+
+// NewJSONCalendar creates a calendar for the JSON file at path.
+func NewJSONCalendar(name string, col color.Color, path string) *JSONCalendar {
+	return &JSONCalendar{
+		Name:  name,
+		Path:  path,
+		Color: col,
+	}
+}
+
+// CalendarName returns the name of the calendar.
+func (c *JSONCalendar) CalendarName() string {
+	return c.Name
+}
+
+// Fetch reads the file unless it was read before.
+func (c *JSONCalendar) Fetch(ctx context.Context) error {
+	if c.fetched {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read calendar file: %w", err)
+	}
+
+	var events []jsonEvent
+	if err = json.Unmarshal(data, &events); err != nil {
+		return fmt.Errorf("failed to parse calendar file %s: %w", c.Path, err)
+	}
+
+	c.fetched = true
+	c.events = events
+
+	return nil
+}
+
+// Refresh reads the file again, even if it was read before.
+func (c *JSONCalendar) Refresh(ctx context.Context) error {
+	c.fetched = false
+	return c.Fetch(ctx)
+}
+
+// FutureEvents returns the events that start between now and until.
+// The start times are converted to location.
+func (c *JSONCalendar) FutureEvents(ctx context.Context, until time.Time, location *time.Location) ([]CalendarEvent, error) {
+	err := c.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch future events: %w", err)
+	}
+
+	var futureEvents []CalendarEvent
+
+	now := time.Now()
+	for i, event := range c.events {
+		if event.Start.Before(now) || !event.Start.Before(until) {
+			continue
+		}
+
+		vevent := ics.NewEvent(fmt.Sprintf("%s#%d", c.Path, i))
+		vevent.SetSummary(event.Title)
+		vevent.SetStartAt(event.Start)
+		if !event.End.IsZero() {
+			vevent.SetEndAt(event.End)
+		}
+
+		calendarEvent := CalendarEvent{
+			VEvent: vevent,
+			Start:  event.Start.In(location),
+			Tag:    c.Name,
+			Color:  c.Color,
+		}
+		if event.Tag != "" {
+			calendarEvent.Tag = event.Tag
+		}
+		if event.Color != nil {
+			calendarEvent.Color = event.Color.color
+		}
+
+		futureEvents = append(futureEvents, calendarEvent)
+	}
+
+	return futureEvents, nil
+}
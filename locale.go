@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Locale selects the language of the generated dashboard.
+type Locale string
+
+const (
+	// LocaleGerman is the default locale
+	LocaleGerman Locale = "de"
+	// LocaleEnglish uses English names for days and months
+	LocaleEnglish Locale = "en"
+)
+
+// weekdayAbbreviations holds the short weekday names per locale, starting with Sunday.
+var weekdayAbbreviations = map[Locale][7]string{
+	LocaleGerman:  {"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	LocaleEnglish: {"Su", "Mo", "Tu", "We", "Th", "Fr", "Sa"},
+}
+
+// UnmarshalText validates a locale from the config file.
+func (l *Locale) UnmarshalText(text []byte) error {
+	switch Locale(text) {
+	case LocaleGerman, LocaleEnglish:
+		*l = Locale(text)
+	case "":
+		*l = LocaleGerman
+	default:
+		return fmt.Errorf("invalid locale: %s (expected de or en)", string(text))
+	}
+
+	return nil
+}
+
+// WeekdayAbbreviations returns the short weekday names of the locale, starting with Sunday.
+// Unknown locales fall back to German.
+func (l Locale) WeekdayAbbreviations() [7]string {
+	if abbreviations, ok := weekdayAbbreviations[l]; ok {
+		return abbreviations
+	}
+	return weekdayAbbreviations[LocaleGerman]
+}
+
+// TimeFormat selects between the 24-hour and the 12-hour clock.
+type TimeFormat string
+
+const (
+	// TimeFormat24h formats times as "15:04"
+	TimeFormat24h TimeFormat = "24h"
+	// TimeFormat12h formats times as "3:04 PM"
+	TimeFormat12h TimeFormat = "12h"
+)
+
+// UnmarshalText validates a time format from the config file.
+func (f *TimeFormat) UnmarshalText(text []byte) error {
+	switch TimeFormat(text) {
+	case TimeFormat24h, TimeFormat12h:
+		*f = TimeFormat(text)
+	case "":
+		*f = TimeFormat24h
+	default:
+		return fmt.Errorf("invalid time format: %s (expected 24h or 12h)", string(text))
+	}
+
+	return nil
+}
+
+// Clock formats the time of day of t (e.g., "15:04" or "3:04 PM").
+// An empty TimeFormat falls back to the 24-hour clock.
+func (f TimeFormat) Clock(t time.Time) string {
+	if f == TimeFormat12h {
+		return t.Format("3:04 PM")
+	}
+	return t.Format("15:04")
+}
+
+// Hour formats only the hour of t (e.g., "15" or "3PM").
+// An empty TimeFormat falls back to the 24-hour clock.
+func (f TimeFormat) Hour(t time.Time) string {
+	if f == TimeFormat12h {
+		return t.Format("3PM")
+	}
+	return t.Format("15")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultLockFile is used if no lock file is configured.
+const defaultLockFile = "/run/epd-dashboard.lock"
+
+// ErrDisplayLocked is returned if another instance holds the lock of the
+// display.
+var ErrDisplayLocked = errors.New("the display is locked by another instance")
+
+// lockFile is an exclusive lock that prevents two instances from driving
+// the display at the same time. The lock is held with flock, so the kernel
+// releases it when a crashed process exits and stale lock files are harmless.
+// The PID written to the file is for information only.
+type lockFile struct {
+	f *os.File
+}
+
+// acquireLock locks the file at path. If another instance holds the lock,
+// it retries until wait elapsed or ctx is cancelled.
+func acquireLock(ctx context.Context, path string, wait time.Duration) (*lockFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w (lock %s held by pid %s)", ErrDisplayLocked, path, lockHolder(path))
+		}
+
+		if err = sleepContext(ctx, 250*time.Millisecond); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	// Record our PID to help debugging, failures are not critical.
+	if err = f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &lockFile{f: f}, nil
+}
+
+// Release unlocks and closes the lock file. The file itself is kept so that
+// a waiting instance keeps locking the same inode.
+func (l *lockFile) Release() error {
+	if err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// lockHolder returns the PID recorded in the lock file or "unknown".
+func lockHolder(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "unknown"
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return "unknown"
+	}
+	return strconv.Itoa(pid)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireLockContention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "epd.lock")
+
+	first, err := acquireLock(t.Context(), path, 0)
+	if err != nil {
+		t.Fatalf("failed to acquire the free lock: %v", err)
+	}
+
+	// The second locker gives up after the wait.
+	start := time.Now()
+	_, err = acquireLock(t.Context(), path, 300*time.Millisecond)
+	if !errors.Is(err, ErrDisplayLocked) {
+		t.Fatalf("got error %v, want ErrDisplayLocked", err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("gave up after %v, want at least the wait of 300ms", elapsed)
+	}
+	if pid := strconv.Itoa(os.Getpid()); !strings.Contains(err.Error(), "pid "+pid) {
+		t.Errorf("got error %q, want the pid %s of the holder", err, pid)
+	}
+
+	// A cancelled context stops the waiting.
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	if _, err = acquireLock(ctx, path, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the context error", err)
+	}
+
+	// The second locker gets the lock once the first releases it.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if err := first.Release(); err != nil {
+			t.Errorf("failed to release the lock: %v", err)
+		}
+	}()
+	second, err := acquireLock(t.Context(), path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to acquire the released lock: %v", err)
+	}
+	if err = second.Release(); err != nil {
+		t.Errorf("failed to release the lock: %v", err)
+	}
+}
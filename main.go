@@ -1,14 +1,22 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"embed"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"image"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/BurntSushi/toml"
 	ics "github.com/arran4/golang-ical"
+	"github.com/fogleman/gg"
 	"github.com/ophusdev/openmeteogo"
 )
 
@@ -17,10 +25,19 @@ var (
 	fontsFS embed.FS
 	//go:embed icons
 	iconsFS embed.FS
-	//go:embed config/config.toml
+	// The config and the optional weather_descriptions.toml next to it.
+	//go:embed config/*.toml
 	configFS embed.FS
 )
 
+// Version and BuildDate identify the binary. They are set at build time:
+//
+//	go build -ldflags "-X main.Version=1.2.3 -X main.BuildDate=2024-01-15"
+var (
+	Version   = "dev"
+	BuildDate = ""
+)
+
 // Define the GPIO pins used for the display.
 const (
 	resetPin = 11 // Replace with your actual reset pin number (BCM)
@@ -29,182 +46,513 @@ const (
 	csPin    = 24 // Replace with your actual chip select pin number (BCM)
 
 	calendarEventCount = 7 // Number of calendar events to display
+
+	appointmentHorizon = 14 * 24 * time.Hour // How far ahead appointments are shown
+)
+
+// Exit codes reported to the service manager.
+const (
+	exitOK      = 0
+	exitFailure = 1 // Unclassified failure
+	exitConfig  = 2 // The config could not be loaded
+	exitFetch   = 3 // The data sources could not be fetched
+	exitRender  = 4 // The dashboard could not be rendered
+	exitDisplay = 5 // The display could not be updated
+	exitLocked  = 6 // Another instance is running
+)
+
+var (
+	verbose  = flag.Bool("verbose", false, "enable debug logging")
+	daemon   = flag.Bool("daemon", false, "keep running and refresh the display periodically")
+	lockWait = flag.Int("lock-wait", 0, "seconds to wait for another running instance to finish")
+	simulate = flag.String("simulate", "", "write the frames to this PNG file instead of the display")
+	overlay  = flag.Bool("debug-overlay", false, "outline the strings, images and sections (for layout work)")
+	output   = flag.String("output", "", "file the rendered dashboard is written to, - for standard output (default output.path or dash.png)")
+	force    = flag.Bool("force", false, "update the display even during the quiet hours")
+	take     = flag.String("take", "", "mark the current dose of this medication as taken and exit")
+	pattern  = flag.Bool("test-pattern", false, "show a test pattern to check the wiring and exit")
+	clean    = flag.Bool("deep-clean", false, "run a deep clean of the display against ghosting and exit")
+	format   = flag.String("format", OutputPNG, "format of the output file: png, bmp or raw (the panel buffer)")
 )
 
 func main() {
-	ctx := context.Background()
+	flag.Parse()
 
+	// Cancel the context on SIGINT or SIGTERM so that pending fetches are
+	// aborted and the display is put to sleep safely.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx)
+	stop()
+	if err != nil {
+		slog.Error(err.Error())
+		_ = sdNotify("STATUS=" + err.Error())
+	}
+
+	os.Exit(exitCode(err))
+}
+
+// run loads the config, fetches the data, renders the dashboard and
+// sends it to the display. The returned error carries an exit code.
+func run(ctx context.Context) error {
 	// Load the configuration from a TOML file.
 	cfgBytes, err := configFS.ReadFile("config/config.toml")
 	if err != nil {
-		log.Fatalf("failed to load config file: %v", err)
+		return withExitCode(exitConfig, fmt.Errorf("failed to load config file: %w", err))
+	}
+
+	if !isOutputFormat(*format) {
+		return withExitCode(exitConfig, fmt.Errorf("unknown output format: %s (expected png, bmp or raw)", *format))
+	}
+
+	cfg, err := parseConfig(cfgBytes)
+	if err != nil {
+		return withExitCode(exitConfig, fmt.Errorf("failed to load config: %w", err))
+	}
+
+	level, err := cfg.LogLevel()
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+
+	if err = cfg.Validate(); err != nil {
+		logConfigProblems(err)
+		return withExitCode(exitConfig, errors.New("invalid config"))
+	}
+	if cfg.Network.TLSSkipVerify {
+		slog.Warn("SECURITY WARNING: tls_skip_verify is set, server certificates are not verified and the connections can be intercepted")
+	}
+
+	if *take != "" {
+		dose, err := takeMedication(cfg.Medications, *take, cmp.Or(cfg.Medication.TakenFile, defaultMedicationTakenFile), time.Now())
+		if err != nil {
+			return withExitCode(exitConfig, err)
+		}
+		slog.Info("marked medication as taken", "name", dose.Name, "time", dose.Time.Format(medicationTimeFormat))
+		return nil
 	}
 
-	var cfg config
-	if _, err = toml.Decode(string(cfgBytes), &cfg); err != nil {
-		log.Fatalf("failed to load config: %v", err)
+	lockPath := cfg.LockFile
+	if lockPath == "" {
+		lockPath = defaultLockFile
 	}
 
-	if cfg.Timezone == "" {
-		log.Fatal("timezone is not set in the config")
+	lock, err := acquireLock(ctx, lockPath, time.Duration(*lockWait)*time.Second)
+	if err != nil {
+		return withExitCode(exitLocked, err)
 	}
+	defer lock.Release()
 
 	location, err := time.LoadLocation(cfg.Timezone)
 	if err != nil {
-		log.Fatalf("failed to load timezone: %v", err)
+		return withExitCode(exitConfig, fmt.Errorf("failed to load timezone: %w", err))
 	}
 
-	client := openmeteogo.NewClient(nil)
+	dashboardConfig, err := newDashboardConfig(cfg)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
 
-	appointments, err := buildAppointments(cfg.GetCalendars(), location)
+	if err = useFontFiles(cfg.FontFiles()); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+
+	if cfg.IconsDir != "" {
+		if err = useIconsDir(cfg.IconsDir); err != nil {
+			return withExitCode(exitConfig, err)
+		}
+	}
+	if err = useWeatherIconMapping(); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	if err = useWeatherDescriptions(); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+
+	if *pattern {
+		img, err := testPattern()
+		if err != nil {
+			return withExitCode(exitRender, fmt.Errorf("failed to draw test pattern: %w", err))
+		}
+
+		epd, err := openDisplay(cfg, logger)
+		if err != nil {
+			return withExitCode(exitDisplay, fmt.Errorf("failed to connect to display: %w", err))
+		}
+		defer epd.Sleep()
+
+		if err = updateDisplay(ctx, epd, img); err != nil {
+			return withExitCode(exitDisplay, err)
+		}
+		return nil
+	}
+
+	if *clean {
+		epd, err := openDisplay(cfg, logger)
+		if err != nil {
+			return withExitCode(exitDisplay, fmt.Errorf("failed to connect to display: %w", err))
+		}
+		if err = deepClean(ctx, epd); err != nil {
+			return withExitCode(exitDisplay, err)
+		}
+		return nil
+	}
+
+	if *daemon {
+		return runDaemon(ctx, cfg, location, logger)
+	}
+
+	if cfg.Schedule.QuietHours.Contains(time.Now().In(location)) && !*force {
+		slog.Info("quiet hours, not updating the display")
+		return nil
+	}
+
+	_ = sdNotify("READY=1\nSTATUS=Fetching data")
+
+	var canvas *gg.Context
+	data, renderErr := fetchData(ctx, cfg, location)
+	if renderErr != nil {
+		renderErr = withExitCode(exitFetch, fmt.Errorf("failed to fetch data: %w", renderErr))
+	} else {
+		canvas, renderErr = renderDashboard(data, dashboardConfig)
+	}
+
+	if renderErr != nil && ctx.Err() != nil {
+		// Interrupted, don't touch the display.
+		return renderErr
+	}
+
+	_ = sdNotify("STATUS=Updating display")
+
+	epd, err := openDisplay(cfg, logger)
 	if err != nil {
-		log.Fatalf("failed to build appointments: %v", err)
+		if renderErr != nil {
+			return renderErr
+		}
+		return withExitCode(exitDisplay, fmt.Errorf("failed to connect to display: %w", err))
 	}
 
-	weatherOptions := openmeteogo.Options{
-		Timezone:          openmeteogo.TimezoneBerlin,
-		TemperatureUnit:   openmeteogo.TemperatureUnitCelsius,
-		PrecipitationUnit: openmeteogo.PrecipitationUnitMm,
-		TimeFormat:        openmeteogo.TimeFormatIso8601,
+	if err = presentAndSleep(ctx, epd, canvas, renderErr, dashboardConfig); err != nil {
+		return err
 	}
 
-	dailyOpts := &openmeteogo.DailyOptions{
-		Latitude:     cfg.Weather.Latitude,
-		Longitude:    cfg.Weather.Longitude,
-		ForecastDays: 8,
-		Options:      weatherOptions,
-		Daily: &[]openmeteogo.OpenMeteoConst{
-			openmeteogo.DailyWeatherCode,
-			openmeteogo.DailyTemperature2mMax,
-			openmeteogo.DailyTemperature2mMin,
-			openmeteogo.DailySunrise,
-			openmeteogo.DailySunset,
-			openmeteogo.DailyPrecipitationSum,
-			openmeteogo.DailyPrecipitationProbabilityMax,
-		},
+	_ = sdNotify("STATUS=Display updated")
+
+	return nil
+}
+
+// presentAndSleep shows the dashboard with present and always leaves the
+// panel in deep sleep, also if the update failed or was interrupted.
+func presentAndSleep(ctx context.Context, epd Display, canvas *gg.Context, renderErr error, dashboardConfig *DashboardConfig) error {
+	defer func() {
+		slog.Debug("putting the display to sleep")
+		epd.Sleep()
+	}()
+
+	return present(ctx, epd, canvas, renderErr, dashboardConfig)
+}
+
+// present shows the dashboard on the display. If rendering failed, an error
+// screen is shown instead, otherwise the old content stays visible and nobody
+// notices the failure. renderErr is returned in this case.
+func present(ctx context.Context, epd Display, canvas *gg.Context, renderErr error, dashboardConfig *DashboardConfig) error {
+	if renderErr != nil {
+		errorCanvas, err := GenerateErrorScreen(renderErr, dashboardConfig)
+		if err != nil {
+			slog.Error("failed to generate error screen", "error", err)
+			return renderErr
+		}
+
+		if err = updateDisplay(ctx, epd, errorCanvas.Image()); err != nil {
+			slog.Error("failed to display error screen", "error", err)
+		}
+
+		return renderErr
+	}
+
+	img := canvas.Image()
+	if dashboardConfig.Grayscale {
+		img = ditherOrdered(img, dashboardConfig.DitherSize)
 	}
 
-	dailyWeather, err := client.DailyWeather.Forecast(ctx, dailyOpts)
+	displayStart := time.Now()
+	err := updateDisplay(ctx, epd, img)
+	metrics.observePhase(phaseDisplay, time.Since(displayStart), err)
 	if err != nil {
-		log.Fatal(err)
+		return withExitCode(exitDisplay, err)
 	}
+	slog.Info("updated display", "duration", time.Since(displayStart))
 
-	hourlyOpts := &openmeteogo.HourlyOptions{
-		Latitude:     cfg.Weather.Latitude,
-		Longitude:    cfg.Weather.Longitude,
-		ForecastDays: 2,
-		Options:      weatherOptions,
-		Hourly: &[]openmeteogo.OpenMeteoConst{
-			openmeteogo.HourlyWeathercode,
-			openmeteogo.HourlyTemperature2m,
-			openmeteogo.HourlyPrecipitation,
-			openmeteogo.HourlyPrecipitationProbability,
-		},
+	return nil
+}
+
+// newDashboardConfig creates the dashboard config from the user's config.
+func newDashboardConfig(cfg config) (*DashboardConfig, error) {
+	weekdays, err := cfg.GetWeekdayAbbreviations()
+	if err != nil {
+		return nil, err
 	}
 
-	hourlyWeather, err := client.HourlyWeather.Forecast(ctx, hourlyOpts)
+	location, err := time.LoadLocation(cfg.Timezone)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to load timezone: %w", err)
 	}
 
 	dashboardConfig := NewDefaultConfig()
+	dashboardConfig.Location = location
+	if cfg.Locale != "" {
+		dashboardConfig.Locale = cfg.Locale
+	}
+	if cfg.TimeFormat != "" {
+		dashboardConfig.TimeFormat = cfg.TimeFormat
+	}
+	dashboardConfig.DateFormat = cfg.DateFormat
+	dashboardConfig.ShowVersion = cfg.ShowVersion
+	dashboardConfig.ShowRefreshTime = cfg.ShowRefreshTime
+	dashboardConfig.AppointmentView = cfg.Appointments.View
+	dashboardConfig.EmptyAppointments = cfg.Appointments.Empty
+	dashboardConfig.WrapAppointmentTitles = cfg.Appointments.WrapTitles
+	dashboardConfig.ShowEventCountdown = cfg.Appointments.ShowCountdown
+	dashboardConfig.ShowMiniMonth = cfg.Layout.ShowMiniMonth
+	if cfg.Appointments.TitleMaxChars > 0 {
+		dashboardConfig.AppointmentTitleMaxChars = cfg.Appointments.TitleMaxChars
+	}
+	dashboardConfig.QuoteMaxChars = cfg.Quote.MaxChars
+	dashboardConfig.SeparatorStyle = cfg.Layout.Separator
+	dashboardConfig.ShowNetworkStatus = cfg.Layout.ShowNetworkStatus
+	dashboardConfig.ShowWiFiSignal = cfg.Layout.ShowWiFiSignal
+	dashboardConfig.MaxTodos = cfg.MaxTodos()
+	dashboardConfig.MaxDepartures = cfg.MaxDepartures()
+	dashboardConfig.WordReplacesQuote = cfg.Word.ReplaceQuote
+	dashboardConfig.PhotoDir = cfg.Photo.Dir
+	dashboardConfig.PhotoRect = image.Rect(cfg.Photo.X, cfg.Photo.Y, cfg.Photo.X+cfg.Photo.Width, cfg.Photo.Y+cfg.Photo.Height)
+	dashboardConfig.ShowYearProgress = cfg.Layout.ShowYearProgress
+	dashboardConfig.ShowMonthProgress = cfg.Layout.ShowMonthProgress
+	dashboardConfig.ShowDayProgress = cfg.Layout.ShowDayProgress || !cfg.meta.IsDefined("layout", "show_day_progress")
+	dashboardConfig.RainChart = cfg.Weather.RainChart
+	dashboardConfig.HistoricalDays = cfg.Weather.HistoricalDays
+	dashboardConfig.ShowPressure = cfg.Layout.ShowPressure
+	dashboardConfig.ShowAQI = cfg.Layout.ShowAQI
+	dashboardConfig.Grayscale = cfg.Render.Grayscale
+	dashboardConfig.DitherSize = cfg.Render.DitherSize.Or(defaultDitherSize)
+	dashboardConfig.Clocks = clocksFrom(cfg.Timezones)
+	dashboardConfig.GarbageSchedule = cfg.GarbageSchedule
+	dashboardConfig.MedicationSchedule = cfg.Medications
+	dashboardConfig.MedicationLookahead = cfg.Medication.Lookahead.Or(defaultMedicationLookahead)
+	dashboardConfig.MedicationTakenFile = cmp.Or(cfg.Medication.TakenFile, defaultMedicationTakenFile)
+	dashboardConfig.RefreshInterval = cfg.Interval()
+	dashboardConfig.OutputPath = cmp.Or(*output, cfg.Output.Path, defaultOutputPath)
+	dashboardConfig.OutputHistory = cfg.Output.History
+	dashboardConfig.ArchiveDir = cfg.Archive.Dir
+	dashboardConfig.ArchiveMaxAgeDays = cfg.Archive.MaxAgeDays
+	dashboardConfig.WeekdayAbbreviations = weekdays
+	dashboardConfig.DebugOverlay = *overlay
+
+	return dashboardConfig, nil
+}
 
-	fetchedQuote, err := fetchQuoteRetry(10)
-	if err != nil {
-		log.Fatal(err)
+// renderDashboard renders the dashboard from the fetched data and saves it to the output file.
+// The returned error carries an exit code.
+func renderDashboard(data *dashboardData, dashboardConfig *DashboardConfig) (*gg.Context, error) {
+	if data.QuoteErr != nil {
+		slog.Warn("failed to fetch quote", "error", data.QuoteErr)
+	}
+
+	if dashboardConfig.ArchiveDir != "" {
+		err := pruneArchive(dashboardConfig.ArchiveDir, dashboardConfig.ArchiveMaxAgeDays, time.Now())
+		if err != nil {
+			slog.Warn("failed to clean up archive", "error", err)
+		}
 	}
 
-	dashboardConfig.Quote = fetchedQuote
-	dashboardConfig.Appointments = appointments
-	dashboardConfig.Weather = Weather{
-		TemperatureLow:           dailyWeather.Daily.Temperature2mMin[0],
-		TemperatureHigh:          dailyWeather.Daily.Temperature2mMax[0],
-		WeatherCode:              dailyWeather.Daily.WeatherCode[0],
-		Sunrise:                  parseTime(dailyWeather.Daily.Sunrise[0]),
-		Sunset:                   parseTime(dailyWeather.Daily.Sunset[0]),
-		PrecipitationSum:         dailyWeather.Daily.PrecipitationSum[0],
-		PrecipitationProbability: dailyWeather.Daily.PrecipitationProbabilityMax[0],
+	dailyWeather := data.DailyWeather
+	hourlyWeather := data.HourlyWeather
+
+	dashboardConfig.Quote = data.Quote
+	dashboardConfig.DataUpdated = data.Updated
+	dashboardConfig.Network = data.Network
+	dashboardConfig.Steps = data.Steps
+	// Only the sleep of the last night is shown, not an older one of a
+	// watch that wasn't synced.
+	dashboardConfig.Sleep = nil
+	if data.Sleep != nil && daysUntil(time.Now(), data.Sleep.End.In(dashboardConfig.Location)) == 0 {
+		dashboardConfig.Sleep = data.Sleep
+	}
+	dashboardConfig.ExchangeRates = data.ExchangeRates
+	dashboardConfig.Pollen = data.Pollen
+	dashboardConfig.Todos = nextTodos(data.Todos, dashboardConfig.MaxTodos)
+	dashboardConfig.Word = data.Word
+	dashboardConfig.Departures = upcomingDepartures(data.Departures, time.Now(), dashboardConfig.MaxDepartures)
+	dashboardConfig.AirQuality = data.AirQuality
+	dashboardConfig.Garbage = dueGarbagePickups(dashboardConfig.GarbageSchedule, time.Now())
+	dashboardConfig.Medications = medicationDoses(dashboardConfig, time.Now())
+	dashboardConfig.Appointments = data.Appointments
+	dashboardConfig.AppointmentTotal = data.AppointmentTotal
+	location := dashboardConfig.Location
+	dashboardConfig.Weather = todayWeather(dailyWeather, location)
+	dashboardConfig.Weather.Pressure, dashboardConfig.Weather.PressureChange = currentPressure(hourlyWeather, time.Now(), location)
+	dashboardConfig.Locations = nil
+	for _, location := range data.Locations {
+		if len(location.Daily.Daily.Time) == 0 {
+			slog.Warn("no weather for location", "location", location.Name)
+			continue
+		}
+		dashboardConfig.Locations = append(dashboardConfig.Locations, LocationWeather{
+			Name:    location.Name,
+			Weather: todayWeather(location.Daily, dashboardConfig.Location),
+		})
 	}
 
-	// Show the daily forecast in the evening.
-	if time.Now().Hour() >= 15 {
-		dailyWeatherData, err := DailyWeatherFrom(dailyWeather)
+	if showDailyForecast(time.Now().In(location), dashboardConfig.RainChart) {
+		dailyWeatherData, err := DailyWeatherFrom(dailyWeather, time.Now(), location, dashboardConfig.WeekdayAbbreviations, forecastItems)
 		if err != nil {
-			log.Fatal(err)
+			return nil, withExitCode(exitFetch, fmt.Errorf("failed to convert daily weather: %w", err))
 		}
 
 		dashboardConfig.WeatherForecast = dailyWeatherData
 	} else {
-		hourlyWeatherData, err := HourlyWeatherFrom(hourlyWeather)
+		hours := forecastItems
+		if dashboardConfig.RainChart {
+			hours = rainChartHours
+		}
+
+		hourlyWeatherData, err := HourlyWeatherFrom(hourlyWeather, time.Now(), location, dashboardConfig.TimeFormat, hours)
 		if err != nil {
-			log.Fatal(err)
+			return nil, withExitCode(exitFetch, fmt.Errorf("failed to convert hourly weather: %w", err))
 		}
 
 		dashboardConfig.WeatherForecast = hourlyWeatherData
 	}
 
+	dashboardConfig.History = nil
+	if dashboardConfig.HistoricalDays > 0 && data.HistoricalWeather != nil {
+		// The zero time keeps every past day.
+		history, err := DailyWeatherFrom(data.HistoricalWeather, time.Time{}, location, dashboardConfig.WeekdayAbbreviations, dashboardConfig.HistoricalDays)
+		if err != nil {
+			slog.Warn("failed to convert historical weather", "error", err)
+		} else {
+			dashboardConfig.History = history
+		}
+	}
+
+	_ = sdNotify("STATUS=Rendering dashboard")
+
+	renderStart := time.Now()
 	canvas, err := GenerateDashboard(dashboardConfig)
 	if err != nil {
-		fmt.Println("Error generating dashboard:", err)
-		return
+		err = fmt.Errorf("failed to generate dashboard: %w", err)
+	} else if err = saveDashboard(canvas.Image(), dashboardConfig.OutputPath, *format); err != nil {
+		err = fmt.Errorf("failed to save dashboard image: %w", err)
 	}
-
-	err = canvas.SavePNG("dash.png")
+	metrics.observePhase(phaseRender, time.Since(renderStart), err)
 	if err != nil {
-		fmt.Println("Error saving dashboard image:", err)
-		return
+		return nil, withExitCode(exitRender, err)
 	}
+	slog.Info("rendered dashboard", "duration", time.Since(renderStart))
 
-	epd, err := New(pin(dcPin), pin(csPin), pin(resetPin), pin(busyPin))
-	if err != nil {
-		log.Fatalf("failed to connect to display: %v", err)
+	if dashboardConfig.OutputHistory > 0 && dashboardConfig.OutputPath != "-" {
+		err = keepHistory(canvas.Image(), dashboardConfig.OutputPath, *format, dashboardConfig.OutputHistory, renderStart)
+		if err != nil {
+			slog.Warn("failed to keep render", "error", err)
+		}
+	}
+
+	// The archive is for review only, so a failure must not keep the display from updating.
+	if dashboardConfig.ArchiveDir != "" {
+		if err = archiveDashboard(canvas, dashboardConfig.ArchiveDir, renderStart); err != nil {
+			slog.Warn("failed to archive dashboard", "error", err)
+		}
+	}
+
+	return canvas, nil
+}
+
+// displaySettleTime is the pause of updateDisplay after waking up and
+// clearing the display. The tests shorten it.
+var displaySettleTime = time.Second
+
+// updateDisplay wakes up and clears the display and shows the image.
+func updateDisplay(ctx context.Context, epd Display, img image.Image) error {
+	slog.Debug("waking up the display")
+	if err := epd.Wake(); err != nil {
+		return fmt.Errorf("failed to wake up display: %w", err)
 	}
 
-	log.Println("Initializing the display...")
-	epd.Init()
+	if err := sleepContext(ctx, displaySettleTime); err != nil {
+		return err
+	}
 
-	time.Sleep(1 * time.Second)
+	slog.Debug("clearing the display")
+	if err := epd.Clear(ctx); err != nil {
+		return fmt.Errorf("failed to clear display: %w", err)
+	}
 
-	log.Println("Clearing...")
-	epd.Clear()
+	if err := sleepContext(ctx, displaySettleTime); err != nil {
+		return err
+	}
 
-	time.Sleep(1 * time.Second)
+	slog.Debug("displaying image")
+	if err := epd.Display(ctx, img); err != nil {
+		return fmt.Errorf("failed to display image: %w", err)
+	}
 
-	log.Println("Displaying image...")
-	epd.Display(canvas.Image())
+	return nil
+}
 
-	log.Println("Quitting...")
-	epd.Sleep()
+// sleepContext pauses for the given duration or until ctx is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
 }
 
 // parseTime turns an open-meteo time string into a time.Time object.
-func parseTime(s *string) time.Time {
+func parseTime(s *string, location *time.Location) time.Time {
 	if s == nil {
 		return time.Time{}
 	}
-	t, err := time.Parse("2006-01-02T15:04", *s)
+	t, err := time.ParseInLocation("2006-01-02T15:04", *s, location)
 	if err != nil {
-		log.Printf("failed to parse time: %v", err)
+		slog.Warn("failed to parse time", "value", *s, "error", err)
 		return time.Time{}
 	}
 	return t
 }
 
 // HourlyWeatherFrom converts hourly weather response to WeatherForecast map
-func HourlyWeatherFrom(response *openmeteogo.HourlyWeatherResponse) (WeatherForecast, error) {
-	maxItems := 7
-
+// with up to maxItems hours from now on. The times of the response are in
+// location, the labels are formatted according to timeFormat.
+// It returns an error if a value series does not match the times.
+func HourlyWeatherFrom(response *openmeteogo.HourlyWeatherResponse, now time.Time, location *time.Location, timeFormat TimeFormat, maxItems int) (WeatherForecast, error) {
 	result := make(WeatherForecast, 0, maxItems)
 
 	if response == nil || response.Hourly.Time == nil {
 		return result, nil
 	}
 
-	now := time.Now()
+	hourly := response.Hourly
+	times := len(hourly.Time)
+	err := errors.Join(
+		checkSeries("temperature_2m", len(hourly.Temperature2m), times),
+		checkSeries("weather_code", len(hourly.WeatherCode), times),
+		checkSeries("precipitation", len(hourly.Precipitation), times),
+		checkSeries("precipitation_probability", len(hourly.PrecipitationProbability), times),
+		checkSeries("surface_pressure", len(hourly.SurfacePressure), times),
+	)
+	if err != nil {
+		return result, fmt.Errorf("invalid hourly weather: %w", err)
+	}
 
-	for i, timeStr := range response.Hourly.Time {
+	for i, timeStr := range hourly.Time {
 		// Parse the time string
-		t, err := time.Parse("2006-01-02T15:04", timeStr)
+		t, err := time.ParseInLocation("2006-01-02T15:04", timeStr, location)
 		if err != nil {
 			return result, fmt.Errorf("failed to parse time: %v", err)
 		}
@@ -215,29 +563,20 @@ func HourlyWeatherFrom(response *openmeteogo.HourlyWeatherResponse) (WeatherFore
 		}
 
 		weather := Weather{
-			Timestamp: t,
-			Label:     t.Local().Format("15"),
+			Timestamp:                t,
+			Label:                    timeFormat.Hour(t),
+			TemperatureLow:           seriesValue(hourly.Temperature2m, i),
+			TemperatureHigh:          seriesValue(hourly.Temperature2m, i),
+			PrecipitationSum:         seriesValue(hourly.Precipitation, i),
+			PrecipitationProbability: seriesValue(hourly.PrecipitationProbability, i),
+			Pressure:                 seriesValue(hourly.SurfacePressure, i),
 		}
 
-		if response.Hourly.Temperature2m != nil && i < len(response.Hourly.Temperature2m) && response.Hourly.Temperature2m[i] != nil {
-			temp := response.Hourly.Temperature2m[i]
-			weather.TemperatureLow = temp
-			weather.TemperatureHigh = temp
-		}
-
-		if response.Hourly.WeatherCode != nil && i < len(response.Hourly.WeatherCode) && response.Hourly.WeatherCode[i] != nil {
-			code := int32(*response.Hourly.WeatherCode[i])
+		if code := seriesValue(hourly.WeatherCode, i); code != nil {
+			code := int32(*code)
 			weather.WeatherCode = &code
 		}
 
-		if response.Hourly.Precipitation != nil && i < len(response.Hourly.Precipitation) && response.Hourly.Precipitation[i] != nil {
-			weather.PrecipitationSum = response.Hourly.Precipitation[i]
-		}
-
-		if response.Hourly.PrecipitationProbability != nil && i < len(response.Hourly.PrecipitationProbability) && response.Hourly.PrecipitationProbability[i] != nil {
-			weather.PrecipitationProbability = response.Hourly.PrecipitationProbability[i]
-		}
-
 		result = append(result, weather)
 
 		if len(result) >= maxItems {
@@ -248,21 +587,85 @@ func HourlyWeatherFrom(response *openmeteogo.HourlyWeatherResponse) (WeatherFore
 	return result, nil
 }
 
-// DailyWeatherFrom converts hourly weather response to WeatherForecast map
-func DailyWeatherFrom(response *openmeteogo.DailyWeatherResponse) (WeatherForecast, error) {
-	maxItems := 7
+// pressureTrendHours is the time the pressure trend is computed over.
+const pressureTrendHours = 3
 
+// currentPressure returns the surface pressure of the current hour and its
+// change since pressureTrendHours before. Either is nil if it is missing,
+// e.g. the change in the first hours of the day.
+func currentPressure(response *openmeteogo.HourlyWeatherResponse, now time.Time, location *time.Location) (pressure, change *float64) {
+	if response == nil {
+		return nil, nil
+	}
+
+	hourly := response.Hourly
+	if len(hourly.SurfacePressure) != len(hourly.Time) {
+		return nil, nil
+	}
+
+	current := -1
+	for i, timeStr := range hourly.Time {
+		t, err := time.ParseInLocation("2006-01-02T15:04", timeStr, location)
+		if err != nil || t.After(now) {
+			break
+		}
+		current = i
+	}
+	if current < 0 {
+		return nil, nil
+	}
+
+	pressure = hourly.SurfacePressure[current]
+	if before := current - pressureTrendHours; pressure != nil && before >= 0 && hourly.SurfacePressure[before] != nil {
+		diff := *pressure - *hourly.SurfacePressure[before]
+		change = &diff
+	}
+
+	return pressure, change
+}
+
+// todayWeather returns the first day of the daily forecast, whose times are
+// in location. Missing series are left nil.
+func todayWeather(response *openmeteogo.DailyWeatherResponse, location *time.Location) Weather {
+	daily := response.Daily
+	return Weather{
+		TemperatureLow:           seriesValue(daily.Temperature2mMin, 0),
+		TemperatureHigh:          seriesValue(daily.Temperature2mMax, 0),
+		WeatherCode:              seriesValue(daily.WeatherCode, 0),
+		Sunrise:                  parseTime(seriesValue(daily.Sunrise, 0), location),
+		Sunset:                   parseTime(seriesValue(daily.Sunset, 0), location),
+		PrecipitationSum:         seriesValue(daily.PrecipitationSum, 0),
+		PrecipitationProbability: seriesValue(daily.PrecipitationProbabilityMax, 0),
+	}
+}
+
+// DailyWeatherFrom converts daily weather response to WeatherForecast map
+// with up to maxItems days from now on. The days of the response are in
+// location, the labels are taken from weekdays, which starts with Sunday.
+// It returns an error if a value series does not match the times.
+func DailyWeatherFrom(response *openmeteogo.DailyWeatherResponse, now time.Time, location *time.Location, weekdays [7]string, maxItems int) (WeatherForecast, error) {
 	result := make(WeatherForecast, 0, maxItems)
 
 	if response == nil || response.Daily.Time == nil {
 		return result, nil
 	}
 
-	now := time.Now()
+	daily := response.Daily
+	times := len(daily.Time)
+	err := errors.Join(
+		checkSeries("temperature_2m_max", len(daily.Temperature2mMax), times),
+		checkSeries("temperature_2m_min", len(daily.Temperature2mMin), times),
+		checkSeries("weather_code", len(daily.WeatherCode), times),
+		checkSeries("precipitation_sum", len(daily.PrecipitationSum), times),
+		checkSeries("precipitation_probability_max", len(daily.PrecipitationProbabilityMax), times),
+	)
+	if err != nil {
+		return result, fmt.Errorf("invalid daily weather: %w", err)
+	}
 
-	for i, timeStr := range response.Daily.Time {
+	for i, timeStr := range daily.Time {
 		// Parse the time string
-		t, err := time.Parse("2006-01-02", timeStr)
+		t, err := time.ParseInLocation("2006-01-02", timeStr, location)
 		if err != nil {
 			return result, fmt.Errorf("failed to parse time: %v", err)
 		}
@@ -272,77 +675,100 @@ func DailyWeatherFrom(response *openmeteogo.DailyWeatherResponse) (WeatherForeca
 			continue
 		}
 
-		weekdays := []string{
-			"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa",
-		}
+		result = append(result, Weather{
+			Timestamp:                t,
+			Label:                    weekdays[t.Weekday()],
+			TemperatureHigh:          seriesValue(daily.Temperature2mMax, i),
+			TemperatureLow:           seriesValue(daily.Temperature2mMin, i),
+			WeatherCode:              seriesValue(daily.WeatherCode, i),
+			PrecipitationSum:         seriesValue(daily.PrecipitationSum, i),
+			PrecipitationProbability: seriesValue(daily.PrecipitationProbabilityMax, i),
+		})
 
-		weather := Weather{
-			Timestamp: t,
-			Label:     weekdays[t.Local().Weekday()],
+		if len(result) >= maxItems {
+			break
 		}
+	}
 
-		if response.Daily.Temperature2mMax != nil && i < len(response.Daily.Temperature2mMax) && response.Daily.Temperature2mMax[i] != nil {
-			temp := response.Daily.Temperature2mMax[i]
-			weather.TemperatureHigh = temp
-		}
-		if response.Daily.Temperature2mMin != nil && i < len(response.Daily.Temperature2mMin) && response.Daily.Temperature2mMin[i] != nil {
-			temp := response.Daily.Temperature2mMin[i]
-			weather.TemperatureLow = temp
-		}
+	return result, nil
+}
 
-		if response.Daily.WeatherCode != nil && i < len(response.Daily.WeatherCode) && response.Daily.WeatherCode[i] != nil {
-			code := *response.Daily.WeatherCode[i]
-			weather.WeatherCode = &code
-		}
+// dailyForecastHour is the hour from which the daily forecast replaces the hourly one.
+const dailyForecastHour = 15
 
-		if response.Daily.PrecipitationSum != nil && i < len(response.Daily.PrecipitationSum) && response.Daily.PrecipitationSum[i] != nil {
-			weather.PrecipitationSum = response.Daily.PrecipitationSum[i]
-		}
+// showDailyForecast reports whether the forecast graph shows the next days
+// instead of the next hours at now. The rain chart always needs the hourly forecast.
+func showDailyForecast(now time.Time, rainChart bool) bool {
+	return now.Hour() >= dailyForecastHour && !rainChart
+}
 
-		if response.Daily.PrecipitationProbabilityMax != nil && i < len(response.Daily.PrecipitationProbabilityMax) && response.Daily.PrecipitationProbabilityMax[i] != nil {
-			weather.PrecipitationProbability = response.Daily.PrecipitationProbabilityMax[i]
-		}
+// checkSeries returns an error if a series of the weather response has
+// values, but not one for each of the times. Missing series are fine.
+func checkSeries(name string, values, times int) error {
+	if values != 0 && values != times {
+		return fmt.Errorf("%s has %d values for %d times", name, values, times)
+	}
+	return nil
+}
 
-		result = append(result, weather)
+// seriesValue returns the i-th value of a series checked by checkSeries,
+// or nil if the series is missing.
+func seriesValue[T any](values []*T, i int) *T {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[i]
+}
 
-		if len(result) >= maxItems {
-			break
-		}
+// buildAppointments fetches the appointments until the given time from the calendars.
+// The tag is the calendar's name unless preferCategory is set and the event
+// has a CATEGORIES property. Events of unnamed calendars always use their category.
+// At most limit appointments are returned, all if limit is 0. total is the
+// number of events in the horizon, including the ones over the limit.
+func buildAppointments(ctx context.Context, cals Calendars, until time.Time, location *time.Location, preferCategory bool, limit int) (appointments []*Appointment, total int, err error) {
+	events, err := cals.MergedEvents(ctx, until, location)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch merged events: %w", err)
 	}
 
-	return result, nil
+	return appointmentsFrom(events, preferCategory, limit), len(events), nil
 }
 
-// buildAppointments fetches the upcoming appointments from the calendars.
-func buildAppointments(cals Calendars, location *time.Location) ([]*Appointment, error) {
-	var err error
-	var start time.Time
+// appointmentsFrom converts the first limit of the sorted events to appointments,
+// all of them if limit is 0. See buildAppointments for the choice of the tag.
+func appointmentsFrom(events []CalendarEvent, preferCategory bool, limit int) []*Appointment {
 	var appointments []*Appointment
 
-	events, err := cals.MergedEvents(time.Now().Add(14 * 24 * time.Hour))
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch merged events: %w", err)
-	}
-
 	for _, event := range events {
-		start, err = event.GetStartAt()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get start time: %w", err)
+		tag := event.Tag
+		if category := eventCategory(event.VEvent); category != "" && (preferCategory || tag == "") {
+			tag = category
 		}
 
 		appointments = append(appointments, &Appointment{
 			Title: event.GetProperty(ics.ComponentPropertySummary).Value,
-			Start: start.In(location),
-			Tag:   event.Tag,
+			Start: event.Start,
+			Tag:   tag,
 			Color: event.Color,
 		})
 
-		if len(appointments) == calendarEventCount {
+		if len(appointments) == limit {
 			break
 		}
 	}
 
-	return appointments, nil
+	return appointments
+}
+
+// eventCategory returns the first entry of the event's CATEGORIES property.
+func eventCategory(event *ics.VEvent) string {
+	prop := event.GetProperty(ics.ComponentPropertyCategories)
+	if prop == nil {
+		return ""
+	}
+
+	category, _, _ := strings.Cut(prop.Value, ",")
+	return strings.TrimSpace(ics.FromText(category))
 }
 
 func pin(pinNumber int) string {
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+	"github.com/ophusdev/openmeteogo"
+)
+
+// MockWeatherTransport answers the forecast requests of openmeteogo with
+// the fixtures in Dir: daily.json for requests of daily values and
+// hourly.json for hourly ones. Other requests and missing fixtures are
+// answered with 404.
+type MockWeatherTransport struct {
+	Dir string
+}
+
+// RoundTrip serves the fixture of the request.
+func (t MockWeatherTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := ""
+	if strings.TrimSuffix(req.URL.Path, "/") == "/v1/forecast" {
+		switch query := req.URL.Query(); {
+		case query.Has("daily"):
+			name = "daily.json"
+		case query.Has("hourly"):
+			name = "hourly.json"
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(t.Dir, name))
+	if name == "" || err != nil {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Status:     "404 Not Found",
+			Body:       io.NopCloser(strings.NewReader("not found")),
+			Request:    req,
+		}, nil
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(data))),
+		Request:    req,
+	}, nil
+}
+
+// mockWeatherClient returns a client that serves the fixtures in dir.
+func mockWeatherClient(dir string) *http.Client {
+	return &http.Client{Transport: MockWeatherTransport{Dir: dir}}
+}
+
+// fixtureLocation is the location of the weather fixtures.
+var fixtureLocation = weatherLocation{Latitude: 47.56, Longitude: 7.58}
+
+func loadBerlin(t *testing.T) *time.Location {
+	t.Helper()
+	return loadLocation(t, "Europe/Berlin")
+}
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+// formatValue formats a value of the forecast, "nil" if it is missing.
+func formatValue[T any](v *T) string {
+	if v == nil {
+		return "nil"
+	}
+	return fmt.Sprint(*v)
+}
+
+func TestDailyWeatherFrom(t *testing.T) {
+	berlin := loadBerlin(t)
+	response, err := fetchDailyWeather(context.Background(), fixtureLocation, "Europe/Berlin", mockWeatherClient("testdata/weather"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Today is skipped from the first second of the day on.
+	now := time.Date(2025, time.March, 14, 0, 0, 1, 0, berlin)
+	forecast, err := DailyWeatherFrom(response, now, berlin, LocaleGerman.WeekdayAbbreviations(), forecastItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forecast) != forecastItems {
+		t.Fatalf("got %d days, want %d", len(forecast), forecastItems)
+	}
+
+	tests := []struct {
+		i                      int
+		label, high, low, code string
+		rain, chance           string
+	}{
+		{0, "Sa", "11.5", "2", "3", "0.4", "35"},
+		{4, "Mi", "8.1", "0.6", "nil", "nil", "nil"},
+		{5, "Do", "nil", "-1.2", "71", "2.5", "60"},
+		{6, "Fr", "6.9", "-2", "1", "0", "15"},
+	}
+	for _, tt := range tests {
+		day := forecast[tt.i]
+		got := []string{day.Label, formatValue(day.TemperatureHigh), formatValue(day.TemperatureLow), formatValue(day.WeatherCode), formatValue(day.PrecipitationSum), formatValue(day.PrecipitationProbability)}
+		want := []string{tt.label, tt.high, tt.low, tt.code, tt.rain, tt.chance}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("day %d = %v, want %v", tt.i, got, want)
+		}
+		if day.Timestamp.Location() != berlin || day.Timestamp.Hour() != 0 {
+			t.Errorf("day %d starts at %v, want midnight in Berlin", tt.i, day.Timestamp)
+		}
+	}
+
+	// In the evening in New York, it is the next day in Berlin already. The
+	// days of a response for New York start at midnight there.
+	newYork := loadLocation(t, "America/New_York")
+	now = time.Date(2025, time.March, 14, 20, 0, 0, 0, newYork)
+	forecast, err = DailyWeatherFrom(response, now, newYork, LocaleGerman.WeekdayAbbreviations(), forecastItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day := forecast[0]; day.Label != "Sa" || formatValue(day.TemperatureHigh) != "11.5" {
+		t.Errorf("first day in New York is %s with %s°, want Sa with 11.5°", day.Label, formatValue(day.TemperatureHigh))
+	}
+	if start := forecast[0].Timestamp; !start.Equal(time.Date(2025, time.March, 15, 0, 0, 0, 0, newYork)) {
+		t.Errorf("first day in New York starts at %v, want midnight there", start)
+	}
+}
+
+func TestHourlyWeatherFrom(t *testing.T) {
+	berlin := loadBerlin(t)
+	response, err := fetchHourlyWeather(context.Background(), fixtureLocation, "Europe/Berlin", mockWeatherClient("testdata/weather"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, time.March, 14, 10, 30, 0, 0, berlin)
+	forecast, err := HourlyWeatherFrom(response, now, berlin, TimeFormat24h, forecastItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forecast) != forecastItems {
+		t.Fatalf("got %d hours, want %d", len(forecast), forecastItems)
+	}
+	if first := forecast[0].Timestamp; !first.Equal(time.Date(2025, time.March, 14, 11, 0, 0, 0, berlin)) {
+		t.Errorf("first hour is %v, want 11:00", first)
+	}
+
+	// 13:00 has no temperature and no chance of rain in the fixture.
+	hour := forecast[2]
+	if hour.TemperatureHigh != nil || hour.TemperatureLow != nil || hour.PrecipitationProbability != nil {
+		t.Errorf("13:00 has values for the missing temperature and chance of rain")
+	}
+	if got := formatValue(hour.WeatherCode); got != "63" {
+		t.Errorf("13:00 has weather code %s, want 63", got)
+	}
+	if got := formatValue(hour.PrecipitationSum); got != "1.4" {
+		t.Errorf("13:00 has %s mm of rain, want 1.4", got)
+	}
+
+	// The rain chart needs more hours than the forecast graph.
+	forecast, err = HourlyWeatherFrom(response, now, berlin, TimeFormat24h, rainChartHours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forecast) != rainChartHours {
+		t.Errorf("got %d hours, want %d", len(forecast), rainChartHours)
+	}
+
+	// Fewer hours are left at the end of the response.
+	now = time.Date(2025, time.March, 15, 20, 0, 0, 0, berlin)
+	forecast, err = HourlyWeatherFrom(response, now, berlin, TimeFormat24h, forecastItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forecast) != 4 {
+		t.Errorf("got %d hours after 20:00 of the last day, want 4", len(forecast))
+	}
+
+	// The hours of a response for New York are in New York, 22:30 there is
+	// 03:30 of the next day in Berlin.
+	newYork := loadLocation(t, "America/New_York")
+	now = time.Date(2025, time.March, 14, 22, 30, 0, 0, newYork)
+	forecast, err = HourlyWeatherFrom(response, now, newYork, TimeFormat24h, forecastItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first := forecast[0].Timestamp; !first.Equal(time.Date(2025, time.March, 14, 23, 0, 0, 0, newYork)) || forecast[0].Label != "23" {
+		t.Errorf("first hour in New York is %v labeled %s, want 23:00 there", first, forecast[0].Label)
+	}
+	if next := nextDayIndex(forecast); next != 1 {
+		t.Errorf("the next day in New York starts at hour %d, want 1", next)
+	}
+}
+
+func TestWeatherEmptyResponses(t *testing.T) {
+	berlin := loadBerlin(t)
+	now := time.Date(2025, time.March, 14, 10, 30, 0, 0, berlin)
+	weekdays := LocaleGerman.WeekdayAbbreviations()
+
+	dir := t.TempDir()
+	for _, name := range []string{"daily.json", "hourly.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(`{"latitude":47.56,"longitude":7.58}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := mockWeatherClient(dir)
+
+	daily, err := fetchDailyWeather(context.Background(), fixtureLocation, "Europe/Berlin", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hourly, err := fetchHourlyWeather(context.Background(), fixtureLocation, "Europe/Berlin", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, convert := range map[string]func() (WeatherForecast, error){
+		"daily": func() (WeatherForecast, error) {
+			return DailyWeatherFrom(daily, now, berlin, weekdays, forecastItems)
+		},
+		"hourly": func() (WeatherForecast, error) {
+			return HourlyWeatherFrom(hourly, now, berlin, TimeFormat24h, forecastItems)
+		},
+		"nil daily": func() (WeatherForecast, error) {
+			return DailyWeatherFrom(nil, now, berlin, weekdays, forecastItems)
+		},
+		"nil hourly": func() (WeatherForecast, error) {
+			return HourlyWeatherFrom(nil, now, berlin, TimeFormat24h, forecastItems)
+		},
+		"empty times": func() (WeatherForecast, error) {
+			return HourlyWeatherFrom(&openmeteogo.HourlyWeatherResponse{Hourly: openmeteogo.HourlyResponse{Time: []string{}}}, now, berlin, TimeFormat24h, forecastItems)
+		},
+	} {
+		forecast, err := convert()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if len(forecast) != 0 {
+			t.Errorf("%s: got %d entries, want none", name, len(forecast))
+		}
+	}
+
+	// A missing fixture is an error of the request.
+	if _, err := fetchDailyWeather(context.Background(), fixtureLocation, "Europe/Berlin", mockWeatherClient(t.TempDir())); err == nil {
+		t.Error("fetching a missing fixture succeeded")
+	}
+}
+
+func TestWeatherMissingSeries(t *testing.T) {
+	berlin := loadBerlin(t)
+	now := time.Date(2025, time.March, 14, 0, 0, 0, 0, berlin)
+
+	// Series that weren't requested are nil slices, their values are nil.
+	daily := &openmeteogo.DailyWeatherResponse{Daily: openmeteogo.DailyResponse{
+		Time:             []string{"2025-03-14", "2025-03-15"},
+		Temperature2mMax: []*float64{ptr(10.0), ptr(12.0)},
+	}}
+	forecast, err := DailyWeatherFrom(daily, now, berlin, LocaleGerman.WeekdayAbbreviations(), forecastItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forecast) != 2 {
+		t.Fatalf("got %d days, want 2", len(forecast))
+	}
+	if got := formatValue(forecast[1].TemperatureHigh); got != "12" {
+		t.Errorf("high = %s, want 12", got)
+	}
+	if forecast[1].TemperatureLow != nil || forecast[1].WeatherCode != nil || forecast[1].PrecipitationSum != nil {
+		t.Errorf("missing series have values: %+v", forecast[1])
+	}
+
+	// A series with fewer values than times is an error.
+	daily.Daily.Temperature2mMin = []*float64{ptr(1.0)}
+	_, err = DailyWeatherFrom(daily, now, berlin, LocaleGerman.WeekdayAbbreviations(), forecastItems)
+	if err == nil || !strings.Contains(err.Error(), "temperature_2m_min has 1 values for 2 times") {
+		t.Errorf("mismatched series: err = %v", err)
+	}
+}
+
+func TestShowDailyForecast(t *testing.T) {
+	berlin := loadBerlin(t)
+
+	tests := []struct {
+		hour, minute int
+		rainChart    bool
+		want         bool
+	}{
+		{0, 0, false, false},
+		{14, 59, false, false},
+		{15, 0, false, true},
+		{23, 59, false, true},
+		{15, 0, true, false},
+		{20, 0, true, false},
+	}
+	for _, tt := range tests {
+		now := time.Date(2025, time.March, 14, tt.hour, tt.minute, 0, 0, berlin)
+		if got := showDailyForecast(now, tt.rainChart); got != tt.want {
+			t.Errorf("showDailyForecast(%s, %v) = %v, want %v", now.Format("15:04"), tt.rainChart, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSeries(t *testing.T) {
+	tests := []struct {
+		values, times int
+		wantErr       bool
+	}{
+		{0, 0, false},
+		{0, 24, false},
+		{24, 24, false},
+		{23, 24, true},
+		{25, 24, true},
+		{1, 0, true},
+	}
+
+	for _, tt := range tests {
+		err := checkSeries("temperature_2m", tt.values, tt.times)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkSeries(%d values, %d times) = %v, want error %v", tt.values, tt.times, err, tt.wantErr)
+		}
+	}
+
+	if got := seriesValue([]*float64(nil), 3); got != nil {
+		t.Errorf("seriesValue of a missing series = %v, want nil", *got)
+	}
+	if got := seriesValue([]*float64{nil, ptr(2.5)}, 1); got == nil || *got != 2.5 {
+		t.Errorf("seriesValue = %s, want 2.5", formatValue(got))
+	}
+}
+
+func TestHourlyWeatherFromSeries(t *testing.T) {
+	berlin := loadBerlin(t)
+	now := time.Date(2025, time.March, 14, 10, 0, 0, 0, berlin)
+	times := []string{"2025-03-14T10:00", "2025-03-14T11:00", "2025-03-14T12:00"}
+
+	tests := []struct {
+		name    string
+		hourly  openmeteogo.HourlyResponse
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "nil entries",
+			hourly: openmeteogo.HourlyResponse{
+				Time:                     times,
+				Temperature2m:            []*float64{ptr(5.0), nil, ptr(7.0)},
+				WeatherCode:              []*float64{nil, ptr(3.0), ptr(61.0)},
+				Precipitation:            []*float64{ptr(0.0), ptr(0.2), nil},
+				PrecipitationProbability: []*float64{nil, nil, nil},
+			},
+			want: []string{"5 nil 0 nil", "nil 3 0.2 nil", "7 61 nil nil"},
+		},
+		{
+			name:   "missing series",
+			hourly: openmeteogo.HourlyResponse{Time: times, Temperature2m: []*float64{ptr(5.0), ptr(6.0), ptr(7.0)}},
+			want:   []string{"5 nil nil nil", "6 nil nil nil", "7 nil nil nil"},
+		},
+		{
+			name:    "short series",
+			hourly:  openmeteogo.HourlyResponse{Time: times, WeatherCode: []*float64{ptr(3.0)}},
+			wantErr: "weather_code has 1 values for 3 times",
+		},
+		{
+			name:    "long series",
+			hourly:  openmeteogo.HourlyResponse{Time: times, SurfacePressure: []*float64{ptr(1.0), ptr(2.0), ptr(3.0), ptr(4.0)}},
+			wantErr: "surface_pressure has 4 values for 3 times",
+		},
+		{
+			name: "every mismatch is reported",
+			hourly: openmeteogo.HourlyResponse{
+				Time:          times,
+				Temperature2m: []*float64{ptr(5.0)},
+				Precipitation: []*float64{ptr(0.0), ptr(0.0)},
+			},
+			wantErr: "temperature_2m has 1 values for 3 times\nprecipitation has 2 values for 3 times",
+		},
+		{
+			name:    "invalid time",
+			hourly:  openmeteogo.HourlyResponse{Time: []string{"2025-03-14 10:00"}},
+			wantErr: "failed to parse time",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forecast, err := HourlyWeatherFrom(&openmeteogo.HourlyWeatherResponse{Hourly: tt.hourly}, now, berlin, TimeFormat24h, forecastItems)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, hour := range forecast {
+				got = append(got, strings.Join([]string{formatValue(hour.TemperatureHigh), formatValue(hour.WeatherCode), formatValue(hour.PrecipitationSum), formatValue(hour.PrecipitationProbability)}, " "))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("forecast = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeDisplay is a Display that records the calls and fails the ones in errs.
+type fakeDisplay struct {
+	calls []string
+	errs  map[string]error
+}
+
+func (d *fakeDisplay) call(name string) error {
+	d.calls = append(d.calls, name)
+	return d.errs[name]
+}
+
+func (d *fakeDisplay) Wake() error { return d.call("Wake") }
+
+func (d *fakeDisplay) Clear(ctx context.Context) error {
+	if err := d.call("Clear"); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (d *fakeDisplay) Display(ctx context.Context, img image.Image) error {
+	if err := d.call("Display"); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (d *fakeDisplay) DeepClean(ctx context.Context) error {
+	if err := d.call("DeepClean"); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (d *fakeDisplay) Sleep() { d.call("Sleep") }
+
+// shortenDisplaySettleTime removes the pauses of updateDisplay for the test.
+func shortenDisplaySettleTime(t *testing.T) {
+	t.Helper()
+	settle := displaySettleTime
+	displaySettleTime = time.Millisecond
+	t.Cleanup(func() { displaySettleTime = settle })
+}
+
+func TestPresentAndSleep(t *testing.T) {
+	shortenDisplaySettleTime(t)
+	errPanel := errors.New("panel unavailable")
+	errRender := errors.New("no weather")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		renderErr error
+		errs      map[string]error
+		calls     []string
+		err       error
+	}{
+		{name: "success", ctx: context.Background(), calls: []string{"Wake", "Clear", "Display", "Sleep"}},
+		{name: "failed wake", ctx: context.Background(), errs: map[string]error{"Wake": errPanel}, calls: []string{"Wake", "Sleep"}, err: errPanel},
+		{name: "failed display", ctx: context.Background(), errs: map[string]error{"Display": errPanel}, calls: []string{"Wake", "Clear", "Display", "Sleep"}, err: errPanel},
+		// The error screen is shown and the render error returned.
+		{name: "render error", ctx: context.Background(), renderErr: errRender, calls: []string{"Wake", "Clear", "Display", "Sleep"}, err: errRender},
+		{name: "cancelled", ctx: cancelled, calls: []string{"Wake", "Sleep"}, err: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			epd := &fakeDisplay{errs: tt.errs}
+			var canvas *gg.Context
+			if tt.renderErr == nil {
+				canvas = gg.NewContext(EPD_WIDTH, EPD_HEIGHT)
+			}
+
+			err := presentAndSleep(tt.ctx, epd, canvas, tt.renderErr, NewDefaultConfig())
+			if !errors.Is(err, tt.err) || (err == nil) != (tt.err == nil) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if !slices.Equal(epd.calls, tt.calls) {
+				t.Errorf("got calls %v, want %v", epd.calls, tt.calls)
+			}
+		})
+	}
+}
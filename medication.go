@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+)
+
+// MedicationConfig is an entry of [[medications]] in the config.
+type MedicationConfig struct {
+	Name string `toml:"name"`
+	Dose string `toml:"dose"`
+	// Times are the times of day of the doses, e.g. ["08:00", "20:00"]
+	Times []clockTime `toml:"times"`
+	// Days are the weekdays of the doses, e.g. ["Monday"], or ["daily"]
+	Days []medicationDay `toml:"days"`
+}
+
+// MedicationDose is a dose of a medication at a time.
+type MedicationDose struct {
+	Name  string
+	Dose  string
+	Time  time.Time
+	Taken bool
+}
+
+// Label returns the name and the dose, e.g. "Metformin 500mg".
+func (d MedicationDose) Label() string {
+	if d.Dose == "" {
+		return d.Name
+	}
+	return d.Name + " " + d.Dose
+}
+
+// clockTime is a time of day in the config, e.g. "08:00".
+type clockTime struct {
+	hour, minute int
+}
+
+// UnmarshalText parses a time of day like "08:00".
+func (c *clockTime) UnmarshalText(text []byte) error {
+	var hour, minute int
+	_, err := fmt.Sscanf(string(text), "%d:%d", &hour, &minute)
+	if err != nil || !validClock(hour, minute) {
+		return fmt.Errorf("invalid time of day: %s (expected e.g. 08:00)", string(text))
+	}
+
+	*c = clockTime{hour: hour, minute: minute}
+
+	return nil
+}
+
+// medicationDay is a weekday of the doses, or every day for "daily".
+type medicationDay struct {
+	weekday tomlWeekday
+	daily   bool
+}
+
+// UnmarshalText parses "daily" or an English weekday name.
+func (d *medicationDay) UnmarshalText(text []byte) error {
+	if string(text) == "daily" {
+		*d = medicationDay{daily: true}
+		return nil
+	}
+
+	*d = medicationDay{}
+	return d.weekday.UnmarshalText(text)
+}
+
+// takesOn reports whether the medication is taken on the day of t. Without
+// days, it is taken daily.
+func (m MedicationConfig) takesOn(t time.Time) bool {
+	if len(m.Days) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(m.Days, func(d medicationDay) bool {
+		return d.daily || d.weekday.weekday == t.Weekday()
+	})
+}
+
+// NextMedicationDoses returns the doses from the start of the day of now
+// until lookahead after now, sorted by time. The doses of today that already
+// passed are included, so the widget can show whether they were taken.
+func NextMedicationDoses(cfg []MedicationConfig, now time.Time, lookahead time.Duration) []MedicationDose {
+	end := now.Add(lookahead)
+
+	var doses []MedicationDose
+	for day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()); !day.After(end); day = day.AddDate(0, 0, 1) {
+		for _, medication := range cfg {
+			if !medication.takesOn(day) {
+				continue
+			}
+
+			for _, clock := range medication.Times {
+				t := time.Date(day.Year(), day.Month(), day.Day(), clock.hour, clock.minute, 0, 0, day.Location())
+				if t.After(end) {
+					continue
+				}
+				doses = append(doses, MedicationDose{Name: medication.Name, Dose: medication.Dose, Time: t})
+			}
+		}
+	}
+
+	slices.SortStableFunc(doses, func(a, b MedicationDose) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	return doses
+}
+
+// Defaults of the [medication] table.
+const (
+	defaultMedicationLookahead = 12 * time.Hour
+	defaultMedicationTakenFile = "medications.json"
+
+	// medicationTakenKeep is how long taken doses are remembered.
+	medicationTakenKeep = 7 * 24 * time.Hour
+	// medicationTimeFormat is the format of the dose times in the taken file.
+	medicationTimeFormat = "2006-01-02T15:04"
+)
+
+// medicationLog are the taken doses, the times of the doses by medication
+// name, as stored in the taken file.
+type medicationLog map[string][]string
+
+// loadMedicationLog reads the taken doses from path. A missing file is an
+// empty log.
+func loadMedicationLog(path string) (medicationLog, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return medicationLog{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read taken medications: %w", err)
+	}
+
+	var log medicationLog
+	if err = json.Unmarshal(data, &log); err != nil {
+		return nil, fmt.Errorf("failed to parse taken medications %s: %w", path, err)
+	}
+	if log == nil {
+		log = medicationLog{}
+	}
+
+	return log, nil
+}
+
+// taken reports whether the dose is in the log.
+func (l medicationLog) taken(dose MedicationDose) bool {
+	return slices.Contains(l[dose.Name], dose.Time.Format(medicationTimeFormat))
+}
+
+// markTaken marks the dose as taken. Doses older than medicationTakenKeep
+// are removed from the log.
+func (l medicationLog) markTaken(dose MedicationDose, now time.Time) {
+	if !l.taken(dose) {
+		l[dose.Name] = append(l[dose.Name], dose.Time.Format(medicationTimeFormat))
+	}
+
+	for name, times := range l {
+		times = slices.DeleteFunc(times, func(s string) bool {
+			t, err := time.ParseInLocation(medicationTimeFormat, s, now.Location())
+			return err != nil || now.Sub(t) > medicationTakenKeep
+		})
+		if len(times) == 0 {
+			delete(l, name)
+			continue
+		}
+		l[name] = times
+	}
+}
+
+// save writes the log to path.
+func (l medicationLog) save(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode taken medications: %w", err)
+	}
+
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write taken medications: %w", err)
+	}
+
+	return nil
+}
+
+// takeMedication marks the dose of the named medication closest to now on
+// the same day as taken in the log at path, and returns it.
+func takeMedication(cfg []MedicationConfig, name, path string, now time.Time) (MedicationDose, error) {
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+
+	var closest *MedicationDose
+	for _, dose := range NextMedicationDoses(cfg, now, endOfDay.Sub(now)-time.Minute) {
+		if dose.Name != name {
+			continue
+		}
+		if closest == nil || dose.Time.Sub(now).Abs() < closest.Time.Sub(now).Abs() {
+			closest = &dose
+		}
+	}
+	if closest == nil {
+		return MedicationDose{}, fmt.Errorf("no dose of %s today", name)
+	}
+
+	log, err := loadMedicationLog(path)
+	if err != nil {
+		return MedicationDose{}, err
+	}
+
+	log.markTaken(*closest, now)
+	if err = log.save(path); err != nil {
+		return MedicationDose{}, err
+	}
+
+	closest.Taken = true
+
+	return *closest, nil
+}
+
+// Layout of the medication widget.
+const (
+	medicationRowHeight = 28
+	medicationMaxRows   = 4
+)
+
+// medicationHeight returns the height of the medication widget with n doses.
+func medicationHeight(n int) int {
+	return min(n, medicationMaxRows) * medicationRowHeight
+}
+
+// drawMedications draws a row per dose into rect, e.g. "08:00 – Metformin
+// 500mg", in the font of the appointments. Taken doses are struck through.
+func drawMedications(dc *dashboardCanvas, rect image.Rectangle, doses []MedicationDose, now time.Time, timeFormat TimeFormat) error {
+	err := setFont(dc.Context, FontRegular, FontSizeSM)
+	if err != nil {
+		return err
+	}
+
+	dc.SetColor(color.Black)
+	for i, dose := range doses[:min(len(doses), medicationMaxRows)] {
+		y := float64(rect.Min.Y + i*medicationRowHeight + medicationRowHeight/2)
+
+		text := fitString(dc.Context, relativeDate(now, dose.Time, timeFormat)+" – "+dose.Label(), float64(rect.Dx()))
+		dc.DrawStringAnchored(text, float64(rect.Min.X), y, 0, 0.35)
+
+		if dose.Taken {
+			width, _ := dc.MeasureString(text)
+			dc.SetLineWidth(2)
+			dc.DrawLine(float64(rect.Min.X), y, float64(rect.Min.X)+width, y)
+			dc.Stroke()
+		}
+	}
+
+	return nil
+}
+
+// medicationDoses returns the doses shown on the dashboard, marked as taken
+// from the taken file. If the file can't be read, no dose is marked.
+func medicationDoses(config *DashboardConfig, now time.Time) []MedicationDose {
+	doses := NextMedicationDoses(config.MedicationSchedule, now, config.MedicationLookahead)
+	if len(doses) == 0 {
+		return nil
+	}
+
+	log, err := loadMedicationLog(config.MedicationTakenFile)
+	if err != nil {
+		slog.Warn("failed to load taken medications", "error", err)
+		return doses
+	}
+
+	for i := range doses {
+		doses[i].Taken = log.taken(doses[i])
+	}
+
+	return doses
+}
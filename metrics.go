@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metrics collects the durations and outcomes of the updates for the
+// /metrics endpoint of the daemon.
+var metrics = newMetricsRegistry()
+
+// sourceMetrics are the metrics of one data source of the DataCache.
+type sourceMetrics struct {
+	fetches     uint64
+	errors      uint64
+	duration    time.Duration
+	lastSuccess time.Time
+}
+
+// phaseMetrics are the metrics of a phase of the update (render, display, refresh).
+type phaseMetrics struct {
+	count       uint64
+	errors      uint64
+	duration    time.Duration
+	lastSuccess time.Time
+}
+
+// metricsRegistry holds the metrics. It is safe for concurrent use.
+type metricsRegistry struct {
+	mu      sync.Mutex
+	sources map[string]*sourceMetrics
+	phases  map[string]*phaseMetrics
+}
+
+// Phases of an update.
+const (
+	phaseRender  = "render"
+	phaseDisplay = "display"
+	phaseRefresh = "refresh"
+)
+
+// newMetricsRegistry creates an empty registry.
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		sources: make(map[string]*sourceMetrics),
+		phases:  make(map[string]*phaseMetrics),
+	}
+}
+
+// observeFetch records a fetch of the source that took d and failed with err.
+func (m *metricsRegistry) observeFetch(source string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sources[source]
+	if !ok {
+		s = &sourceMetrics{}
+		m.sources[source] = s
+	}
+
+	s.fetches++
+	s.duration = d
+	if err != nil {
+		s.errors++
+	} else {
+		s.lastSuccess = time.Now()
+	}
+}
+
+// observePhase records a phase of the update that took d and failed with err.
+func (m *metricsRegistry) observePhase(phase string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.phases[phase]
+	if !ok {
+		p = &phaseMetrics{}
+		m.phases[phase] = p
+	}
+
+	p.count++
+	p.duration = d
+	if err != nil {
+		p.errors++
+	} else {
+		p.lastSuccess = time.Now()
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *metricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	writeFamily := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	writeValue := func(name, label, value string, v float64) {
+		fmt.Fprintf(&b, "%s{%s=\"%s\"} %g\n", name, label, escapeLabel(value), v)
+	}
+
+	sources := slices.Sorted(maps.Keys(m.sources))
+	phases := slices.Sorted(maps.Keys(m.phases))
+
+	writeFamily("epd_fetch_total", "counter", "Number of fetches of a data source.")
+	for _, name := range sources {
+		writeValue("epd_fetch_total", "source", name, float64(m.sources[name].fetches))
+	}
+	writeFamily("epd_fetch_errors_total", "counter", "Number of failed fetches of a data source.")
+	for _, name := range sources {
+		writeValue("epd_fetch_errors_total", "source", name, float64(m.sources[name].errors))
+	}
+	writeFamily("epd_fetch_duration_seconds", "gauge", "Duration of the last fetch of a data source.")
+	for _, name := range sources {
+		writeValue("epd_fetch_duration_seconds", "source", name, m.sources[name].duration.Seconds())
+	}
+	writeFamily("epd_fetch_last_success_timestamp_seconds", "gauge", "Time of the last successful fetch of a data source.")
+	for _, name := range sources {
+		writeValue("epd_fetch_last_success_timestamp_seconds", "source", name, unixSeconds(m.sources[name].lastSuccess))
+	}
+
+	writeFamily("epd_phase_total", "counter", "Number of runs of an update phase.")
+	for _, name := range phases {
+		writeValue("epd_phase_total", "phase", name, float64(m.phases[name].count))
+	}
+	writeFamily("epd_phase_errors_total", "counter", "Number of failed runs of an update phase.")
+	for _, name := range phases {
+		writeValue("epd_phase_errors_total", "phase", name, float64(m.phases[name].errors))
+	}
+	writeFamily("epd_phase_duration_seconds", "gauge", "Duration of the last run of an update phase.")
+	for _, name := range phases {
+		writeValue("epd_phase_duration_seconds", "phase", name, m.phases[name].duration.Seconds())
+	}
+	writeFamily("epd_phase_last_success_timestamp_seconds", "gauge", "Time of the last successful run of an update phase.")
+	for _, name := range phases {
+		writeValue("epd_phase_last_success_timestamp_seconds", "phase", name, unixSeconds(m.phases[name].lastSuccess))
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// escapeLabel escapes a label value of the text exposition format.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// unixSeconds returns t as seconds since the epoch, 0 for the zero time.
+func unixSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixMilli()) / 1000
+}
+
+// serveMetrics serves the metrics at /metrics on addr until ctx is cancelled.
+// It returns an error if addr can't be listened on.
+func serveMetrics(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if _, err := metrics.WriteTo(w); err != nil {
+			slog.Debug("failed to write metrics", "error", err)
+		}
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics server failed", "error", err)
+		}
+	}()
+
+	slog.Info("serving metrics", "address", listener.Addr().String())
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	"time"
+)
+
+// miniMonthHeight is the height of the mini calendar below the appointments.
+const miniMonthHeight = 126
+
+// monthLayout returns the column of the first day of the month of t, with
+// Monday as column 0, the number of days of the month and the number of
+// weeks (rows) the month spans.
+func monthLayout(t time.Time) (firstColumn, days, weeks int) {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	firstColumn = (int(first.Weekday()) + 6) % 7
+	days = first.AddDate(0, 1, -1).Day()
+	weeks = (firstColumn + days + 6) / 7
+
+	return firstColumn, days, weeks
+}
+
+// eventDaysOf returns the days of the month of now that have appointments.
+func eventDaysOf(appointments []*Appointment, now time.Time) map[int]bool {
+	eventDays := make(map[int]bool)
+	for _, appointment := range appointments {
+		start := appointment.Start.In(now.Location())
+		if start.Year() == now.Year() && start.Month() == now.Month() {
+			eventDays[start.Day()] = true
+		}
+	}
+	return eventDays
+}
+
+// renderMiniMonth draws the current month into rect like the small calendar
+// of a paper planner: a header with the weekdays, followed by the weeks
+// starting on Monday. Today is inverted and days with appointments have a
+// dot underneath. The rows are sized for 6 weeks, so the grid does not
+// jump from month to month. weekdays are the abbreviations starting with Sunday.
+func renderMiniMonth(dc *dashboardCanvas, rect image.Rectangle, now time.Time, eventDays map[int]bool, weekdays [7]string) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return err
+	}
+
+	columnWidth := float64(rect.Dx()) / 7
+	rowHeight := float64(rect.Dy()) / 7
+	radius := min(columnWidth, rowHeight)/2 - 1
+
+	// Header, starting on Monday
+	dc.SetColor(color.Black)
+	for column := range 7 {
+		dc.DrawStringAnchored(
+			weekdays[(column+1)%7],
+			float64(rect.Min.X)+(float64(column)+0.5)*columnWidth,
+			float64(rect.Min.Y)+rowHeight/2,
+			0.5, 0.5,
+		)
+	}
+
+	firstColumn, days, _ := monthLayout(now)
+	for day := 1; day <= days; day++ {
+		cell := firstColumn + day - 1
+		x := float64(rect.Min.X) + (float64(cell%7)+0.5)*columnWidth
+		y := float64(rect.Min.Y) + (float64(cell/7+1)+0.5)*rowHeight
+
+		textColor := color.Color(color.Black)
+		if day == now.Day() {
+			dc.SetColor(color.Black)
+			dc.DrawCircle(x, y, radius)
+			dc.Fill()
+			textColor = ColorWhite
+		}
+
+		dc.SetColor(textColor)
+		dc.DrawStringAnchored(strconv.Itoa(day), x, y-3, 0.5, 0.5)
+
+		if eventDays[day] {
+			dc.DrawCircle(x, y+radius-1, 1.5)
+			dc.Fill()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthLayout(t *testing.T) {
+	tests := []struct {
+		name                     string
+		year                     int
+		month                    time.Month
+		firstColumn, days, weeks int
+	}{
+		{"February of a leap year", 2024, time.February, 3, 29, 5},
+		{"February of a common year", 2025, time.February, 5, 28, 5},
+		{"February in 4 weeks", 2021, time.February, 0, 28, 4},
+		{"February of a century", 2100, time.February, 0, 28, 4},
+		{"31 days starting on Saturday", 2025, time.March, 5, 31, 6},
+		{"30 days starting on Sunday", 2025, time.June, 6, 30, 6},
+		{"31 days starting on Sunday", 2026, time.March, 6, 31, 6},
+		{"31 days starting on Thursday", 2026, time.January, 3, 31, 5},
+		{"December", 2025, time.December, 0, 31, 5},
+	}
+
+	for _, tt := range tests {
+		// The day and the location must not matter.
+		for _, now := range []time.Time{
+			time.Date(tt.year, tt.month, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(tt.year, tt.month, tt.days, 23, 59, 0, 0, loadBerlin(t)),
+		} {
+			firstColumn, days, weeks := monthLayout(now)
+			if firstColumn != tt.firstColumn || days != tt.days || weeks != tt.weeks {
+				t.Errorf("%s: monthLayout(%s) = %d, %d, %d, want %d, %d, %d", tt.name, now.Format(time.DateTime), firstColumn, days, weeks, tt.firstColumn, tt.days, tt.weeks)
+			}
+		}
+	}
+}
+
+func TestEventDaysOf(t *testing.T) {
+	berlin := loadBerlin(t)
+	now := time.Date(2025, time.March, 14, 10, 0, 0, 0, berlin)
+
+	appointments := []*Appointment{
+		{Start: time.Date(2025, time.March, 14, 18, 0, 0, 0, berlin)},
+		// Past the 14 days of the list, still in the month.
+		{Start: time.Date(2025, time.March, 31, 9, 0, 0, 0, berlin)},
+		// The 20th in Berlin, the 19th in UTC.
+		{Start: time.Date(2025, time.March, 19, 23, 30, 0, 0, time.UTC)},
+		{Start: time.Date(2025, time.April, 1, 0, 0, 0, 0, berlin)},
+		{Start: time.Date(2024, time.March, 15, 0, 0, 0, 0, berlin)},
+	}
+
+	got := eventDaysOf(appointments, now)
+	want := map[int]bool{14: true, 20: true, 31: true}
+	if len(got) != len(want) {
+		t.Errorf("eventDaysOf = %v, want %v", got, want)
+	}
+	for day := range want {
+		if !got[day] {
+			t.Errorf("eventDaysOf = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestAppointmentsUntil(t *testing.T) {
+	berlin := loadBerlin(t)
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2025, month, day, 10, 0, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		name      string
+		miniMonth bool
+		now       time.Time
+		want      time.Time
+	}{
+		{"list", false, at(time.March, 3), at(time.March, 3).Add(appointmentHorizon)},
+		{"mini month, start of the month", true, at(time.March, 3), time.Date(2025, time.April, 1, 0, 0, 0, 0, berlin)},
+		{"mini month, end of the month", true, at(time.March, 28), at(time.March, 28).Add(appointmentHorizon)},
+		{"mini month, December", true, at(time.December, 1), time.Date(2026, time.January, 1, 0, 0, 0, 0, berlin)},
+	}
+
+	for _, tt := range tests {
+		var cfg config
+		cfg.Layout.ShowMiniMonth = tt.miniMonth
+		if got := cfg.AppointmentsUntil(tt.now); !got.Equal(tt.want) {
+			t.Errorf("%s: AppointmentsUntil = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
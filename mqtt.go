@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttConfig is the [mqtt] table of the config. The trigger is only started
+// if Broker is set.
+type mqttConfig struct {
+	Broker   string `toml:"broker"`
+	Port     int    `toml:"port"`
+	Topic    string `toml:"topic"`
+	ClientID string `toml:"client_id"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// Discovery publishes the state of the dashboard as Home Assistant
+	// sensors, announced below DiscoveryPrefix.
+	Discovery       bool   `toml:"discovery"`
+	DiscoveryPrefix string `toml:"discovery_prefix"`
+}
+
+// Defaults of the [mqtt] table.
+const (
+	defaultMQTTPort            = 1883
+	defaultMQTTClientID        = "epd-dashboard"
+	defaultMQTTDiscoveryPrefix = "homeassistant"
+)
+
+// Timing of the MQTT connection.
+const (
+	mqttKeepAlive = 60 * time.Second
+	mqttTimeout   = 10 * time.Second
+	// mqttQuiesce is the time to finish the pending work on disconnect.
+	mqttQuiesce = 250 * time.Millisecond
+)
+
+// mqttSubscribeFailure is the return code of a refused subscription.
+const mqttSubscribeFailure = 0x80
+
+// MQTTTrigger subscribes to a topic of an MQTT broker and requests a display
+// update for every message, e.g. from Home Assistant when a door opens. With
+// discovery, it also publishes the state of the dashboard for Home Assistant.
+// Messages are subscribed to and published at QoS 0.
+type MQTTTrigger struct {
+	cfg     mqttConfig
+	refresh chan<- struct{}
+
+	mu sync.Mutex
+	// states are the latest values of the state topics, published again
+	// after a reconnect.
+	states map[string]string
+	// client is the client of Run, nil before Run.
+	client paho.Client
+
+	// dial replaces the TCP connection to the broker if set.
+	dial func() (net.Conn, error)
+	// retry is the time to wait before connecting again.
+	retry time.Duration
+}
+
+// NewMQTTTrigger creates a trigger that sends to refresh for every message.
+// Messages are dropped while an update is already pending.
+func NewMQTTTrigger(cfg mqttConfig, refresh chan<- struct{}) *MQTTTrigger {
+	if cfg.Port == 0 {
+		cfg.Port = defaultMQTTPort
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = defaultMQTTClientID
+	}
+	if cfg.DiscoveryPrefix == "" {
+		cfg.DiscoveryPrefix = defaultMQTTDiscoveryPrefix
+	}
+
+	return &MQTTTrigger{cfg: cfg, refresh: refresh, states: make(map[string]string), retry: retryInterval}
+}
+
+// clientOptions returns the options of the client for the broker of the
+// config. Lost connections are re-established by the client.
+func (t *MQTTTrigger) clientOptions() *paho.ClientOptions {
+	opts := paho.NewClientOptions().
+		AddBroker("tcp://" + net.JoinHostPort(t.cfg.Broker, strconv.Itoa(t.cfg.Port))).
+		SetClientID(t.cfg.ClientID).
+		SetUsername(t.cfg.Username).
+		SetPassword(t.cfg.Password).
+		SetCleanSession(true).
+		SetKeepAlive(mqttKeepAlive).
+		SetConnectTimeout(mqttTimeout).
+		SetWriteTimeout(mqttTimeout).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(t.retry).
+		SetOnConnectHandler(t.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			slog.Warn("lost MQTT connection", "broker", t.cfg.Broker, "error", err)
+		})
+
+	if t.dial != nil {
+		opts.SetCustomOpenConnectionFn(func(*url.URL, paho.ClientOptions) (net.Conn, error) {
+			return t.dial()
+		})
+	}
+
+	return opts
+}
+
+// SetState publishes the value of a sensor of haSensors, e.g.
+// haSensorDisplay. It is published once connected if the broker is not
+// reachable. Without discovery or on a nil trigger, it does nothing.
+func (t *MQTTTrigger) SetState(sensor, value string) {
+	if t == nil || !t.cfg.Discovery {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.states[sensor] = value
+	if t.client != nil && t.client.IsConnectionOpen() {
+		if err := publishRetained(t.client, t.stateTopic(sensor), []byte(value)); err != nil {
+			slog.Warn("failed to publish MQTT state", "sensor", sensor, "error", err)
+		}
+	}
+}
+
+// Run connects to the broker and stays subscribed until ctx is cancelled.
+// The first connection is retried after retryInterval, lost connections
+// are re-established by the client.
+func (t *MQTTTrigger) Run(ctx context.Context) {
+	client := paho.NewClient(t.clientOptions())
+	t.mu.Lock()
+	t.client = client
+	t.mu.Unlock()
+	defer client.Disconnect(uint(mqttQuiesce / time.Millisecond))
+
+	for {
+		token := client.Connect()
+		select {
+		case <-ctx.Done():
+			return
+		case <-token.Done():
+		}
+		if token.Error() == nil {
+			break
+		}
+
+		slog.Warn("failed to connect to MQTT broker", "broker", t.cfg.Broker, "error", token.Error())
+		if sleepContext(ctx, t.retry) != nil {
+			return
+		}
+	}
+
+	<-ctx.Done()
+}
+
+// onConnect subscribes to the topic and publishes the discovery configs and
+// the current states after every connect, the broker forgets the
+// subscriptions of a clean session.
+func (t *MQTTTrigger) onConnect(client paho.Client) {
+	if t.cfg.Topic != "" {
+		token := client.Subscribe(t.cfg.Topic, 0, t.handleMessage)
+		err := waitToken(token)
+		if result, ok := token.(*paho.SubscribeToken); ok && err == nil && result.Result()[t.cfg.Topic] == mqttSubscribeFailure {
+			err = errors.New("refused by the broker")
+		}
+		if err != nil {
+			slog.Warn("failed to subscribe to MQTT topic", "topic", t.cfg.Topic, "error", err)
+		} else {
+			slog.Info("subscribed to MQTT topic", "broker", t.cfg.Broker, "topic", t.cfg.Topic)
+		}
+	}
+
+	if err := t.publishStates(client); err != nil {
+		slog.Warn("failed to publish Home Assistant discovery", "error", err)
+	}
+}
+
+// handleMessage requests an update, unless one is pending already.
+func (t *MQTTTrigger) handleMessage(_ paho.Client, msg paho.Message) {
+	slog.Info("received MQTT message, requesting an update", "topic", msg.Topic())
+	select {
+	case t.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// publishStates publishes the discovery configs and the current states.
+func (t *MQTTTrigger) publishStates(client paho.Client) error {
+	if !t.cfg.Discovery {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, sensor := range haSensors {
+		payload, err := json.Marshal(t.discoveryConfig(sensor))
+		if err != nil {
+			return fmt.Errorf("failed to encode discovery config: %w", err)
+		}
+		if err = publishRetained(client, t.discoveryTopic(sensor), payload); err != nil {
+			return fmt.Errorf("failed to publish discovery config: %w", err)
+		}
+	}
+
+	for sensor, value := range t.states {
+		if err := publishRetained(client, t.stateTopic(sensor), []byte(value)); err != nil {
+			return fmt.Errorf("failed to publish state: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// publishRetained publishes a message at QoS 0 and waits until it is sent.
+// Retained messages are kept by the broker and sent to every new subscriber.
+func publishRetained(client paho.Client, topic string, payload []byte) error {
+	return waitToken(client.Publish(topic, 0, true, payload))
+}
+
+// waitToken waits for the operation of token and returns its error.
+func waitToken(token paho.Token) error {
+	if !token.WaitTimeout(mqttTimeout) {
+		return errors.New("timed out waiting for the MQTT broker")
+	}
+	return token.Error()
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// fakeMQTTBroker is a broker on the other end of a net.Pipe. It answers
+// CONNECT, SUBSCRIBE and PINGREQ, and passes every packet it receives to
+// packets.
+type fakeMQTTBroker struct {
+	conn    net.Conn
+	packets chan packets.ControlPacket
+}
+
+// serve reads the packets of the client until the connection is closed.
+func (b *fakeMQTTBroker) serve(returnCode byte) {
+	defer close(b.packets)
+
+	for {
+		packet, err := packets.ReadPacket(b.conn)
+		if err != nil {
+			return
+		}
+		b.packets <- packet
+
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			ack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+			ack.ReturnCode = returnCode
+			ack.Write(b.conn)
+		case *packets.SubscribePacket:
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = make([]byte, len(p.Topics))
+			ack.Write(b.conn)
+		case *packets.PingreqPacket:
+			packets.NewControlPacket(packets.Pingresp).Write(b.conn)
+		}
+	}
+}
+
+// publish sends a message to the client. Messages at QoS 1 need the
+// messageID, the client acknowledges them after handling them.
+func (b *fakeMQTTBroker) publish(topic, payload string, qos byte, messageID uint16) {
+	packet := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	packet.TopicName = topic
+	packet.Payload = []byte(payload)
+	packet.Qos = qos
+	packet.MessageID = messageID
+	packet.Write(b.conn)
+}
+
+// next returns the next packet the broker received.
+func (b *fakeMQTTBroker) next(t *testing.T) packets.ControlPacket {
+	t.Helper()
+	select {
+	case packet, ok := <-b.packets:
+		if !ok {
+			t.Fatal("connection closed")
+		}
+		return packet
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a packet")
+	}
+	return nil
+}
+
+// newFakeMQTTTrigger returns a trigger whose connections are passed to
+// the returned channel as fake brokers. The brokers answer the connections
+// with the return codes in turn, and accept the remaining ones.
+func newFakeMQTTTrigger(cfg mqttConfig, refresh chan<- struct{}, returnCodes ...byte) (*MQTTTrigger, <-chan *fakeMQTTBroker) {
+	brokers := make(chan *fakeMQTTBroker, 1)
+
+	trigger := NewMQTTTrigger(cfg, refresh)
+	trigger.retry = 10 * time.Millisecond
+	trigger.dial = func() (net.Conn, error) {
+		var returnCode byte
+		if len(returnCodes) > 0 {
+			returnCode, returnCodes = returnCodes[0], returnCodes[1:]
+		}
+
+		client, server := net.Pipe()
+		broker := &fakeMQTTBroker{conn: server, packets: make(chan packets.ControlPacket, 16)}
+		go broker.serve(returnCode)
+		brokers <- broker
+		return client, nil
+	}
+
+	return trigger, brokers
+}
+
+// nextBroker returns the broker of the next connection.
+func nextBroker(t *testing.T, brokers <-chan *fakeMQTTBroker) *fakeMQTTBroker {
+	t.Helper()
+	select {
+	case broker := <-brokers:
+		return broker
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a connection")
+	}
+	return nil
+}
+
+// expectConnect checks that the client connects with a clean session, the
+// keep alive and the credentials of cfg.
+func expectConnect(t *testing.T, broker *fakeMQTTBroker, cfg mqttConfig) {
+	t.Helper()
+
+	connect, ok := broker.next(t).(*packets.ConnectPacket)
+	if !ok {
+		t.Fatal("first packet is not CONNECT")
+	}
+	if connect.ClientIdentifier != cfg.ClientID || connect.Username != cfg.Username || string(connect.Password) != cfg.Password {
+		t.Errorf("connected as %q with %q:%q, want %q with %q:%q", connect.ClientIdentifier, connect.Username, connect.Password, cfg.ClientID, cfg.Username, cfg.Password)
+	}
+	if !connect.CleanSession || connect.Keepalive != uint16(mqttKeepAlive/time.Second) {
+		t.Errorf("got clean session %v and keep alive %d, want a clean session and %v", connect.CleanSession, connect.Keepalive, mqttKeepAlive)
+	}
+}
+
+// expectSubscription checks that the client connects and subscribes to topic.
+func expectSubscription(t *testing.T, broker *fakeMQTTBroker, cfg mqttConfig) {
+	t.Helper()
+
+	expectConnect(t, broker, cfg)
+	subscribe, ok := broker.next(t).(*packets.SubscribePacket)
+	if !ok {
+		t.Fatal("second packet is not SUBSCRIBE")
+	}
+	if len(subscribe.Topics) != 1 || subscribe.Topics[0] != cfg.Topic || subscribe.Qoss[0] != 0 {
+		t.Fatalf("subscribed to %v at QoS %v, want %s at QoS 0", subscribe.Topics, subscribe.Qoss, cfg.Topic)
+	}
+}
+
+// expectRefresh waits for an update to be requested.
+func expectRefresh(t *testing.T, refresh <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-refresh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no update was requested")
+	}
+}
+
+func TestMQTTTrigger(t *testing.T) {
+	cfg := mqttConfig{Broker: "broker", Topic: "home/door", ClientID: "epd", Username: "u", Password: "pw"}
+	refresh := make(chan struct{}, 1)
+	trigger, brokers := newFakeMQTTTrigger(cfg, refresh)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		trigger.Run(ctx)
+		close(done)
+	}()
+
+	broker := nextBroker(t, brokers)
+	expectSubscription(t, broker, cfg)
+
+	broker.publish("home/door", "open", 0, 0)
+	expectRefresh(t, refresh)
+
+	// Messages are dropped while an update is pending. The client
+	// acknowledges the last message only after it handled all of them.
+	broker.publish("home/door", "open", 0, 0)
+	broker.publish("home/door", "closed", 0, 0)
+	broker.publish("home/door", "open", 1, 7)
+	if ack, ok := broker.next(t).(*packets.PubackPacket); !ok || ack.MessageID != 7 {
+		t.Fatal("the message at QoS 1 was not acknowledged")
+	}
+	expectRefresh(t, refresh)
+	select {
+	case <-refresh:
+		t.Error("a pending update was requested twice")
+	default:
+	}
+
+	// A lost connection is re-established and subscribed again.
+	broker.conn.Close()
+	broker = nextBroker(t, brokers)
+	expectSubscription(t, broker, cfg)
+	broker.publish("home/door", "open", 0, 0)
+	expectRefresh(t, refresh)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after the context was cancelled")
+	}
+}
+
+func TestMQTTTriggerRefused(t *testing.T) {
+	cfg := mqttConfig{Broker: "broker", Topic: "home/door", ClientID: "epd"}
+	refresh := make(chan struct{}, 1)
+	// Return code 5 is "not authorized", the second connection is accepted.
+	trigger, brokers := newFakeMQTTTrigger(cfg, refresh, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go trigger.Run(ctx)
+
+	expectConnect(t, nextBroker(t, brokers), cfg)
+
+	// The refused connection is retried.
+	broker := nextBroker(t, brokers)
+	expectSubscription(t, broker, cfg)
+	broker.publish("home/door", "open", 0, 0)
+	expectRefresh(t, refresh)
+}
+
+func TestMQTTTriggerDiscovery(t *testing.T) {
+	cfg := mqttConfig{Broker: "broker", ClientID: "epd", Discovery: true}
+	trigger, brokers := newFakeMQTTTrigger(cfg, nil)
+
+	// The state set before the connection is published once connected.
+	trigger.SetState(haSensorDisplay, haDisplaySleeping)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go trigger.Run(ctx)
+
+	broker := nextBroker(t, brokers)
+	expectConnect(t, broker, cfg)
+
+	// Without a topic, nothing is subscribed. Everything is retained.
+	expectPublish := func() *packets.PublishPacket {
+		t.Helper()
+		publish, ok := broker.next(t).(*packets.PublishPacket)
+		if !ok {
+			t.Fatal("got a packet other than PUBLISH")
+		}
+		if !publish.Retain || publish.Qos != 0 {
+			t.Errorf("%s published with retain %v at QoS %d, want retained at QoS 0", publish.TopicName, publish.Retain, publish.Qos)
+		}
+		return publish
+	}
+	published := make(map[string]string)
+	for range len(haSensors) + 1 {
+		publish := expectPublish()
+		published[publish.TopicName] = string(publish.Payload)
+	}
+	for _, sensor := range haSensors {
+		if _, ok := published[trigger.discoveryTopic(sensor)]; !ok {
+			t.Errorf("no discovery config for %s", sensor)
+		}
+	}
+	if got := published[trigger.stateTopic(haSensorDisplay)]; got != haDisplaySleeping {
+		t.Errorf("display state = %q, want %q", got, haDisplaySleeping)
+	}
+
+	// States are published right away while connected.
+	trigger.SetState(haSensorDisplay, haDisplayOn)
+	if publish := expectPublish(); publish.TopicName != trigger.stateTopic(haSensorDisplay) || string(publish.Payload) != haDisplayOn {
+		t.Errorf("published %q to %s, want the display state", publish.Payload, publish.TopicName)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// wirelessStatusPath lists the wireless interfaces and their signal on Linux.
+const wirelessStatusPath = "/proc/net/wireless"
+
+// NetworkStatus is the state of the wireless connection.
+type NetworkStatus struct {
+	// Connected is false if no wireless interface is associated
+	Connected bool
+	// Interface is the name of the wireless interface, e.g. wlan0
+	Interface string
+	// SSID is the name of the network, empty if iwgetid is not available
+	SSID string
+	// SignalDBm is the signal level in dBm
+	SignalDBm int
+}
+
+// Bars maps the signal level to 0 to 4 bars: 4 above -55 dBm, 3 above
+// -65 dBm, 2 above -75 dBm, 1 above -85 dBm and 0 at or below it.
+func (s NetworkStatus) Bars() int {
+	switch {
+	case !s.Connected:
+		return 0
+	case s.SignalDBm > -55:
+		return 4
+	case s.SignalDBm > -65:
+		return 3
+	case s.SignalDBm > -75:
+		return 2
+	case s.SignalDBm > -85:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// FetchNetworkStatus reads the signal level of the first wireless interface
+// from /proc/net/wireless and its SSID from iwgetid. A missing SSID is not an error.
+func FetchNetworkStatus(ctx context.Context) (NetworkStatus, error) {
+	f, err := os.Open(wirelessStatusPath)
+	if err != nil {
+		return NetworkStatus{}, fmt.Errorf("failed to read wireless status: %w", err)
+	}
+	defer f.Close()
+
+	status, err := parseWirelessStatus(bufio.NewScanner(f))
+	if err != nil || !status.Connected {
+		return status, err
+	}
+
+	out, err := exec.CommandContext(ctx, "iwgetid", "-r", status.Interface).Output()
+	if err == nil {
+		status.SSID = strings.TrimSpace(string(out))
+	}
+
+	return status, nil
+}
+
+// parseWirelessStatus parses the first interface of /proc/net/wireless:
+//
+//	Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE
+//	 face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22
+//	 wlan0: 0000   54.  -56.  -256        0      0      0      0      0        0
+func parseWirelessStatus(scanner *bufio.Scanner) (NetworkStatus, error) {
+	for line := 0; scanner.Scan(); line++ {
+		// Skip the two header lines
+		if line < 2 {
+			continue
+		}
+
+		name, values, ok := strings.Cut(scanner.Text(), ":")
+		fields := strings.Fields(values)
+		if !ok || len(fields) < 3 {
+			return NetworkStatus{}, fmt.Errorf("invalid wireless status: %q", scanner.Text())
+		}
+
+		level, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "."), 64)
+		if err != nil {
+			return NetworkStatus{}, fmt.Errorf("invalid signal level %q: %w", fields[2], err)
+		}
+
+		return NetworkStatus{
+			Connected: true,
+			Interface: strings.TrimSpace(name),
+			SignalDBm: int(level),
+		}, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return NetworkStatus{}, fmt.Errorf("failed to read wireless status: %w", err)
+	}
+
+	// No wireless interface is associated.
+	return NetworkStatus{}, nil
+}
+
+// Layout of the network widget.
+const (
+	networkIconSize = 14
+	networkSSIDLen  = 12
+)
+
+// drawNetworkStatus draws the signal bars in the top right corner of the
+// frame, with the SSID left of them unless only the signal is shown. The
+// icons are "wifi/bars-0.png" to "wifi/bars-4.png".
+func drawNetworkStatus(dc *dashboardCanvas, config *DashboardConfig, status NetworkStatus) error {
+	right := config.Width - config.Padding - 8
+	bottom := config.Padding + 20
+
+	icon := fmt.Sprintf("wifi/bars-%d.png", status.Bars())
+	err := addImage(dc, icon, image.Point{X: right, Y: bottom}, networkIconSize, networkIconSize, 1, 1, nil)
+	if err != nil {
+		return err
+	}
+
+	if !config.ShowNetworkStatus {
+		return nil
+	}
+
+	if err = setFont(dc.Context, FontRegular, FontSizeXXXS); err != nil {
+		return err
+	}
+
+	label := status.SSID
+	switch {
+	case !status.Connected && config.Locale == LocaleEnglish:
+		label = "No WiFi"
+	case !status.Connected:
+		label = "Kein WLAN"
+	case label == "":
+		label = status.Interface
+	}
+
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(limit(label, networkSSIDLen), float64(right-networkIconSize-5), float64(bottom), 1, 0)
+
+	return nil
+}
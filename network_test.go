@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestNetworkStatusBars(t *testing.T) {
+	tests := []struct {
+		status NetworkStatus
+		want   int
+	}{
+		{NetworkStatus{Connected: true, SignalDBm: -40}, 4},
+		{NetworkStatus{Connected: true, SignalDBm: -54}, 4},
+		{NetworkStatus{Connected: true, SignalDBm: -55}, 3},
+		{NetworkStatus{Connected: true, SignalDBm: -65}, 2},
+		{NetworkStatus{Connected: true, SignalDBm: -66}, 2},
+		{NetworkStatus{Connected: true, SignalDBm: -75}, 1},
+		{NetworkStatus{Connected: true, SignalDBm: -84}, 1},
+		{NetworkStatus{Connected: true, SignalDBm: -85}, 0},
+		{NetworkStatus{Connected: true, SignalDBm: -95}, 0},
+		{NetworkStatus{Connected: false, SignalDBm: -40}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.Bars(); got != tt.want {
+			t.Errorf("Bars of %+v = %d, want %d", tt.status, got, tt.want)
+		}
+	}
+}
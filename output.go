@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/image/bmp"
+)
+
+// Output formats of RenderTo.
+const (
+	// OutputPNG is a PNG image of the dashboard
+	OutputPNG = "png"
+	// OutputBMP is a BMP image of the dashboard, e.g. for other e-paper tools
+	OutputBMP = "bmp"
+	// OutputRaw is the packed 4-bit buffer that is sent to the panel
+	OutputRaw = "raw"
+)
+
+// defaultOutputPath is used if neither -output nor output.path is set.
+const defaultOutputPath = "dash.png"
+
+// historyTimeFormat is the timestamp in the names of the kept renders.
+const historyTimeFormat = "20060102T1504"
+
+// isOutputFormat reports whether RenderTo supports format.
+func isOutputFormat(format string) bool {
+	switch format {
+	case OutputPNG, OutputBMP, OutputRaw:
+		return true
+	}
+	return false
+}
+
+// RenderTo writes img to w in the given format (png, bmp or raw).
+func RenderTo(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case OutputPNG:
+		return png.Encode(w, img)
+	case OutputBMP:
+		return bmp.Encode(w, img)
+	case OutputRaw:
+		buf, err := PackImage(img, PackOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to convert image to buffer: %w", err)
+		}
+		_, err = w.Write(buf)
+		return err
+	default:
+		return fmt.Errorf("unknown output format: %s (expected png, bmp or raw)", format)
+	}
+}
+
+// saveDashboard writes img in the given format to the file at path, or to
+// standard output if path is "-". The file is written to a temporary file
+// next to it first and renamed, so readers never see a partial image.
+// The rename is only atomic on POSIX systems. On Windows, os.Rename fails if
+// a reader has the file open, and the old image stays in place.
+func saveDashboard(img image.Image, path, format string) error {
+	if path == "-" {
+		return RenderTo(os.Stdout, img, format)
+	}
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return RenderTo(w, img, format)
+	})
+}
+
+// writeFileAtomic writes the file at path with write. See saveDashboard for
+// the temporary file.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(f.Name()) // fails once the file is renamed
+
+	err = errors.Join(f.Chmod(0o644), write(f))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if err = os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// historyPath returns the path of the render of now kept next to path
+// (e.g., "dash-20250614T0630.png" for "dash.png").
+func historyPath(path string, now time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + now.Format(historyTimeFormat) + ext
+}
+
+// keepHistory saves img as the render of now next to path and removes all
+// but the newest keep renders.
+func keepHistory(img image.Image, path, format string, keep int, now time.Time) error {
+	if err := saveDashboard(img, historyPath(path, now), format); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(path)
+	pattern := strings.TrimSuffix(path, ext) + "-" + strings.Repeat("?", len(historyTimeFormat)) + ext
+	renders, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("failed to list kept renders: %w", err)
+	}
+
+	// The timestamps sort chronologically, the newest render is last.
+	slices.Sort(renders)
+
+	var errs []error
+	for _, render := range renders[:max(len(renders)-keep, 0)] {
+		if err = os.Remove(render); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Debug("removed kept render", "file", render)
+	}
+
+	if err = errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to prune kept renders: %w", err)
+	}
+
+	return nil
+}
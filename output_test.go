@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/image/bmp"
+)
+
+// solidImage returns an image of the panel's size filled with c.
+func solidImage(c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, EPD_WIDTH, EPD_HEIGHT))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, 0xff
+	}
+	return img
+}
+
+// dirEntries returns the names of the files in dir.
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestSaveDashboard(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dash.png")
+
+	if err := saveDashboard(solidImage(ColorRed), path, OutputPNG); err != nil {
+		t.Fatal(err)
+	}
+	// The file is replaced, not appended to.
+	if err := saveDashboard(solidImage(ColorBlue), path, OutputPNG); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := color.RGBAModel.Convert(img.At(10, 10)); got != ColorBlue {
+		t.Errorf("pixel is %v, want the blue of the second save", got)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o644 {
+		t.Errorf("file mode is %v, want 0644", perm)
+	}
+
+	// A failed render keeps the old file and removes the temporary one.
+	if err = saveDashboard(solidImage(ColorRed), path, "gif"); err == nil {
+		t.Error("saving an unknown format succeeded")
+	}
+	if names := dirEntries(t, dir); !slices.Equal(names, []string{"dash.png"}) {
+		t.Errorf("directory has %v, want only dash.png", names)
+	}
+	if info2, err := os.Stat(path); err != nil || info2.Size() != info.Size() || !info2.ModTime().Equal(info.ModTime()) {
+		t.Errorf("the failed save changed the file")
+	}
+
+	if err = saveDashboard(solidImage(ColorRed), filepath.Join(dir, "missing", "dash.png"), OutputPNG); err == nil {
+		t.Error("saving into a missing directory succeeded")
+	}
+}
+
+func TestKeepHistory(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dash.png")
+	start := time.Date(2025, time.March, 14, 9, 0, 0, 0, time.UTC)
+
+	// Files that only look like renders are left alone.
+	others := []string{"dash.png", "dash-notes.png", "dash-20250314T0900.bmp", "other-20250314T0800.png"}
+	for _, name := range others {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	img := solidImage(ColorWhite)
+	for i := range 5 {
+		if err := keepHistory(img, path, OutputPNG, 3, start.Add(time.Duration(i)*15*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	slices.Sort(others)
+	want := append([]string{"dash-20250314T0930.png", "dash-20250314T0945.png", "dash-20250314T1000.png"}, others...)
+	slices.Sort(want)
+	if got := dirEntries(t, dir); !slices.Equal(got, want) {
+		t.Errorf("directory has\n%v, want\n%v", got, want)
+	}
+
+	// keep 0 removes even the new render.
+	if err := keepHistory(img, path, OutputPNG, 0, start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := dirEntries(t, dir); !slices.Equal(got, others) {
+		t.Errorf("directory has %v after keeping none, want %v", got, others)
+	}
+}
+
+func TestRenderTo(t *testing.T) {
+	dc, err := GenerateDashboard(fixtureConfig(time.Date(2025, time.March, 14, 9, 30, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canvas := dc.Image()
+
+	decoders := map[string]func(r *bytes.Reader) (image.Image, error){
+		OutputPNG: func(r *bytes.Reader) (image.Image, error) { return png.Decode(r) },
+		OutputBMP: func(r *bytes.Reader) (image.Image, error) { return bmp.Decode(r) },
+	}
+	for format, decode := range decoders {
+		var buf bytes.Buffer
+		if err = RenderTo(&buf, canvas, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		img, err := decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		if img.Bounds() != canvas.Bounds() {
+			t.Fatalf("%s: bounds %v, want %v", format, img.Bounds(), canvas.Bounds())
+		}
+		diff := 0
+		for y := canvas.Bounds().Min.Y; y < canvas.Bounds().Max.Y; y++ {
+			for x := canvas.Bounds().Min.X; x < canvas.Bounds().Max.X; x++ {
+				if color.RGBAModel.Convert(img.At(x, y)) != color.RGBAModel.Convert(canvas.At(x, y)) {
+					diff++
+				}
+			}
+		}
+		if diff > 0 {
+			t.Errorf("%s: %d pixels differ from the canvas", format, diff)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = RenderTo(&buf, canvas, OutputRaw); err != nil {
+		t.Fatal(err)
+	}
+	packed, err := PackImage(canvas, PackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), packed) {
+		t.Errorf("raw output of %d bytes differs from the packed image", buf.Len())
+	}
+
+	if err = RenderTo(&buf, canvas, "gif"); err == nil {
+		t.Error("rendering an unknown format succeeded")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+)
+
+var (
+	ColorBlack  = color.RGBA{0x00, 0x00, 0x00, 0xff}
+	ColorWhite  = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	ColorYellow = color.RGBA{0xff, 0xff, 0x00, 0xff}
+	ColorRed    = color.RGBA{0xff, 0x00, 0x00, 0xff}
+	ColorBlue   = color.RGBA{0x00, 0x00, 0xff, 0xff}
+	ColorGreen  = color.RGBA{0x00, 0xff, 0x00, 0xff}
+)
+
+// panelColors are the 6 colors of the panel with the 4-bit codes the
+// controller expects for them. Code 0x04 is not used by the 7.3" (E) panel.
+// ColorPalette and ColorPaletteBinary are derived from this table, so a
+// color can't be paired with the code of another one.
+var panelColors = []struct {
+	color color.RGBA
+	code  uint8
+}{
+	{ColorBlack, 0x00},
+	{ColorWhite, 0x01},
+	{ColorYellow, 0x02},
+	{ColorRed, 0x03},
+	{ColorBlue, 0x05},
+	{ColorGreen, 0x06},
+}
+
+// ColorPalette with the 6 colors supported by the panel
+var ColorPalette = func() color.Palette {
+	palette := make(color.Palette, len(panelColors))
+	for i, c := range panelColors {
+		palette[i] = c.color
+	}
+	return palette
+}()
+
+// ColorPaletteBinary are the codes of the colors of ColorPalette at the same index.
+var ColorPaletteBinary = func() []uint8 {
+	codes := make([]uint8, len(panelColors))
+	for i, c := range panelColors {
+		codes[i] = c.code
+	}
+	return codes
+}()
+
+// PackOptions configures PackImage.
+type PackOptions struct {
+	// DitherSize dithers the image to black and white with a Bayer matrix of
+	// this size (2, 4 or 8) before packing, like render.grayscale. The image
+	// is snapped to the closest palette colors if it is 0.
+	DitherSize int
+}
+
+// packedSize is the size of a packed image, two pixels per byte.
+const packedSize = EPD_WIDTH * EPD_HEIGHT / 2
+
+// PackImage converts img to the byte buffer the panel expects: the 6 colors
+// of the panel, two pixels per byte. The image must be 800x480 or, in
+// portrait orientation, 480x800. It needs no hardware, so the buffer can be
+// packed on another machine and sent with Epd.DisplayRaw.
+func PackImage(img image.Image, opts PackOptions) ([]byte, error) {
+	buf := make([]byte, packedSize)
+	if err := packInto(buf, img, opts); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// packInto packs img like PackImage into buf, which must have packedSize
+// bytes. It converts and packs in a single pass, images in portrait
+// orientation are rotated on the fly.
+func packInto(buf []byte, img image.Image, opts PackOptions) error {
+	if opts.DitherSize > 0 {
+		img = ditherOrdered(img, opts.DitherSize)
+	}
+
+	bounds := img.Bounds()
+
+	var rotate bool
+	switch {
+	case bounds.Dx() == EPD_WIDTH && bounds.Dy() == EPD_HEIGHT:
+	case bounds.Dx() == EPD_HEIGHT && bounds.Dy() == EPD_WIDTH:
+		rotate = true
+	default:
+		return fmt.Errorf("invalid image dimensions: %d x %d, expected %d x %d",
+			bounds.Dx(), bounds.Dy(), EPD_WIDTH, EPD_HEIGHT)
+	}
+
+	lut := paletteLookup()
+	rgba, _ := img.(*image.RGBA)
+
+	// colorAt returns the panel color of the pixel at x, y of the panel.
+	colorAt := func(x, y int) uint8 {
+		if rotate {
+			// The portrait image is rotated 90 degrees clockwise.
+			x, y = y, EPD_WIDTH-1-x
+		}
+		x += bounds.Min.X
+		y += bounds.Min.Y
+
+		if rgba != nil {
+			i := rgba.PixOffset(x, y)
+			return lut[lookupIndex(rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2])]
+		}
+		c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+		return lut[lookupIndex(c.R, c.G, c.B)]
+	}
+
+	idx := 0
+	for y := range EPD_HEIGHT {
+		for x := 0; x < EPD_WIDTH; x += 2 {
+			buf[idx] = colorAt(x, y)<<4 | colorAt(x+1, y)
+			idx++
+		}
+	}
+
+	return nil
+}
+
+// lookupIndex returns the index of a color in the lookup table of
+// paletteLookup, which has 5 bits per channel.
+func lookupIndex(r, g, b uint8) int {
+	return int(r>>3)<<10 | int(g>>3)<<5 | int(b>>3)
+}
+
+// paletteLookup returns a table with the panel color (ColorPaletteBinary)
+// of the closest palette color for every 15-bit color. The nearest color
+// search of color.Palette is too slow to run for every pixel on a Pi Zero.
+var paletteLookup = sync.OnceValue(func() *[1 << 15]uint8 {
+	var lut [1 << 15]uint8
+	for i := range lut {
+		// Scale the 5 bits of every channel back to 8 bits.
+		expand := func(v int) uint8 { return uint8(v<<3 | v>>2) }
+		c := color.RGBA{expand(i >> 10 & 0x1f), expand(i >> 5 & 0x1f), expand(i & 0x1f), 0xff}
+		lut[i] = ColorPaletteBinary[ColorPalette.Index(c)]
+	}
+	return &lut
+})
+
+// colorBars returns a packed buffer with a vertical bar of every panel color.
+func colorBars() []byte {
+	buf := make([]byte, packedSize)
+	for i := range buf {
+		// Two pixels per byte, the bars are wider than that.
+		x := i % (EPD_WIDTH / 2) * 2
+		code := panelColors[x*len(panelColors)/EPD_WIDTH].code
+		buf[i] = code<<4 | code
+	}
+	return buf
+}
+
+// quantizeImage converts an image to a quantized version using the given palette.
+func quantizeImage(img image.Image, palette color.Palette) *image.Paletted {
+	bounds := img.Bounds()
+	quantized := image.NewPaletted(bounds, palette)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			originalColor := img.At(x, y)
+			closestColor := palette.Convert(originalColor)
+			quantized.Set(x, y, closestColor)
+		}
+	}
+
+	return quantized
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// photoExtensions are the extensions of the photos that can be decoded.
+var photoExtensions = []string{".png", ".jpg", ".jpeg"}
+
+// photosIn returns the PNG and JPEG files in dir sorted by name. Other
+// files are skipped with a warning, directories and hidden files silently.
+func photosIn(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read photo directory: %w", err)
+	}
+
+	var photos []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if !slices.Contains(photoExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			slog.Warn("skipping unsupported photo", "file", entry.Name())
+			continue
+		}
+		photos = append(photos, filepath.Join(dir, entry.Name()))
+	}
+
+	// ReadDir sorts by name already, but the order must not depend on it.
+	slices.Sort(photos)
+
+	return photos, nil
+}
+
+// dayIndex returns the index of the entry of the day of t among n entries.
+// It changes at midnight and cycles through all entries.
+func dayIndex(t time.Time, n int) int {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60)
+	return int(day % int64(n))
+}
+
+// loadPhoto loads the photo of the day of now from dir, scaled to fit into
+// width and height and quantized to the palette of the panel. Photos that
+// can't be decoded are skipped with a warning in favor of the next one.
+func loadPhoto(dir string, now time.Time, width, height int) (image.Image, error) {
+	photos, err := photosIn(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(photos) == 0 {
+		return nil, fmt.Errorf("no photos in %s", dir)
+	}
+
+	first := dayIndex(now, len(photos))
+	for i := range photos {
+		path := photos[(first+i)%len(photos)]
+
+		photo, err := decodePhoto(path)
+		if err != nil {
+			slog.Warn("skipping photo", "file", path, "error", err)
+			continue
+		}
+
+		// Keep the aspect ratio, the rest of the rectangle stays empty.
+		bounds := photo.Bounds()
+		if bounds.Dx()*height > bounds.Dy()*width {
+			height = 0
+		} else {
+			width = 0
+		}
+
+		return quantizeImage(scaleImage(photo, width, height, draw.CatmullRom), ColorPalette), nil
+	}
+
+	return nil, fmt.Errorf("no readable photos in %s", dir)
+}
+
+// decodePhoto decodes the PNG or JPEG file at path.
+func decodePhoto(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	photo, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode photo: %w", err)
+	}
+
+	return photo, nil
+}
+
+// drawPhoto draws the photo of the day centered into rect on a white
+// background.
+func drawPhoto(dc *dashboardCanvas, rect image.Rectangle, dir string, now time.Time) error {
+	photo, err := loadPhoto(dir, now, rect.Dx(), rect.Dy())
+	if err != nil {
+		return err
+	}
+
+	dc.SetColor(color.White)
+	dc.DrawRectangle(float64(rect.Min.X), float64(rect.Min.Y), float64(rect.Dx()), float64(rect.Dy()))
+	dc.Fill()
+
+	center := rect.Min.Add(rect.Size().Div(2))
+	dc.DrawImageAnchored(photo, center.X, center.Y, 0.5, 0.5)
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"net/http"
+)
+
+// PollenSource provides the pollen forecast of today.
+type PollenSource interface {
+	// FetchPollenCount returns the pollen level of every species at the location.
+	FetchPollenCount(ctx context.Context, lat, lon float64) ([]PollenEntry, error)
+}
+
+// PollenEntry is the pollen level of a species.
+type PollenEntry struct {
+	Species string
+	// Level is 0 (none) to 4 (high)
+	Level int
+}
+
+// maxPollenLevel is the highest level of a PollenEntry.
+const maxPollenLevel = 4
+
+// dwdPollenEndpoint is the pollen forecast of the Deutscher Wetterdienst.
+var dwdPollenEndpoint = "https://opendata.dwd.de/climate_environment/health/alerts/s31fg.json"
+
+// DWDPollenSource reads the pollen forecast of the Deutscher Wetterdienst.
+// The DWD publishes the forecast per region instead of per coordinate, so
+// the location is ignored and the configured part region is used.
+type DWDPollenSource struct {
+	// PartRegion is the partregion_id of the forecast, e.g. 11 for
+	// "Inseln und Marschen", or the region_id of regions without parts
+	PartRegion int
+	// Species are the DWD names of the species, e.g. Birke, Graeser or Hasel
+	Species []string
+	// Client fetches the forecast
+	Client *http.Client
+}
+
+// NewDWDPollenSource creates a DWD source for the part region and the species.
+func NewDWDPollenSource(partRegion int, species []string, client *http.Client) *DWDPollenSource {
+	return &DWDPollenSource{PartRegion: partRegion, Species: species, Client: client}
+}
+
+// dwdPollenResponse is the part of the DWD pollen forecast we use.
+type dwdPollenResponse struct {
+	Content []struct {
+		RegionID     int `json:"region_id"`
+		PartRegionID int `json:"partregion_id"`
+		Pollen       map[string]struct {
+			Today string `json:"today"`
+		} `json:"Pollen"`
+	} `json:"content"`
+}
+
+// dwdPollenLevels maps the levels of the DWD (0 to 3 in half steps) to PollenEntry levels.
+var dwdPollenLevels = map[string]int{
+	"0":   0,
+	"0-1": 1,
+	"1":   1,
+	"1-2": 2,
+	"2":   2,
+	"2-3": 3,
+	"3":   4,
+}
+
+// FetchPollenCount fetches today's levels of the species in the part region.
+func (d *DWDPollenSource) FetchPollenCount(ctx context.Context, _, _ float64) ([]PollenEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dwdPollenEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pollen forecast: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch pollen forecast: unexpected status %s", resp.Status)
+	}
+
+	var response dwdPollenResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode pollen forecast: %w", err)
+	}
+
+	for _, region := range response.Content {
+		// Regions without parts have a partregion_id of -1.
+		if region.PartRegionID != d.PartRegion && (region.PartRegionID != -1 || region.RegionID != d.PartRegion) {
+			continue
+		}
+
+		entries := make([]PollenEntry, 0, len(d.Species))
+		for _, species := range d.Species {
+			forecast, ok := region.Pollen[species]
+			if !ok {
+				return nil, fmt.Errorf("unknown pollen species: %s", species)
+			}
+
+			// Missing forecasts ("-1") count as no pollen.
+			entries = append(entries, PollenEntry{Species: species, Level: dwdPollenLevels[forecast.Today]})
+		}
+		return entries, nil
+	}
+
+	return nil, fmt.Errorf("unknown pollen region: %d", d.PartRegion)
+}
+
+// pollenSpeciesNames are the displayed names of the DWD species.
+var pollenSpeciesNames = map[string]string{
+	"Graeser": "Gräser",
+	"Beifuss": "Beifuß",
+}
+
+// pollenLevelColors are the colors of the severity bars by level.
+var pollenLevelColors = [maxPollenLevel + 1]color.Color{
+	ColorGreen, ColorGreen, ColorYellow, ColorRed, ColorRed,
+}
+
+// Layout of the pollen widget.
+const (
+	pollenRowHeight  = 18
+	pollenLabelWidth = 70
+)
+
+// pollenHeight returns the height of the pollen widget with n species.
+func pollenHeight(n int) int {
+	return n * pollenRowHeight
+}
+
+// drawPollen draws a severity bar per species into rect, one row each.
+func drawPollen(dc *dashboardCanvas, rect image.Rectangle, entries []PollenEntry) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		top := rect.Min.Y + i*pollenRowHeight
+
+		name := entry.Species
+		if displayed, ok := pollenSpeciesNames[name]; ok {
+			name = displayed
+		}
+
+		dc.SetColor(color.Black)
+		dc.DrawStringAnchored(name, float64(rect.Min.X), float64(top+pollenRowHeight/2), 0, 0.35)
+
+		level := min(max(entry.Level, 0), maxPollenLevel)
+		bar := image.Rect(rect.Min.X+pollenLabelWidth, top+4, rect.Max.X, top+pollenRowHeight-4)
+		drawProgressBar(dc, bar, float64(level)/maxPollenLevel, pollenLevelColors[level])
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"time"
+)
+
+// Layout of the year progress widget.
+const (
+	progressRowHeight  = 18
+	progressLabelWidth = 70
+	progressValueWidth = 40
+)
+
+// yearProgress returns the elapsed fraction (0 to 1) of the year of t.
+func yearProgress(t time.Time) float64 {
+	start := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	return periodProgress(t, start, start.AddDate(1, 0, 0))
+}
+
+// monthProgress returns the elapsed fraction (0 to 1) of the month of t.
+func monthProgress(t time.Time) float64 {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return periodProgress(t, start, start.AddDate(0, 1, 0))
+}
+
+// periodProgress returns the elapsed fraction of the period from start to end at t.
+func periodProgress(t, start, end time.Time) float64 {
+	return min(max(t.Sub(start).Seconds()/end.Sub(start).Seconds(), 0), 1)
+}
+
+// yearProgressHeight returns the height of the year progress widget.
+func yearProgressHeight(withMonth bool) int {
+	if withMonth {
+		return 2 * progressRowHeight
+	}
+	return progressRowHeight
+}
+
+// drawYearProgress draws the elapsed part of the year as a bar into rect,
+// e.g. "2024 [bar] 34%". withMonth adds a second row for the month. The
+// year and the month are those of now in its location, so now must be in
+// the dashboard's location.
+func drawYearProgress(dc *dashboardCanvas, rect image.Rectangle, now time.Time, withMonth bool, locale Locale) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return err
+	}
+
+	drawProgressRow(dc, rect, rect.Min.Y, strconv.Itoa(now.Year()), yearProgress(now))
+
+	if withMonth {
+		name := months[now.Month()-1]
+		if locale == LocaleEnglish {
+			name = now.Month().String()
+		}
+		drawProgressRow(dc, rect, rect.Min.Y+progressRowHeight, name, monthProgress(now))
+	}
+
+	return nil
+}
+
+// drawProgressRow draws a labelled progress bar with the percentage at top.
+func drawProgressRow(dc *dashboardCanvas, rect image.Rectangle, top int, label string, progress float64) {
+	centerY := float64(top + progressRowHeight/2)
+
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(label, float64(rect.Min.X), centerY, 0, 0.35)
+	dc.DrawStringAnchored(fmt.Sprintf("%.0f%%", progress*100), float64(rect.Max.X), centerY, 1, 0.35)
+
+	bar := image.Rect(rect.Min.X+progressLabelWidth, top+4, rect.Max.X-progressValueWidth, top+progressRowHeight-4)
+	drawProgressBar(dc, bar, progress, ColorBlue)
+}
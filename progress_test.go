@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestYearProgress(t *testing.T) {
+	berlin := loadBerlin(t)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want float64
+	}{
+		{"start of the year", time.Date(2025, time.January, 1, 0, 0, 0, 0, berlin), 0},
+		// The clocks went forward by an hour since New Year.
+		{"middle of a leap year", time.Date(2024, time.July, 2, 0, 0, 0, 0, berlin), (183*24 - 1) / (366 * 24.0)},
+		// 23:30 UTC on New Year's Eve is the new year in Berlin.
+		{"new year in the location", time.Date(2024, time.December, 31, 23, 30, 0, 0, time.UTC).In(berlin), 0.5 / 24 / 365},
+		{"end of the year", time.Date(2025, time.December, 31, 23, 59, 59, 0, berlin), 1 - 1.0/(365*86400)},
+	}
+
+	for _, tt := range tests {
+		if got := yearProgress(tt.now); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: yearProgress = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// The month of the location is used as well.
+	now := time.Date(2025, time.March, 31, 22, 30, 0, 0, time.UTC).In(berlin)
+	if got := monthProgress(now); math.Abs(got-0.5/24/30) > 1e-9 {
+		t.Errorf("monthProgress at the start of April in Berlin = %v", got)
+	}
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"math/rand"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
 )
 
 var quoteEndpoint = "https://api.zitat-service.de"
@@ -19,6 +27,9 @@ type quoteResponse struct {
 type quote struct {
 	Text   string `json:"text"`
 	Author string `json:"author"`
+	// Pinned is set for the pinned quote of the config. It is shown in
+	// full, regardless of max_chars.
+	Pinned bool `json:"pinned,omitempty"`
 }
 
 var categoryIds = []int{
@@ -50,24 +61,116 @@ var languages = []string{
 
 var errInvalidQuote = fmt.Errorf("invalid quote")
 
-func fetchQuoteRetry(maxRetries int) (quote, error) {
-	var q quote
+// QuoteProvider provides the quotes of the footer.
+type QuoteProvider interface {
+	// FetchQuote returns a quote. Errors wrapping errInvalidQuote are
+	// retried with another quote.
+	FetchQuote(ctx context.Context) (quote, error)
+}
+
+// defaultQuoteTimeout is the default timeout of a single quote request.
+const defaultQuoteTimeout = 5 * time.Second
+
+// fetchQuoteRetry fetches quotes until a valid one is found. Every attempt
+// gets its own timeout, so a slow attempt does not use up the following ones.
+// Quotes whose hash is in recent are invalid as well, unless no other quote
+// was found after maxRetries attempts.
+func fetchQuoteRetry(ctx context.Context, provider QuoteProvider, maxRetries int, timeout time.Duration, recent []string) (quote, error) {
+	var q, repeated quote
 	var err error
 	for i := 0; i < maxRetries; i++ {
-		q, err = fetchQuote()
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		q, err = provider.FetchQuote(attemptCtx)
+		cancel()
+		if err == nil && slices.Contains(recent, quoteHash(q.Text)) {
+			repeated, err = q, fmt.Errorf("%w: shown recently", errInvalidQuote)
+		}
 		if err == nil {
 			return q, nil
 		}
 		if errors.Is(err, errInvalidQuote) {
-			time.Sleep(time.Millisecond * 200)
+			select {
+			case <-ctx.Done():
+				return quote{}, ctx.Err()
+			case <-time.After(time.Millisecond * 200):
+			}
 			continue
 		}
 		return quote{}, err
 	}
+	if repeated.Text != "" {
+		return repeated, nil
+	}
 	return quote{}, fmt.Errorf("failed to fetch quote after %d retries: %w", maxRetries, err)
 }
 
-func fetchQuote() (quote, error) {
+// defaultQuoteHistory is the number of recent quotes that are not shown
+// again if none is configured.
+const defaultQuoteHistory = 7
+
+// quoteHash identifies the text of a quote in the state file.
+func quoteHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// fetchNewQuote fetches a quote that is not one of the last ones in the
+// state file and adds it to them. A state file that can't be read or
+// written only allows repeats.
+func fetchNewQuote(ctx context.Context, cfg config, client *http.Client) (quote, error) {
+	provider, err := cfg.QuoteProvider(client)
+	if err != nil {
+		return quote{}, err
+	}
+
+	path := cfg.StateFile()
+	state, err := loadDaemonState(path)
+	if err != nil {
+		slog.Warn("failed to read recent quotes", "error", err)
+	}
+
+	q, err := fetchQuoteRetry(ctx, provider, 10, cfg.QuoteTimeout(), state.RecentQuotes)
+	if err != nil {
+		return quote{}, err
+	}
+
+	history := cfg.QuoteHistory()
+	if history == 0 {
+		return q, nil
+	}
+
+	err = updateDaemonState(path, func(state *daemonState) {
+		state.RecentQuotes = addRecentQuote(state.RecentQuotes, q, history)
+	})
+	if err != nil {
+		slog.Warn("failed to save recent quotes", "error", err)
+	}
+
+	return q, nil
+}
+
+// addRecentQuote adds the hash of q to the hashes of the recent quotes and
+// keeps the last history of them. An accepted repeat moves to the end.
+func addRecentQuote(recent []string, q quote, history int) []string {
+	hash := quoteHash(q.Text)
+	recent = slices.DeleteFunc(recent, func(h string) bool { return h == hash })
+	recent = append(recent, hash)
+	return recent[max(len(recent)-history, 0):]
+}
+
+// ZitatServiceProvider fetches random quotes of some categories from
+// zitat-service.de in English and German.
+type ZitatServiceProvider struct {
+	Client *http.Client
+}
+
+// NewZitatServiceProvider creates a zitat-service.de provider.
+func NewZitatServiceProvider(client *http.Client) *ZitatServiceProvider {
+	return &ZitatServiceProvider{Client: client}
+}
+
+// FetchQuote fetches a quote of a random category.
+func (p *ZitatServiceProvider) FetchQuote(ctx context.Context) (quote, error) {
 	categoryId := categoryIds[rand.Intn(len(categoryIds))]
 
 	language := "en"
@@ -75,28 +178,82 @@ func fetchQuote() (quote, error) {
 		language = languages[rand.Intn(len(languages))]
 	}
 
-	resp, err := http.Get(fmt.Sprintf(quoteEndpoint+"/v1/quote?language=%s&categoryId=%d", language, categoryId))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(quoteEndpoint+"/v1/quote?language=%s&categoryId=%d", language, categoryId), nil)
 	if err != nil {
-		return quote{}, fmt.Errorf("%w: %w", errInvalidQuote, err)
+		return quote{}, err
+	}
+
+	var response quoteResponse
+	if err = getQuoteJSON(p.Client, req, &response); err != nil {
+		return quote{}, err
+	}
+
+	return newFetchedQuote(response.Quote, response.Author, true)
+}
+
+// getQuoteJSON sends the request of a quote API and decodes its response
+// into v. All errors wrap errInvalidQuote, so another quote is tried.
+func getQuoteJSON(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidQuote, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return quote{}, fmt.Errorf("invalid status code: %w: %w", errInvalidQuote, err)
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("%w: unexpected status %s: %q", errInvalidQuote, resp.Status, strings.TrimSpace(string(body)))
 	}
 
-	var response quoteResponse
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return quote{}, fmt.Errorf("decoing failed: %w: %w", errInvalidQuote, err)
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: decoding failed: %w", errInvalidQuote, err)
+	}
+
+	return nil
+}
+
+// maxQuoteLength is the length of the longest quote that fits into the footer.
+const maxQuoteLength = 280
+
+// newFetchedQuote cleans up the text and the author of a fetched quote.
+// Empty and long quotes are invalid, and so are quotes without an author if
+// it is required.
+func newFetchedQuote(text, author string, authorRequired bool) (quote, error) {
+	text, author = cleanQuoteText(text), sanitizeQuoteText(author)
+	if text == "" || (author == "" && authorRequired) {
+		return quote{}, fmt.Errorf("%w: empty text or author", errInvalidQuote)
 	}
 
 	// Skip long quotes,
-	if len(response.Quote) > 280 {
-		return quote{}, fmt.Errorf("quote too long: %w", errInvalidQuote)
+	if len(text) > maxQuoteLength {
+		return quote{}, fmt.Errorf("%w: quote too long", errInvalidQuote)
 	}
 
 	return quote{
-		Text:   response.Quote,
-		Author: response.Author,
+		Text:   text,
+		Author: author,
 	}, nil
 }
+
+// quotationMarks are removed from the start and the end of a quote, the
+// footer doesn't show them.
+const quotationMarks = `"„“”«»‹›`
+
+// cleanQuoteText collapses the whitespace of a quote and removes its
+// surrounding quotation marks.
+func cleanQuoteText(text string) string {
+	text = sanitizeQuoteText(text)
+	return strings.TrimSpace(strings.Trim(text, quotationMarks))
+}
+
+// sanitizeQuoteText replaces control characters, e.g. of terminal colors,
+// with spaces and collapses the whitespace.
+func sanitizeQuoteText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeQuoteProvider returns its quotes in order and then errors.
+type fakeQuoteProvider struct {
+	quotes []quote
+	calls  int
+}
+
+// FetchQuote returns the next quote.
+func (p *fakeQuoteProvider) FetchQuote(ctx context.Context) (quote, error) {
+	if p.calls >= len(p.quotes) {
+		return quote{}, fmt.Errorf("%w: no more quotes", errInvalidQuote)
+	}
+	p.calls++
+	return p.quotes[p.calls-1], nil
+}
+
+func TestFetchQuoteRetryRecent(t *testing.T) {
+	seen := quote{Text: "Seen", Author: "A"}
+	fresh := quote{Text: "Fresh", Author: "B"}
+	recent := []string{quoteHash(seen.Text)}
+
+	tests := []struct {
+		name       string
+		quotes     []quote
+		maxRetries int
+		want       quote
+		calls      int
+	}{
+		{"fresh quote", []quote{fresh}, 3, fresh, 1},
+		{"repeat rejected", []quote{seen, seen, fresh}, 3, fresh, 3},
+		{"repeat accepted after the retries", []quote{seen, seen, seen, fresh}, 3, seen, 3},
+	}
+
+	for _, tt := range tests {
+		provider := &fakeQuoteProvider{quotes: tt.quotes}
+		got, err := fetchQuoteRetry(context.Background(), provider, tt.maxRetries, time.Second, recent)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want || provider.calls != tt.calls {
+			t.Errorf("%s: got %q after %d calls, want %q after %d", tt.name, got.Text, provider.calls, tt.want.Text, tt.calls)
+		}
+	}
+
+	// Without any quote, the last error is returned.
+	if _, err := fetchQuoteRetry(context.Background(), &fakeQuoteProvider{}, 2, time.Second, recent); err == nil {
+		t.Error("fetchQuoteRetry without quotes succeeded")
+	}
+}
+
+func TestAddRecentQuote(t *testing.T) {
+	var recent []string
+	for _, text := range []string{"a", "b", "c", "a", "d"} {
+		recent = addRecentQuote(recent, quote{Text: text}, 3)
+	}
+
+	want := []string{quoteHash("c"), quoteHash("a"), quoteHash("d")}
+	if !slices.Equal(recent, want) {
+		t.Errorf("recent = %v, want %v", recent, want)
+	}
+}
+
+func TestUpdateDaemonState(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	// Concurrent updates of the quotes and the deep clean are all kept.
+	cleaned := time.Date(2025, time.March, 14, 23, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := updateDaemonState(path, func(state *daemonState) {
+				state.RecentQuotes = addRecentQuote(state.RecentQuotes, quote{Text: fmt.Sprint(i)}, 100)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := updateDaemonState(path, func(state *daemonState) { state.LastDeepClean = cleaned }); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+
+	state, err := loadDaemonState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.RecentQuotes) != 20 || !state.LastDeepClean.Equal(cleaned) {
+		t.Errorf("state has %d quotes and deep clean %v, want 20 and %v", len(state.RecentQuotes), state.LastDeepClean, cleaned)
+	}
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d files, want only the state", len(entries))
+	}
+
+	// A broken state is not overwritten.
+	if err = os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = updateDaemonState(path, func(*daemonState) {}); err == nil {
+		t.Error("updating a broken state succeeded")
+	}
+}
+
+// useQuoteEndpoint points the zitat-service.de provider at a server with
+// handler for the rest of the test.
+func useQuoteEndpoint(t *testing.T, handler http.HandlerFunc) *http.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	endpoint := quoteEndpoint
+	quoteEndpoint = srv.URL
+	t.Cleanup(func() { quoteEndpoint = endpoint })
+
+	return srv.Client()
+}
+
+func TestZitatServiceProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    quote
+		wantErr string
+	}{
+		{
+			name:   "quote",
+			status: http.StatusOK,
+			body:   `{"quote": "Carpe diem.", "authorName": "Horaz"}`,
+			want:   quote{Text: "Carpe diem.", Author: "Horaz"},
+		},
+		{
+			name:   "quotation marks",
+			status: http.StatusOK,
+			body:   `{"quote": "„Carpe   diem.“", "authorName": " Horaz "}`,
+			want:   quote{Text: "Carpe diem.", Author: "Horaz"},
+		},
+		{
+			name:    "server error",
+			status:  http.StatusInternalServerError,
+			body:    "database is down",
+			wantErr: `unexpected status 500 Internal Server Error: "database is down"`,
+		},
+		{
+			name:    "long error body",
+			status:  http.StatusBadGateway,
+			body:    strings.Repeat("x", 1000),
+			wantErr: `"` + strings.Repeat("x", 200) + `"`,
+		},
+		{
+			name:    "malformed JSON",
+			status:  http.StatusOK,
+			body:    `{"quote": "Carpe`,
+			wantErr: "decoding failed",
+		},
+		{
+			name:    "empty quote",
+			status:  http.StatusOK,
+			body:    `{"quote": " ", "authorName": "Horaz"}`,
+			wantErr: "empty text or author",
+		},
+		{
+			name:    "only quotation marks",
+			status:  http.StatusOK,
+			body:    `{"quote": "„“", "authorName": "Horaz"}`,
+			wantErr: "empty text or author",
+		},
+		{
+			name:    "empty author",
+			status:  http.StatusOK,
+			body:    `{"quote": "Carpe diem.", "authorName": ""}`,
+			wantErr: "empty text or author",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := useQuoteEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/quote" || r.URL.Query().Get("categoryId") == "" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			got, err := NewZitatServiceProvider(client).FetchQuote(context.Background())
+			if tt.wantErr != "" {
+				// Every error is retried with another quote.
+				if !errors.Is(err, errInvalidQuote) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want an invalid quote with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("quote = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFetchedQuote(t *testing.T) {
+	tests := []struct {
+		text, author   string
+		authorRequired bool
+		want           quote
+		wantErr        bool
+	}{
+		{text: `"Quoted"`, author: "A", want: quote{Text: "Quoted", Author: "A"}},
+		{text: "«Guillemets»", author: "A", want: quote{Text: "Guillemets", Author: "A"}},
+		{text: `He said "no".`, author: "A", want: quote{Text: `He said "no".`, Author: "A"}},
+		{text: " \"  Spaced \" ", author: "A", want: quote{Text: "Spaced", Author: "A"}},
+		{text: "Anonymous", want: quote{Text: "Anonymous"}},
+		{text: "Anonymous", authorRequired: true, wantErr: true},
+		{text: "", author: "A", wantErr: true},
+		{text: strings.Repeat("a", maxQuoteLength), author: "A", want: quote{Text: strings.Repeat("a", maxQuoteLength), Author: "A"}},
+		{text: strings.Repeat("a", maxQuoteLength+1), author: "A", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := newFetchedQuote(tt.text, tt.author, tt.authorRequired)
+		if tt.wantErr {
+			if !errors.Is(err, errInvalidQuote) {
+				t.Errorf("newFetchedQuote(%q, %q) err = %v, want an invalid quote", tt.text, tt.author, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("newFetchedQuote(%q, %q) = %+v, %v, want %+v", tt.text, tt.author, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// quotableEndpoint is the base URL of the quotable.io API.
+var quotableEndpoint = "https://api.quotable.io"
+
+// QuotableProvider fetches random quotes from quotable.io.
+type QuotableProvider struct {
+	Client *http.Client
+}
+
+// NewQuotableProvider creates a quotable.io provider.
+func NewQuotableProvider(client *http.Client) *QuotableProvider {
+	return &QuotableProvider{Client: client}
+}
+
+// FetchQuote fetches a random quote that fits into the footer.
+func (p *QuotableProvider) FetchQuote(ctx context.Context) (quote, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/random?maxLength=%d", quotableEndpoint, maxQuoteLength), nil)
+	if err != nil {
+		return quote{}, err
+	}
+
+	var response struct {
+		Content string `json:"content"`
+		Author  string `json:"author"`
+	}
+	if err = getQuoteJSON(p.Client, req, &response); err != nil {
+		return quote{}, err
+	}
+
+	return newFetchedQuote(response.Content, response.Author, true)
+}
+
+// FileQuoteProvider picks a random quote from a local file. JSON files
+// contain an array of {"text": "...", "author": "..."} objects, CSV files
+// a text and an optional author per row.
+type FileQuoteProvider struct {
+	Path string
+}
+
+// NewFileQuoteProvider creates a provider of the quotes in the file at path.
+func NewFileQuoteProvider(path string) *FileQuoteProvider {
+	return &FileQuoteProvider{Path: path}
+}
+
+// FetchQuote reads the file again on every call, so it can be edited
+// while the daemon runs.
+func (p *FileQuoteProvider) FetchQuote(ctx context.Context) (quote, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return quote{}, fmt.Errorf("failed to read quotes: %w", err)
+	}
+
+	quotes, err := parseQuoteFile(data, filepath.Ext(p.Path))
+	if err != nil {
+		return quote{}, fmt.Errorf("failed to parse quotes %s: %w", p.Path, err)
+	}
+	if len(quotes) == 0 {
+		return quote{}, fmt.Errorf("no quotes in %s", p.Path)
+	}
+
+	q := quotes[rand.Intn(len(quotes))]
+	return newFetchedQuote(q.Text, q.Author, false)
+}
+
+// parseQuoteFile parses a JSON file, or a CSV file if ext is ".csv". A CSV
+// header row of "text" and "author" is skipped.
+func parseQuoteFile(data []byte, ext string) ([]quote, error) {
+	if !strings.EqualFold(ext, ".csv") {
+		var quotes []quote
+		err := json.Unmarshal(data, &quotes)
+		return quotes, err
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var quotes []quote
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "text") {
+			continue
+		}
+		q := quote{Text: record[0]}
+		if len(record) > 1 {
+			q.Author = record[1]
+		}
+		quotes = append(quotes, q)
+	}
+
+	return quotes, nil
+}
+
+// ExecQuoteProvider runs a command and shows its output as the quote,
+// e.g. fortune. The author may follow the text after an em dash, e.g.
+// "Stay hungry. — Steve Jobs". The command is killed when the timeout of
+// the quote request ends.
+type ExecQuoteProvider struct {
+	// Command is the program and its arguments, it is not run by a shell
+	Command []string
+}
+
+// NewExecQuoteProvider creates a provider running the command.
+func NewExecQuoteProvider(command []string) *ExecQuoteProvider {
+	return &ExecQuoteProvider{Command: command}
+}
+
+// execQuoteWaitDelay is how long the output of a killed quote command is
+// read before it is closed.
+const execQuoteWaitDelay = time.Second
+
+// ansiEscape matches the color and cursor sequences of terminal programs.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// FetchQuote runs the command. A failing command is not retried.
+func (p *ExecQuoteProvider) FetchQuote(ctx context.Context) (quote, error) {
+	if len(p.Command) == 0 {
+		return quote{}, errors.New("quote command is not set in the config")
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+	cmd.Stderr = &stderr
+	// Children of a killed shell may keep the output open.
+	cmd.WaitDelay = execQuoteWaitDelay
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return quote{}, fmt.Errorf("quote command timed out: %w", ctx.Err())
+	}
+	if err != nil {
+		return quote{}, fmt.Errorf("quote command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	text, author := splitQuoteAuthor(ansiEscape.ReplaceAllString(string(out), ""))
+	return newFetchedQuote(text, author, false)
+}
+
+// splitQuoteAuthor splits the output of a quote command at the last em
+// dash into the text and the author.
+func splitQuoteAuthor(out string) (string, string) {
+	i := strings.LastIndex(out, "—")
+	if i < 0 {
+		return out, ""
+	}
+	return out[:i], out[i+len("—"):]
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuotableProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/random" || r.URL.Query().Get("maxLength") == "" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"_id": "x", "content": "“Stay hungry.”", "author": "Steve Jobs", "tags": []}`))
+	}))
+	defer srv.Close()
+
+	endpoint := quotableEndpoint
+	quotableEndpoint = srv.URL
+	defer func() { quotableEndpoint = endpoint }()
+
+	got, err := NewQuotableProvider(srv.Client()).FetchQuote(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (quote{Text: "Stay hungry.", Author: "Steve Jobs"}); got != want {
+		t.Errorf("quote = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseQuoteFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		ext     string
+		data    string
+		want    []quote
+		wantErr bool
+	}{
+		{
+			name: "JSON",
+			ext:  ".json",
+			data: `[{"text": "One", "author": "A"}, {"text": "Two"}]`,
+			want: []quote{{Text: "One", Author: "A"}, {Text: "Two"}},
+		},
+		{name: "empty JSON", ext: ".json", data: `[]`},
+		{name: "malformed JSON", ext: ".json", data: `[{"text": "One"`, wantErr: true},
+		{name: "JSON object", ext: ".json", data: `{"text": "One"}`, wantErr: true},
+		{
+			name: "CSV with header",
+			ext:  ".csv",
+			data: "text,author\nOne,A\n\"Two, quoted\",B\n",
+			want: []quote{{Text: "One", Author: "A"}, {Text: "Two, quoted", Author: "B"}},
+		},
+		{
+			name: "CSV without author",
+			ext:  ".CSV",
+			data: "One\nTwo,B\n",
+			want: []quote{{Text: "One"}, {Text: "Two", Author: "B"}},
+		},
+		{name: "malformed CSV", ext: ".csv", data: "\"One,A\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseQuoteFile([]byte(tt.data), tt.ext)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: quote %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestFileQuoteProvider(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "quotes.csv")
+	if err := os.WriteFile(path, []byte("text,author\n\"  „Only one“ \",\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewFileQuoteProvider(path).FetchQuote(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Quotes of a file don't need an author.
+	if want := (quote{Text: "Only one"}); got != want {
+		t.Errorf("quote = %+v, want %+v", got, want)
+	}
+
+	empty := filepath.Join(dir, "empty.json")
+	if err = os.WriteFile(empty, []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{empty, filepath.Join(dir, "missing.json")} {
+		if _, err = NewFileQuoteProvider(path).FetchQuote(context.Background()); err == nil {
+			t.Errorf("%s: no error", filepath.Base(path))
+		}
+	}
+}
+
+// requireShell skips the test if there is no sh to run the commands.
+func requireShell(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+}
+
+func TestExecQuoteProvider(t *testing.T) {
+	requireShell(t)
+
+	tests := []struct {
+		name    string
+		script  string
+		want    quote
+		wantErr string
+	}{
+		{
+			name:   "text and author",
+			script: `printf 'Stay hungry.\n  — Steve Jobs\n'`,
+			want:   quote{Text: "Stay hungry.", Author: "Steve Jobs"},
+		},
+		{
+			name:   "terminal colors",
+			script: `printf '\033[1;32mColored\033[0m\n'`,
+			want:   quote{Text: "Colored"},
+		},
+		{
+			name:    "failure",
+			script:  `echo broken >&2; exit 3`,
+			wantErr: "quote command failed: exit status 3: broken",
+		},
+		{
+			name:    "no output",
+			script:  `true`,
+			wantErr: "empty text or author",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := NewExecQuoteProvider([]string{"sh", "-c", tt.script}).FetchQuote(context.Background())
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: quote = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := NewExecQuoteProvider(nil).FetchQuote(context.Background()); err == nil {
+		t.Error("running no command succeeded")
+	}
+}
+
+func TestExecQuoteProviderTimeout(t *testing.T) {
+	requireShell(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The sleep keeps the output open after the shell is killed.
+	start := time.Now()
+	_, err := NewExecQuoteProvider([]string{"sh", "-c", "sleep 10; echo late"}).FetchQuote(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > execQuoteWaitDelay+2*time.Second {
+		t.Errorf("the command was stopped after %v", elapsed)
+	}
+}
+
+func TestSanitizeQuoteText(t *testing.T) {
+	tests := []struct {
+		in, text, author string
+	}{
+		{"Plain — Author", "Plain", "Author"},
+		{"Tabs\tand\r\nlines\n— Someone\n", "Tabs and lines", "Someone"},
+		{"Bell\a and\x00null — Me\x7f", "Bell and null", "Me"},
+		{"No author", "No author", ""},
+		{"A — dash — in the text — Author", "A — dash — in the text", "Author"},
+		{"\u0085Next\u0085line — X", "Next line", "X"},
+	}
+
+	for _, tt := range tests {
+		text, author := splitQuoteAuthor(tt.in)
+		q, err := newFetchedQuote(text, author, false)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if q.Text != tt.text || q.Author != tt.author {
+			t.Errorf("%q = %q by %q, want %q by %q", tt.in, q.Text, q.Author, tt.text, tt.author)
+		}
+	}
+
+	if got := sanitizeQuoteText(" a\x1b\x00b   "); got != "a b" {
+		t.Errorf("sanitizeQuoteText = %q, want %q", got, "a b")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Layout of the rain chart.
+const (
+	// rainChartHours is the number of hours shown by the rain chart
+	rainChartHours = 12
+	// rainChartTickHours is the distance between two hour labels
+	rainChartTickHours = 3
+	// rainChartAxisWidth is the space left of the chart for the percentages
+	rainChartAxisWidth = 36.0
+	// rainChartLabelHeight is the space below the chart for the hours
+	rainChartLabelHeight = 18.0
+)
+
+// renderRainChart draws the precipitation probability of the forecast as an
+// area chart into rect. The chart always has rainChartHours slots on a scale
+// from 0 to 100%, so a shorter forecast leaves the remaining hours empty.
+// Hours without a probability interrupt the area. If the hours cross
+// midnight, a divider with the weekday separates them from the next day.
+func renderRainChart(dc *dashboardCanvas, rect image.Rectangle, forecast WeatherForecast, weekdays [7]string) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return fmt.Errorf("failed to set rain chart font: %w", err)
+	}
+
+	left := float64(rect.Min.X) + rainChartAxisWidth
+	right := float64(rect.Max.X)
+	top := float64(rect.Min.Y)
+	bottom := float64(rect.Max.Y) - rainChartLabelHeight
+
+	step := (right - left) / (rainChartHours - 1)
+	x := func(hour int) float64 { return left + float64(hour)*step }
+	y := func(probability float64) float64 {
+		return bottom - min(max(probability, 0), 100)/100*(bottom-top)
+	}
+
+	// Scale and gridlines
+	dc.SetColor(color.Black)
+	for _, probability := range []float64{0, 50, 100} {
+		dc.DrawStringAnchored(fmt.Sprintf("%.0f%%", probability), left-6, y(probability), 1, 0.35)
+		dc.DrawLine(left, y(probability), right, y(probability))
+	}
+	dc.SetLineWidth(1)
+	dc.SetDash(2, 3)
+	dc.Stroke()
+	dc.SetDash()
+
+	// Area, one polygon per run of hours with a probability
+	hours := forecast[:min(len(forecast), rainChartHours)]
+
+	dc.SetColor(ColorBlue)
+	for start := 0; start < len(hours); start++ {
+		if hours[start].PrecipitationProbability == nil {
+			continue
+		}
+
+		end := start
+		for end+1 < len(hours) && hours[end+1].PrecipitationProbability != nil {
+			end++
+		}
+
+		if start == end {
+			// A single hour has no width, draw it as a bar.
+			dc.DrawRectangle(x(start)-step/4, y(*hours[start].PrecipitationProbability), step/2, bottom-y(*hours[start].PrecipitationProbability))
+			dc.Fill()
+			continue
+		}
+
+		dc.MoveTo(x(start), bottom)
+		for hour := start; hour <= end; hour++ {
+			dc.LineTo(x(hour), y(*hours[hour].PrecipitationProbability))
+		}
+		dc.LineTo(x(end), bottom)
+		dc.ClosePath()
+		dc.Fill()
+
+		start = end
+	}
+
+	// Hour ticks
+	dc.SetColor(color.Black)
+	dc.SetLineWidth(1)
+	dc.DrawLine(left, bottom, right, bottom)
+	dc.Stroke()
+
+	for hour := 0; hour < len(hours); hour += rainChartTickHours {
+		dc.DrawLine(x(hour), bottom, x(hour), bottom+4)
+		dc.Stroke()
+		dc.DrawStringAnchored(hours[hour].Label, x(hour), bottom+rainChartLabelHeight/2+2, 0.5, 0.5)
+	}
+
+	// Day divider, halfway between the last hour of today and the first of tomorrow
+	if next := nextDayIndex(hours); next > 0 {
+		divider := x(next) - step/2
+		dc.DrawLine(divider, top, divider, bottom)
+		dc.SetDash(1, 2)
+		dc.Stroke()
+		dc.SetDash()
+		dc.DrawStringAnchored(weekdays[hours[next].Timestamp.Weekday()], divider+3, top, 0, 1)
+	}
+
+	return nil
+}
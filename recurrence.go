@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arran4/golang-ical"
+	"github.com/teambition/rrule-go"
+)
+
+// exdateTolerance is the maximum difference between an occurrence and an
+// EXDATE entry for the occurrence to be considered cancelled.
+const exdateTolerance = time.Second
+
+// eventOccurrences returns the start times of the event between from and until.
+// Recurring events are expanded using their RRULE, skipping the occurrences listed
+// in EXDATE or in excluded (occurrences that were moved to a separate event).
+func eventOccurrences(event *ics.VEvent, start, from, until time.Time, excluded []time.Time, location *time.Location) ([]time.Time, error) {
+	rule := event.GetProperty(ics.ComponentPropertyRrule)
+	if rule == nil {
+		if start.Before(from) || start.After(until) {
+			return nil, nil
+		}
+		return []time.Time{start}, nil
+	}
+
+	// Expand in the zone of the event so that DST changes are handled correctly.
+	opt, err := rrule.StrToROptionInLocation(rule.Value, start.Location())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RRULE %q: %w", rule.Value, err)
+	}
+	opt.Dtstart = start
+
+	r, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RRULE %q: %w", rule.Value, err)
+	}
+
+	exdates, err := exceptionDates(event, location)
+	if err != nil {
+		return nil, err
+	}
+	exdates = append(exdates, excluded...)
+
+	var occurrences []time.Time
+	for _, occurrence := range r.Between(from, until, true) {
+		if !matchesAny(occurrence, exdates) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	return occurrences, nil
+}
+
+// exceptionDates returns all EXDATE entries of the event. A property may
+// hold several comma-separated dates and a TZID parameter.
+func exceptionDates(event *ics.VEvent, location *time.Location) ([]time.Time, error) {
+	var exdates []time.Time
+	for _, prop := range event.GetProperties(ics.ComponentPropertyExdate) {
+		for _, value := range strings.Split(prop.Value, ",") {
+			t, err := parseICalTime(strings.TrimSpace(value), prop.ICalParameters, location)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse EXDATE: %w", err)
+			}
+			exdates = append(exdates, t)
+		}
+	}
+	return exdates, nil
+}
+
+// recurrenceID returns the RECURRENCE-ID of an event that overrides a
+// single occurrence of a recurring event.
+func recurrenceID(event *ics.VEvent, location *time.Location) (time.Time, bool) {
+	prop := event.GetProperty(ics.ComponentPropertyRecurrenceId)
+	if prop == nil {
+		return time.Time{}, false
+	}
+
+	t, err := parseICalTime(prop.Value, prop.ICalParameters, location)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// matchesAny reports whether t is within exdateTolerance of one of times.
+func matchesAny(t time.Time, times []time.Time) bool {
+	for _, other := range times {
+		if diff := t.Sub(other); diff <= exdateTolerance && diff >= -exdateTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// parseICalTime parses an iCal date or date-time value. Values ending in Z
+// are UTC, values with a TZID parameter are parsed in that zone (or UTC if
+// the zone is unknown, e.g., Windows zone names) and floating values in location.
+func parseICalTime(value string, params map[string][]string, location *time.Location) (time.Time, error) {
+	loc := location
+	if tzid := params[string(ics.ParameterTzid)]; len(tzid) > 0 {
+		var err error
+		loc, err = time.LoadLocation(strings.Trim(tzid[0], `"`))
+		if err != nil {
+			loc = time.UTC
+		}
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		value = strings.TrimSuffix(value, "Z")
+		loc = time.UTC
+	}
+
+	for _, layout := range []string{"20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unsupported time value: %s", value)
+}
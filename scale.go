@@ -0,0 +1,66 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// scaledSize returns the target size of an image of size src. If width or
+// height is 0, it is calculated from the other one preserving the aspect ratio.
+func scaledSize(src image.Rectangle, width, height int) (int, int) {
+	if width == 0 && src.Dy() > 0 {
+		width = max(1, (src.Dx()*height+src.Dy()/2)/src.Dy())
+	}
+	if height == 0 && src.Dx() > 0 {
+		height = max(1, (src.Dy()*width+src.Dx()/2)/src.Dx())
+	}
+	return width, height
+}
+
+// scaleImage scales img to width and height (see scaledSize) with the given
+// interpolator. Use draw.NearestNeighbor for flat palette graphics and
+// draw.CatmullRom for photos.
+func scaleImage(img image.Image, width, height int, interpolator draw.Interpolator) *image.RGBA {
+	width, height = scaledSize(img.Bounds(), width, height)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	interpolator.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+
+	return dst
+}
+
+// resizeForPalette scales img smoothly and snaps every pixel back to the
+// palette afterwards. Semi-transparent edges become either fully transparent
+// or fully opaque, so no gray fringes remain that the panel's quantizer
+// would turn into speckles of random colors.
+func resizeForPalette(img image.Image, width, height int, palette color.Palette) *image.RGBA {
+	dst := scaleImage(img, width, height, draw.CatmullRom)
+	snapToPalette(dst, palette)
+
+	return dst
+}
+
+// snapToPalette replaces every pixel of dst with the closest palette color
+// in place. Pixels with less than half opacity become fully transparent.
+func snapToPalette(dst *image.RGBA, palette color.Palette) {
+	for i := 0; i < len(dst.Pix); i += 4 {
+		if dst.Pix[i+3] < 0x80 {
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = 0, 0, 0, 0
+			continue
+		}
+
+		// Un-premultiply before looking up the closest palette color.
+		a := uint32(dst.Pix[i+3])
+		c := color.RGBA{
+			R: uint8(uint32(dst.Pix[i]) * 0xff / a),
+			G: uint8(uint32(dst.Pix[i+1]) * 0xff / a),
+			B: uint8(uint32(dst.Pix[i+2]) * 0xff / a),
+			A: 0xff,
+		}
+
+		r, g, b, _ := palette.Convert(c).RGBA()
+		dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), 0xff
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+// blackWhiteImage returns a white image with a black ring and a black
+// checkerboard of 3 pixel squares, whose edges scaling blurs.
+func blackWhiteImage(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	center := size / 2
+	for y := range size {
+		for x := range size {
+			dx, dy := x-center, y-center
+			distance := dx*dx + dy*dy
+			ring := distance > (size/4)*(size/4) && distance < (size/3)*(size/3)
+			checker := x < size/4 && y < size/4 && (x/3+y/3)%2 == 0
+
+			c := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			if ring || checker {
+				c = color.RGBA{A: 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestScaleBlackWhiteStaysTwoColored(t *testing.T) {
+	src := blackWhiteImage(90)
+	scalers := map[string]func(width, height int) *image.RGBA{
+		"nearest neighbor": func(width, height int) *image.RGBA {
+			return scaleImage(src, width, height, draw.NearestNeighbor)
+		},
+		"smooth": func(width, height int) *image.RGBA {
+			return resizeForPalette(src, width, height, ColorPalette)
+		},
+	}
+
+	for name, scale := range scalers {
+		for _, size := range []int{31, 64, 90, 173} {
+			dst := scale(size, 0)
+			if dst.Bounds().Dx() != size || dst.Bounds().Dy() != size {
+				t.Errorf("%s to %d: got size %v", name, size, dst.Bounds().Size())
+				continue
+			}
+
+			colors := make(map[color.RGBA]int)
+			for y := range size {
+				for x := range size {
+					colors[dst.RGBAAt(x, y)]++
+				}
+			}
+			black, white := colors[color.RGBA{A: 0xff}], colors[color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}]
+			if len(colors) != 2 || black == 0 || white == 0 {
+				t.Errorf("%s to %d: got colors %v, want only black and white", name, size, colors)
+			}
+		}
+	}
+}
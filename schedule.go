@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// quietHours is a daily window, e.g. "23:00-06:00", in which the display is
+// not refreshed. Windows may cross midnight.
+type quietHours struct {
+	// start and end are minutes after midnight
+	start, end int
+	set        bool
+}
+
+// UnmarshalText parses a window like "23:00-06:00".
+func (q *quietHours) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*q = quietHours{}
+		return nil
+	}
+
+	var startHour, startMinute, endHour, endMinute int
+	_, err := fmt.Sscanf(string(text), "%d:%d-%d:%d", &startHour, &startMinute, &endHour, &endMinute)
+	if err != nil || !validClock(startHour, startMinute) || !validClock(endHour, endMinute) {
+		return fmt.Errorf("invalid quiet hours: %s (expected e.g. 23:00-06:00)", string(text))
+	}
+
+	*q = quietHours{
+		start: startHour*60 + startMinute,
+		end:   endHour*60 + endMinute,
+		set:   true,
+	}
+
+	return nil
+}
+
+// validClock reports whether hour and minute are a time of day.
+func validClock(hour, minute int) bool {
+	return hour >= 0 && hour < 24 && minute >= 0 && minute < 60
+}
+
+// Contains reports whether t is within the window. The wall clock of t's
+// location is used, so DST changes don't shift the window.
+func (q quietHours) Contains(t time.Time) bool {
+	if !q.set || q.start == q.end {
+		return false
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	if q.start < q.end {
+		return minute >= q.start && minute < q.end
+	}
+	// The window crosses midnight.
+	return minute >= q.start || minute < q.end
+}
+
+// End returns the next end of the window after t in t's location.
+func (q quietHours) End(t time.Time) time.Time {
+	end := time.Date(t.Year(), t.Month(), t.Day(), q.end/60, q.end%60, 0, 0, t.Location())
+	if !end.After(t) {
+		end = time.Date(t.Year(), t.Month(), t.Day()+1, q.end/60, q.end%60, 0, 0, t.Location())
+	}
+	return end
+}
+
+// Postpone returns next, or the end of the window if next is within it or
+// the window starts between now and next.
+func (q quietHours) Postpone(now, next time.Time) time.Time {
+	if !q.set || q.start == q.end {
+		return next
+	}
+
+	end := q.End(now)
+	if q.Contains(next) || !next.Before(end) {
+		return end
+	}
+	return next
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursUnmarshal(t *testing.T) {
+	tests := []struct {
+		text       string
+		start, end int
+		set        bool
+		wantErr    bool
+	}{
+		{text: ""},
+		{text: "23:00-06:00", start: 23 * 60, end: 6 * 60, set: true},
+		{text: "00:00-23:59", start: 0, end: 23*60 + 59, set: true},
+		{text: "12:30-13:15", start: 12*60 + 30, end: 13*60 + 15, set: true},
+		{text: "7:05-8:00", start: 7*60 + 5, end: 8 * 60, set: true},
+		{text: "24:00-06:00", wantErr: true},
+		{text: "23:60-06:00", wantErr: true},
+		{text: "23:00-06:-1", wantErr: true},
+		{text: "23:00", wantErr: true},
+		{text: "23-06", wantErr: true},
+		{text: "night", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var q quietHours
+		err := q.UnmarshalText([]byte(tt.text))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("UnmarshalText(%q) = %+v, want an error", tt.text, q)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("UnmarshalText(%q): %v", tt.text, err)
+			continue
+		}
+		if want := (quietHours{start: tt.start, end: tt.end, set: tt.set}); q != want {
+			t.Errorf("UnmarshalText(%q) = %+v, want %+v", tt.text, q, want)
+		}
+	}
+}
+
+// mustQuietHours parses the window text.
+func mustQuietHours(t *testing.T, text string) quietHours {
+	t.Helper()
+	var q quietHours
+	if err := q.UnmarshalText([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestQuietHoursContains(t *testing.T) {
+	berlin := loadBerlin(t)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		window string
+		t      time.Time
+		want   bool
+	}{
+		{"23:00-06:00", at(time.March, 14, 22, 59), false},
+		{"23:00-06:00", at(time.March, 14, 23, 0), true},
+		{"23:00-06:00", at(time.March, 15, 0, 0), true},
+		{"23:00-06:00", at(time.March, 15, 5, 59), true},
+		{"23:00-06:00", at(time.March, 15, 6, 0), false},
+		{"01:00-05:00", at(time.March, 14, 0, 59), false},
+		{"01:00-05:00", at(time.March, 14, 3, 0), true},
+		{"01:00-05:00", at(time.March, 14, 5, 0), false},
+		{"01:00-05:00", at(time.March, 14, 23, 0), false},
+		// An empty window never contains a time.
+		{"06:00-06:00", at(time.March, 14, 6, 0), false},
+		{"", at(time.March, 14, 3, 0), false},
+		// The wall clock counts on the days the clocks change.
+		{"01:00-05:00", at(time.March, 30, 3, 30), true},
+		{"23:00-06:00", at(time.October, 26, 2, 30), true},
+		{"23:00-06:00", time.Date(2025, time.March, 30, 4, 30, 0, 0, time.UTC).In(berlin), false},
+	}
+
+	for _, tt := range tests {
+		if got := mustQuietHours(t, tt.window).Contains(tt.t); got != tt.want {
+			t.Errorf("%q contains %s = %v, want %v", tt.window, tt.t.Format("2006-01-02 15:04 -07:00"), got, tt.want)
+		}
+	}
+}
+
+func TestQuietHoursEnd(t *testing.T) {
+	berlin := loadBerlin(t)
+	// The offsets tell CET from CEST on the days the clocks change.
+	parse := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04 -07:00", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.In(berlin)
+	}
+
+	tests := []struct {
+		name   string
+		window string
+		t      string
+		want   string
+	}{
+		{"before midnight", "23:00-06:00", "2025-03-14 23:30 +01:00", "2025-03-15 06:00 +01:00"},
+		{"after midnight", "23:00-06:00", "2025-03-15 02:00 +01:00", "2025-03-15 06:00 +01:00"},
+		{"at the end", "23:00-06:00", "2025-03-15 06:00 +01:00", "2025-03-16 06:00 +01:00"},
+		{"same day", "01:00-05:00", "2025-03-14 03:00 +01:00", "2025-03-14 05:00 +01:00"},
+		{"end of the month", "23:00-06:00", "2025-03-31 23:30 +02:00", "2025-04-01 06:00 +02:00"},
+		{"end of the year", "23:00-06:00", "2025-12-31 23:30 +01:00", "2026-01-01 06:00 +01:00"},
+		// The night is an hour shorter when the clocks go forward and an
+		// hour longer when they go back.
+		{"clocks go forward", "23:00-06:00", "2025-03-29 23:00 +01:00", "2025-03-30 06:00 +02:00"},
+		{"clocks go back", "23:00-06:00", "2025-10-25 23:00 +02:00", "2025-10-26 06:00 +01:00"},
+		// An end in the skipped hour is normalized to the hour after it.
+		{"end in the skipped hour", "01:00-02:30", "2025-03-30 01:30 +01:00", "2025-03-30 03:30 +02:00"},
+	}
+
+	for _, tt := range tests {
+		got := mustQuietHours(t, tt.window).End(parse(tt.t))
+		if want := parse(tt.want); !got.Equal(want) {
+			t.Errorf("%s: End(%s) = %s, want %s", tt.name, tt.t, got.Format("2006-01-02 15:04 -07:00"), tt.want)
+		}
+	}
+}
+
+func TestQuietHoursPostpone(t *testing.T) {
+	berlin := loadBerlin(t)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, time.March, day, hour, minute, 0, 0, berlin)
+	}
+
+	night := mustQuietHours(t, "23:00-06:00")
+
+	tests := []struct {
+		name      string
+		q         quietHours
+		now, next time.Time
+		want      time.Time
+	}{
+		{"unset", quietHours{}, at(14, 22, 50), at(15, 2, 0), at(15, 2, 0)},
+		{"before the window", night, at(14, 12, 0), at(14, 18, 0), at(14, 18, 0)},
+		{"ends at the start", night, at(14, 22, 0), at(14, 23, 0), at(15, 6, 0)},
+		{"within the window", night, at(14, 22, 50), at(15, 2, 0), at(15, 6, 0)},
+		{"across the window", night, at(14, 20, 0), at(15, 8, 0), at(15, 6, 0)},
+		{"in the window", night, at(15, 1, 0), at(15, 1, 30), at(15, 6, 0)},
+		{"after the window", night, at(15, 6, 0), at(15, 12, 0), at(15, 12, 0)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.q.Postpone(tt.now, tt.next); !got.Equal(tt.want) {
+			t.Errorf("%s: Postpone = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
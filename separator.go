@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/fogleman/gg"
+)
+
+// SeparatorStyle selects how the lines between the sections are drawn.
+type SeparatorStyle string
+
+const (
+	// SeparatorSolid draws a continuous line (default)
+	SeparatorSolid SeparatorStyle = "solid"
+	// SeparatorDotted draws a line of small squares
+	SeparatorDotted SeparatorStyle = "dotted"
+	// SeparatorDashed draws a line of short dashes
+	SeparatorDashed SeparatorStyle = "dashed"
+	// SeparatorNone leaves a gap without a line
+	SeparatorNone SeparatorStyle = "none"
+)
+
+// separatorThickness is the height of the separator lines in pixels.
+const separatorThickness = 2.0
+
+// UnmarshalText validates a separator style from the config file.
+func (s *SeparatorStyle) UnmarshalText(text []byte) error {
+	switch SeparatorStyle(text) {
+	case SeparatorSolid, SeparatorDotted, SeparatorDashed, SeparatorNone:
+		*s = SeparatorStyle(text)
+	case "":
+		*s = SeparatorSolid
+	default:
+		return fmt.Errorf("invalid separator style: %s (expected solid, dotted, dashed or none)", string(text))
+	}
+
+	return nil
+}
+
+// pattern returns the length of a segment and of the gap after it. A gap of
+// 0 draws a solid line, a segment of 0 no line at all.
+func (s SeparatorStyle) pattern() (segment, gap float64) {
+	switch s {
+	case SeparatorDotted:
+		return separatorThickness, separatorThickness * 2
+	case SeparatorDashed:
+		return 8, 4
+	case SeparatorNone:
+		return 0, 0
+	default:
+		return 1, 0
+	}
+}
+
+// drawSeparator draws a horizontal separator of the given width starting at x, y.
+// The segments are spread evenly, so the line ends with a segment on both sides.
+func drawSeparator(dc *gg.Context, style SeparatorStyle, x, y, width float64) {
+	segment, gap := style.pattern()
+	if segment == 0 {
+		return
+	}
+
+	dc.SetColor(color.Black)
+
+	if gap == 0 {
+		dc.DrawRectangle(x, y, width, separatorThickness)
+		dc.Fill()
+		return
+	}
+
+	count := int((width + gap) / (segment + gap))
+	if count < 2 {
+		dc.DrawRectangle(x, y, width, separatorThickness)
+		dc.Fill()
+		return
+	}
+
+	// Stretch the gaps to end exactly at the right edge.
+	gap = (width - float64(count)*segment) / float64(count-1)
+	for i := range count {
+		dc.DrawRectangle(x+float64(i)*(segment+gap), y, segment, separatorThickness)
+	}
+	dc.Fill()
+}
@@ -0,0 +1,95 @@
+//go:build (linux && amd64) || (darwin && amd64)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// EpdSimulator writes every frame to a PNG file instead of a panel and opens
+// it with the desktop's image viewer. The frames are reduced to the panel's
+// palette, so they look like the panel would show them.
+type EpdSimulator struct {
+	path   string
+	log    *slog.Logger
+	opened bool
+}
+
+// newSimulator creates a simulator that writes the frames to path.
+func newSimulator(path string, logger *slog.Logger) (Display, error) {
+	return &EpdSimulator{path: path, log: logger}, nil
+}
+
+// Wake does nothing, the simulator needs no initialization.
+func (s *EpdSimulator) Wake() error {
+	return nil
+}
+
+// Clear does nothing, every frame replaces the file completely.
+func (s *EpdSimulator) Clear(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Display writes the image reduced to the panel's palette. The viewer is
+// only opened for the first frame, most viewers reload changed files.
+func (s *EpdSimulator) Display(ctx context.Context, img image.Image) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f, err := os.Create(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to create simulator image: %w", err)
+	}
+
+	if err = png.Encode(f, quantizeImage(img, ColorPalette)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write simulator image: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("failed to write simulator image: %w", err)
+	}
+	s.log.Info("simulated display update", "file", s.path)
+
+	if !s.opened {
+		s.opened = true
+		if err = openViewer(s.path); err != nil {
+			s.log.Warn("failed to open image viewer", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// DeepClean does nothing, the simulator has no ghosting.
+func (s *EpdSimulator) DeepClean(ctx context.Context) error {
+	s.log.Info("simulated deep clean")
+	return ctx.Err()
+}
+
+// Sleep does nothing.
+func (s *EpdSimulator) Sleep() {}
+
+// openViewer opens the file with the desktop's default application. The
+// viewer is waited for in the background, so it doesn't stay a zombie.
+func openViewer(path string) error {
+	command := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		command = "open"
+	}
+
+	cmd := exec.Command(command, path)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+
+	return nil
+}
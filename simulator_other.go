@@ -0,0 +1,13 @@
+//go:build !((linux && amd64) || (darwin && amd64))
+
+package main
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// newSimulator fails, the simulator is only available on linux/amd64 and darwin/amd64.
+func newSimulator(string, *slog.Logger) (Display, error) {
+	return nil, errors.New("the display simulator is only available on linux/amd64 and darwin/amd64")
+}
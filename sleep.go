@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FitSleepSource reads the last night's sleep from the FIT files that a
+// watch sync (e.g. Gadgetbridge or GarminDB) stores in a directory.
+type FitSleepSource struct {
+	// Dir is the directory with the .fit files
+	Dir string
+}
+
+// NewFitSleepSource creates a sleep source reading the FIT files in dir.
+func NewFitSleepSource(dir string) *FitSleepSource {
+	return &FitSleepSource{Dir: dir}
+}
+
+// maxSleepFiles is the number of the most recent FIT files searched for sleep
+// data. Activity files are synced to the same directory.
+const maxSleepFiles = 10
+
+// FetchSleep reads the sleep of the most recent FIT file with sleep data.
+func (s *FitSleepSource) FetchSleep(ctx context.Context) (SleepSummary, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return SleepSummary{}, fmt.Errorf("failed to read sleep directory: %w", err)
+	}
+
+	type fitFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []fitFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".fit") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fitFile{filepath.Join(s.Dir, entry.Name()), info.ModTime()})
+	}
+	slices.SortFunc(files, func(a, b fitFile) int { return b.modTime.Compare(a.modTime) })
+
+	for _, file := range files[:min(len(files), maxSleepFiles)] {
+		if err := ctx.Err(); err != nil {
+			return SleepSummary{}, err
+		}
+
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return SleepSummary{}, fmt.Errorf("failed to read sleep file: %w", err)
+		}
+		messages, err := parseFIT(data)
+		if err != nil {
+			return SleepSummary{}, fmt.Errorf("failed to parse %s: %w", file.path, err)
+		}
+
+		summary, err := sleepSummaryFrom(messages)
+		if errors.Is(err, errNoSleep) {
+			continue
+		}
+		return summary, err
+	}
+
+	return SleepSummary{}, fmt.Errorf("failed to read sleep: no FIT file with sleep data in %s", s.Dir)
+}
+
+// SleepSummary is the sleep of a night.
+type SleepSummary struct {
+	// Duration is the time asleep, without the awake phases
+	Duration time.Duration
+	// Score is the quality of the sleep from 0 to 100
+	Score int
+	// End is the time of the last sleep stage, usually the wake up
+	End time.Time
+}
+
+// sleepQuality is a band of the sleep score.
+type sleepQuality struct {
+	// Min is the lowest score of the band
+	Min     int
+	LabelDE string
+	LabelEN string
+}
+
+// sleepQualities are the bands of the sleep score, the best first. They
+// match the bands of Garmin's sleep score.
+var sleepQualities = []sleepQuality{
+	{90, "Ausgezeichnet", "Excellent"},
+	{80, "Gut", "Good"},
+	{60, "Mäßig", "Fair"},
+	{0, "Schlecht", "Poor"},
+}
+
+// Quality returns the band of the score.
+func (s SleepSummary) Quality() sleepQuality {
+	for _, quality := range sleepQualities {
+		if s.Score >= quality.Min {
+			return quality
+		}
+	}
+	return sleepQualities[len(sleepQualities)-1]
+}
+
+// Messages and fields of the FIT profile used for the sleep.
+const (
+	fitMesgSleepLevel      = 275
+	fitMesgSleepAssessment = 346
+	// fitFieldTimestamp is the timestamp field of every message
+	fitFieldTimestamp = 253
+	// fitFieldSleepLevel is the stage of a sleep_level message
+	fitFieldSleepLevel = 0
+	// fitFieldSleepScore is the overall_sleep_score of a sleep_assessment message
+	fitFieldSleepScore = 0
+)
+
+// Stages of the sleep_level messages.
+const (
+	fitSleepUnmeasurable = 0
+	fitSleepAwake        = 1
+	fitSleepLight        = 2
+	fitSleepDeep         = 3
+	fitSleepREM          = 4
+)
+
+// errNoSleep is returned by sleepSummaryFrom if there are no sleep stages.
+var errNoSleep = errors.New("no sleep stages in the FIT file")
+
+// sleepSummaryFrom sums up the sleep stages of a FIT file. Every
+// sleep_level message starts a stage that lasts until the next one. The
+// score is the overall_sleep_score of the sleep assessment if the watch
+// recorded one, otherwise it is estimated from the duration (70 points for
+// 8 hours) and the share of deep and REM sleep (30 points for half of it).
+func sleepSummaryFrom(messages []fitMessage) (SleepSummary, error) {
+	var levels []fitMessage
+	score := -1
+	for _, message := range messages {
+		_, hasTimestamp := message.Fields[fitFieldTimestamp]
+		_, hasLevel := message.Fields[fitFieldSleepLevel]
+		switch {
+		case message.Global == fitMesgSleepLevel && hasTimestamp && hasLevel:
+			levels = append(levels, message)
+		case message.Global == fitMesgSleepAssessment:
+			if value, ok := message.Fields[fitFieldSleepScore]; ok {
+				score = int(value)
+			}
+		}
+	}
+	if len(levels) == 0 {
+		return SleepSummary{}, errNoSleep
+	}
+
+	var summary SleepSummary
+	var restful time.Duration
+	for i, level := range levels[:len(levels)-1] {
+		duration := levels[i+1].Time().Sub(level.Time())
+		switch level.Fields[fitFieldSleepLevel] {
+		case fitSleepDeep, fitSleepREM:
+			restful += duration
+			fallthrough
+		case fitSleepLight:
+			summary.Duration += duration
+		}
+	}
+	summary.End = levels[len(levels)-1].Time()
+
+	if score < 0 {
+		durationPoints := min(summary.Duration.Hours()/8, 1) * 70
+		restfulPoints := 0.0
+		if summary.Duration > 0 {
+			restfulPoints = min(restful.Hours()/summary.Duration.Hours()/0.5, 1) * 30
+		}
+		score = int(durationPoints + restfulPoints)
+	}
+	summary.Score = min(score, 100)
+
+	return summary, nil
+}
+
+// fitEpoch is the zero of the FIT timestamps.
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// fitMessage is a data message of a FIT file. Only the integer fields are
+// decoded, and fields with the invalid value (all bits set) are left out.
+type fitMessage struct {
+	// Global is the message number of the FIT profile, e.g. 275 for sleep_level
+	Global uint16
+	// Fields maps the field numbers to their values
+	Fields map[byte]uint64
+}
+
+// Time returns the timestamp of the message.
+func (m fitMessage) Time() time.Time {
+	return fitEpoch.Add(time.Duration(m.Fields[fitFieldTimestamp]) * time.Second)
+}
+
+// fitDefinition is the layout of the data messages of a local message type.
+type fitDefinition struct {
+	global uint16
+	order  binary.ByteOrder
+	fields []fitFieldDefinition
+	// devSize is the size of the developer fields, which are skipped
+	devSize int
+}
+
+// fitFieldDefinition is a field of a definition message.
+type fitFieldDefinition struct {
+	num  byte
+	size byte
+}
+
+// errFITTruncated is returned by parseFIT if a record ends early.
+var errFITTruncated = errors.New("invalid FIT file: truncated record")
+
+// parseFIT decodes the data messages of a FIT file. Data messages with a
+// compressed timestamp header get a timestamp field. The CRC is not checked.
+func parseFIT(data []byte) ([]fitMessage, error) {
+	if len(data) < 12 {
+		return nil, errors.New("invalid FIT file: too short")
+	}
+	headerSize := int(data[0])
+	if headerSize < 12 || len(data) < headerSize || string(data[8:12]) != ".FIT" {
+		return nil, errors.New("invalid FIT file: missing header")
+	}
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	if len(data) < headerSize+dataSize {
+		return nil, errFITTruncated
+	}
+	records := data[headerSize : headerSize+dataSize]
+
+	definitions := make(map[byte]*fitDefinition)
+	var messages []fitMessage
+	var lastTimestamp uint32
+
+	for pos := 0; pos < len(records); {
+		header := records[pos]
+		pos++
+
+		local := header & 0x0F
+		compressed := header&0x80 != 0
+		if compressed {
+			local = header >> 5 & 0x03
+		}
+
+		// Definition message
+		if !compressed && header&0x40 != 0 {
+			if pos+5 > len(records) {
+				return nil, errFITTruncated
+			}
+			definition := &fitDefinition{order: binary.LittleEndian}
+			if records[pos+1] == 1 {
+				definition.order = binary.BigEndian
+			}
+			definition.global = definition.order.Uint16(records[pos+2 : pos+4])
+			count := int(records[pos+4])
+			pos += 5
+
+			if pos+3*count > len(records) {
+				return nil, errFITTruncated
+			}
+			for range count {
+				definition.fields = append(definition.fields, fitFieldDefinition{num: records[pos], size: records[pos+1]})
+				pos += 3
+			}
+
+			if header&0x20 != 0 {
+				if pos >= len(records) {
+					return nil, errFITTruncated
+				}
+				count := int(records[pos])
+				pos++
+				if pos+3*count > len(records) {
+					return nil, errFITTruncated
+				}
+				for range count {
+					definition.devSize += int(records[pos+1])
+					pos += 3
+				}
+			}
+
+			definitions[local] = definition
+			continue
+		}
+
+		// Data message
+		definition, ok := definitions[local]
+		if !ok {
+			return nil, fmt.Errorf("invalid FIT file: data of undefined local message %d", local)
+		}
+
+		message := fitMessage{Global: definition.global, Fields: make(map[byte]uint64)}
+		for _, field := range definition.fields {
+			end := pos + int(field.size)
+			if end > len(records) {
+				return nil, errFITTruncated
+			}
+			if value, ok := fitUint(definition.order, records[pos:end]); ok {
+				message.Fields[field.num] = value
+			}
+			pos = end
+		}
+		pos += definition.devSize
+		if pos > len(records) {
+			return nil, errFITTruncated
+		}
+
+		if timestamp, ok := message.Fields[fitFieldTimestamp]; ok {
+			lastTimestamp = uint32(timestamp)
+		} else if compressed {
+			// The offset are the lowest 5 bits of the timestamp, which
+			// roll over every 32 seconds.
+			offset := uint32(header & 0x1F)
+			timestamp := lastTimestamp&^0x1F | offset
+			if offset < lastTimestamp&0x1F {
+				timestamp += 0x20
+			}
+			lastTimestamp = timestamp
+			message.Fields[fitFieldTimestamp] = uint64(timestamp)
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// fitUint decodes an unsigned integer field. It returns false for other
+// sizes (e.g. strings) and for the invalid value.
+func fitUint(order binary.ByteOrder, b []byte) (uint64, bool) {
+	var value, invalid uint64
+	switch len(b) {
+	case 1:
+		value, invalid = uint64(b[0]), 0xFF
+	case 2:
+		value, invalid = uint64(order.Uint16(b)), 0xFFFF
+	case 4:
+		value, invalid = uint64(order.Uint32(b)), 0xFFFFFFFF
+	case 8:
+		value, invalid = order.Uint64(b), 0xFFFFFFFFFFFFFFFF
+	default:
+		return 0, false
+	}
+	return value, value != invalid
+}
+
+// sleepHeight is the height of the sleep widget below the appointments.
+const sleepHeight = 22
+
+// drawSleep draws a crescent moon with the duration and the quality of the
+// sleep, e.g. "8h 12m  Gut", centered in rect.
+func drawSleep(dc *dashboardCanvas, rect image.Rectangle, sleep SleepSummary, locale Locale) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXS)
+	if err != nil {
+		return err
+	}
+
+	quality := sleep.Quality()
+	label := quality.LabelDE
+	if locale == LocaleEnglish {
+		label = quality.LabelEN
+	}
+	minutes := int(sleep.Duration.Round(time.Minute).Minutes())
+	text := fmt.Sprintf("%dh %02dm  %s", minutes/60, minutes%60, label)
+
+	iconSize := rect.Dy() - 4
+	textW, _ := dc.MeasureString(text)
+	left := rect.Min.X + (rect.Dx()-iconSize-8-int(textW))/2
+	centerY := rect.Min.Y + rect.Dy()/2
+
+	err = addImage(dc, "sleep/moon.png", image.Point{X: left, Y: centerY}, iconSize, iconSize, 0, 0.5, nil)
+	if err != nil {
+		return err
+	}
+
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(text, float64(left+iconSize+8), float64(centerY), 0, 0.35)
+
+	return nil
+}
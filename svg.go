@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log/slog"
+	"math"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+// isSVG reports whether the file at path is an SVG image.
+func isSVG(p string) bool {
+	return strings.EqualFold(path.Ext(p), ".svg")
+}
+
+// rasterizeSVG renders the SVG read from r at exactly width and height.
+// If width or height is 0, it is calculated from the view box preserving
+// the aspect ratio. Vector icons stay sharp at any size, unlike scaled PNGs.
+// Elements using currentColor are drawn black.
+func rasterizeSVG(r io.Reader, width, height int, palette color.Palette) (*image.RGBA, error) {
+	icon, err := oksvg.ReadReplacingCurrentColor(r, "black", oksvg.IgnoreErrorMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse svg: %w", err)
+	}
+
+	viewBox := image.Rect(0, 0, int(math.Ceil(icon.ViewBox.W)), int(math.Ceil(icon.ViewBox.H)))
+	width, height = scaledSize(viewBox, width, height)
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("failed to size svg: invalid view box %vx%v", icon.ViewBox.W, icon.ViewBox.H)
+	}
+
+	icon.SetTarget(0, 0, float64(width), float64(height))
+
+	// oksvg applies stroke widths and dash patterns in pixels, ignoring
+	// the transforms of the path and the target size.
+	targetScale := math.Sqrt(float64(width) / icon.ViewBox.W * float64(height) / icon.ViewBox.H)
+	for i := range icon.SVGPaths {
+		scaleStroke(&icon.SVGPaths[i], pathScale(&icon.SVGPaths[i])*targetScale)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	scanner := rasterx.NewScannerGV(width, height, dst, dst.Bounds())
+	icon.Draw(rasterx.NewDasher(width, height, scanner), 1)
+
+	snapToPalette(dst, palette)
+
+	return dst, nil
+}
+
+// scaleStroke scales the stroke width and dash pattern of p by scale.
+func scaleStroke(p *oksvg.SvgPath, scale float64) {
+	p.LineWidth *= scale
+	p.DashOffset *= scale
+
+	// The dash slice may be shared with other paths of the same group.
+	dash := make([]float64, len(p.Dash))
+	for i, d := range p.Dash {
+		dash[i] = d * scale
+	}
+	p.Dash = dash
+}
+
+// pathTransformIndex returns the index of the transform of an
+// oksvg.SvgPath, which is accumulated from the enclosing groups. oksvg does
+// not export it, so the unexported mAdder.M field is looked up on the first
+// svg render. If a newer oksvg doesn't have it, nil is returned and logged once.
+var pathTransformIndex = sync.OnceValue(func() []int {
+	adder, ok := reflect.TypeOf(oksvg.SvgPath{}).FieldByName("mAdder")
+	if ok {
+		var m reflect.StructField
+		if m, ok = adder.Type.FieldByName("M"); ok && m.Type == reflect.TypeOf(rasterx.Matrix2D{}) {
+			return append(slices.Clone(adder.Index), m.Index...)
+		}
+	}
+
+	slog.Warn("failed to find the path transform of oksvg, stroke widths in transformed svg groups are not scaled")
+	return nil
+})
+
+// pathScale returns the scale factor of the transform of p, 1 if the
+// transform can't be read.
+func pathScale(p *oksvg.SvgPath) float64 {
+	index := pathTransformIndex()
+	if index == nil {
+		return 1
+	}
+
+	m := reflect.ValueOf(p).Elem().FieldByIndex(index)
+	a, b := m.FieldByName("A").Float(), m.FieldByName("B").Float()
+	c, d := m.FieldByName("C").Float(), m.FieldByName("D").Float()
+
+	return math.Sqrt(math.Abs(a*d - b*c))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/srwiley/oksvg"
+)
+
+func TestPathScale(t *testing.T) {
+	const svg = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+<path d="M0 0L10 10" stroke="black"/>
+<g transform="scale(2 2)"><path d="M0 0L5 5" stroke="black"/></g>
+<g transform="scale(2 8)"><path d="M0 0L5 1" stroke="black"/></g>
+</svg>`
+
+	icon, err := oksvg.ReadIconStream(strings.NewReader(svg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []float64{1, 2, 4}
+	if len(icon.SVGPaths) != len(want) {
+		t.Fatalf("got %d paths, want %d", len(icon.SVGPaths), len(want))
+	}
+	for i := range icon.SVGPaths {
+		if got := pathScale(&icon.SVGPaths[i]); got != want[i] {
+			t.Errorf("path %d: scale = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+// opaqueBounds returns the smallest rectangle holding the drawn pixels of img.
+func opaqueBounds(img *image.RGBA) image.Rectangle {
+	var bounds image.Rectangle
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.RGBAAt(x, y).A != 0 {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return bounds
+}
+
+func TestRasterizeSVGSizes(t *testing.T) {
+	const name = "weather/sunny.svg"
+
+	render := func(size int) *image.RGBA {
+		f, err := embeddedIcons().Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		img, err := rasterizeSVG(f, size, size, ColorPalette)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := img.Bounds().Size(); got != image.Pt(size, size) {
+			t.Fatalf("%s at %d pixels has size %v", name, size, got)
+		}
+		return img
+	}
+
+	small, large := opaqueBounds(render(60)), opaqueBounds(render(120))
+	if small.Empty() {
+		t.Fatalf("%s at 60 pixels is empty", name)
+	}
+
+	// The icon scales as a whole, so its drawn area doubles with the size.
+	want := image.Rect(small.Min.X*2, small.Min.Y*2, small.Max.X*2, small.Max.Y*2)
+	near := func(a, b int) bool { return a-b <= 2 && b-a <= 2 }
+	if !near(large.Min.X, want.Min.X) || !near(large.Min.Y, want.Min.Y) || !near(large.Max.X, want.Max.X) || !near(large.Max.Y, want.Max.Y) {
+		t.Errorf("%s at 120 pixels covers %v, want about %v (twice %v)", name, large, want, small)
+	}
+}
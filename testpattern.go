@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/fogleman/gg"
+)
+
+// testPattern returns an image to check the wiring of the panel without the
+// network or the dashboard layout: a bar of every palette color, a 1px
+// border, a crosshair through the center and a label with the size and the
+// version. A wrong color order, shifted rows or cut edges are visible at
+// a glance.
+func testPattern() (image.Image, error) {
+	dc := gg.NewContext(EPD_WIDTH, EPD_HEIGHT)
+	width, height := float64(EPD_WIDTH), float64(EPD_HEIGHT)
+
+	dc.SetColor(ColorWhite)
+	dc.Clear()
+
+	// The bars are inset, so the border is visible next to every color.
+	const margin = 10
+	barWidth := (width - 2*margin) / float64(len(panelColors))
+	for i, c := range panelColors {
+		dc.SetColor(c.color)
+		dc.DrawRectangle(margin+float64(i)*barWidth, margin, barWidth, height*0.6)
+		dc.Fill()
+	}
+
+	// The border and the crosshair are drawn as filled rectangles, so they
+	// cover exactly one row or column of pixels.
+	dc.SetColor(color.Black)
+	for _, r := range [][4]float64{
+		{0, 0, width, 1},
+		{0, height - 1, width, 1},
+		{0, 0, 1, height},
+		{width - 1, 0, 1, height},
+		{0, height / 2, width, 1},
+		{width / 2, 0, 1, height},
+	} {
+		dc.DrawRectangle(r[0], r[1], r[2], r[3])
+	}
+	dc.Fill()
+
+	err := setFont(dc, FontBold, FontSizeSM)
+	if err != nil {
+		return nil, err
+	}
+
+	label := fmt.Sprintf("Test pattern %dx%d, %s", EPD_WIDTH, EPD_HEIGHT, versionLabel())
+	labelWidth, labelHeight := dc.MeasureString(label)
+
+	dc.SetColor(ColorWhite)
+	dc.DrawRectangle(width/2-labelWidth/2-10, height*0.8-labelHeight/2-8, labelWidth+20, labelHeight+16)
+	dc.Fill()
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(label, width/2, height*0.8, 0.5, 0.35)
+
+	return dc.Image(), nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestTestPattern(t *testing.T) {
+	// The label shows the version, which is set at build time.
+	version, buildDate := Version, BuildDate
+	Version, BuildDate = "1.2.3", "2024-01-15"
+	t.Cleanup(func() { Version, BuildDate = version, buildDate })
+
+	img, err := testPattern()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "testpattern", img)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// timezoneConfig is a [[timezones]] entry of the config.
+type timezoneConfig struct {
+	// Name is the label of the clock, e.g. "NYC"
+	Name string `toml:"name"`
+	// Timezone is the IANA name of the zone, e.g. "America/New_York"
+	Timezone string `toml:"timezone"`
+}
+
+// validate checks the i-th timezone of the config.
+func (t timezoneConfig) validate(i int) error {
+	name := t.Name
+	if name == "" {
+		name = fmt.Sprintf("#%d", i+1)
+	}
+
+	switch {
+	case t.Name == "":
+		return fmt.Errorf("timezone #%d needs a name", i+1)
+	case t.Timezone == "":
+		return fmt.Errorf("timezone %s needs a timezone", name)
+	}
+
+	if _, err := time.LoadLocation(t.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone of %s: %s", name, t.Timezone)
+	}
+
+	return nil
+}
+
+// Clock is a time zone shown by the clock row.
+type Clock struct {
+	Name     string
+	Location *time.Location
+}
+
+// clocksFrom loads the time zones of the config. Invalid ones are rejected
+// by the config validation, so they are only skipped here.
+func clocksFrom(timezones []timezoneConfig) []Clock {
+	var clocks []Clock
+	for _, tz := range timezones {
+		location, err := time.LoadLocation(tz.Timezone)
+		if err != nil {
+			slog.Warn("failed to load timezone", "name", tz.Name, "timezone", tz.Timezone, "error", err)
+			continue
+		}
+		clocks = append(clocks, Clock{Name: tz.Name, Location: location})
+	}
+	return clocks
+}
+
+// clocksLabel returns the time in every zone, e.g. "Berlin 14:32 | NYC 08:32 | UTC 12:32".
+func clocksLabel(clocks []Clock, now time.Time, format TimeFormat) string {
+	parts := make([]string, len(clocks))
+	for i, clock := range clocks {
+		parts[i] = clock.Name + " " + format.Clock(now.In(clock.Location))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// drawClocks draws the time in every configured zone in a row centered
+// below the heading.
+func drawClocks(dc *dashboardCanvas, config *DashboardConfig, now time.Time) error {
+	err := setFont(dc.Context, FontRegular, FontSizeXXXS)
+	if err != nil {
+		return err
+	}
+
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(
+		clocksLabel(config.Clocks, now, config.TimeFormat),
+		float64(config.Width/2),
+		float64(config.Padding+58),
+		0.5, 0,
+	)
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TodoSource provides the open tasks.
+type TodoSource interface {
+	// FetchTodos returns the open tasks in any order.
+	FetchTodos(ctx context.Context) ([]TodoItem, error)
+}
+
+// TodoItem is an open task.
+type TodoItem struct {
+	Title string
+	// DueDate is the date (at midnight) or the time the task is due, nil if it has none
+	DueDate *time.Time
+	// Priority is 1 (highest) to 4 (none), like the P1 to P4 of Todoist
+	Priority int
+}
+
+// lowestTodoPriority is the priority of tasks without one.
+const lowestTodoPriority = 4
+
+// todoistEndpoint is the base URL of the Todoist REST API.
+var todoistEndpoint = "https://api.todoist.com/rest/v2"
+
+// TodoistSource reads the active tasks from the Todoist REST API v2.
+type TodoistSource struct {
+	// APIToken is the personal API token of the Todoist integrations settings
+	APIToken string
+	// Location is the timezone of the due dates without a time
+	Location *time.Location
+	// Client fetches the tasks
+	Client *http.Client
+}
+
+// NewTodoistSource creates a Todoist source authenticating with the API token.
+func NewTodoistSource(apiToken string, location *time.Location, client *http.Client) *TodoistSource {
+	return &TodoistSource{APIToken: apiToken, Location: location, Client: client}
+}
+
+// todoistTask is the part of a Todoist task we use.
+type todoistTask struct {
+	Content string `json:"content"`
+	// Priority is 1 (normal) to 4 (urgent)
+	Priority int `json:"priority"`
+	Due      *struct {
+		Date     string `json:"date"`
+		Datetime string `json:"datetime"`
+	} `json:"due"`
+}
+
+// FetchTodos fetches the active tasks.
+func (t *TodoistSource) FetchTodos(ctx context.Context) ([]TodoItem, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, todoistEndpoint+"/tasks", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.APIToken)
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch todos: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch todos: unexpected status %s", resp.Status)
+	}
+
+	var tasks []todoistTask
+	if err = json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+		return nil, fmt.Errorf("failed to decode todos: %w", err)
+	}
+
+	items := make([]TodoItem, 0, len(tasks))
+	for _, task := range tasks {
+		item := TodoItem{
+			Title: task.Content,
+			// Todoist's API counts the other way round than its P1 to P4.
+			Priority: min(max(lowestTodoPriority+1-task.Priority, 1), lowestTodoPriority),
+		}
+
+		if task.Due != nil {
+			var due time.Time
+			if task.Due.Datetime != "" {
+				due, err = time.Parse(time.RFC3339, task.Due.Datetime)
+			} else {
+				due, err = time.ParseInLocation(time.DateOnly, task.Due.Date, t.Location)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid due date of todo %q: %w", task.Content, err)
+			}
+			item.DueDate = &due
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// TodoTxtSource reads the open tasks from a file in the todo.txt format
+// (http://todotxt.org), e.g. "(A) Call Mom +Family due:2025-01-15".
+type TodoTxtSource struct {
+	// Path is the todo.txt file
+	Path string
+	// Location is the timezone of the due dates
+	Location *time.Location
+}
+
+// NewTodoTxtSource creates a source reading the todo.txt file at path.
+func NewTodoTxtSource(path string, location *time.Location) *TodoTxtSource {
+	return &TodoTxtSource{Path: path, Location: location}
+}
+
+// FetchTodos reads the file again on every call.
+func (t *TodoTxtSource) FetchTodos(ctx context.Context) ([]TodoItem, error) {
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read todos: %w", err)
+	}
+	defer f.Close()
+
+	var items []TodoItem
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if item, ok := parseTodoTxtLine(scanner.Text(), t.Location); ok {
+			items = append(items, item)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read todos: %w", err)
+	}
+
+	return items, nil
+}
+
+// parseTodoTxtLine parses a task of a todo.txt file. It returns false for
+// empty lines and completed tasks. The priorities (A) to (C) become 1 to 3,
+// every other task gets the lowest priority. The due date is taken from the
+// due: tag, which is removed from the title together with the creation date.
+func parseTodoTxtLine(line string, location *time.Location) (TodoItem, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] == "x" {
+		return TodoItem{}, false
+	}
+
+	item := TodoItem{Priority: lowestTodoPriority}
+	if p := fields[0]; len(p) == 3 && p[0] == '(' && p[2] == ')' && p[1] >= 'A' && p[1] <= 'Z' {
+		if p[1] <= 'C' {
+			item.Priority = int(p[1]-'A') + 1
+		}
+		fields = fields[1:]
+	}
+	if len(fields) > 0 {
+		if _, err := time.Parse(time.DateOnly, fields[0]); err == nil {
+			fields = fields[1:]
+		}
+	}
+
+	var title []string
+	for _, field := range fields {
+		if value, ok := strings.CutPrefix(field, "due:"); ok {
+			if due, err := time.ParseInLocation(time.DateOnly, value, location); err == nil {
+				item.DueDate = &due
+				continue
+			}
+		}
+		title = append(title, field)
+	}
+	item.Title = strings.Join(title, " ")
+
+	return item, item.Title != ""
+}
+
+// defaultMaxTodos is the number of tasks shown without max_todos.
+const defaultMaxTodos = 4
+
+// nextTodos returns the first n tasks sorted by due date, tasks without one
+// last, and by priority.
+func nextTodos(items []TodoItem, n int) []TodoItem {
+	items = slices.Clone(items)
+	slices.SortStableFunc(items, func(a, b TodoItem) int {
+		switch {
+		case a.DueDate == nil && b.DueDate != nil:
+			return 1
+		case a.DueDate != nil && b.DueDate == nil:
+			return -1
+		case a.DueDate != nil && !a.DueDate.Equal(*b.DueDate):
+			return a.DueDate.Compare(*b.DueDate)
+		}
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+
+	return items[:min(len(items), n)]
+}
+
+// Layout of the todo widget.
+const (
+	todoRowHeight  = 22
+	todoBadgeWidth = 26.0
+)
+
+// todoPriorityColors are the badge colors of the priorities 1 to 4.
+var todoPriorityColors = [...]color.RGBA{ColorRed, ColorYellow, ColorBlue, ColorBlack}
+
+// todosHeight returns the height of the todo widget with n tasks.
+func todosHeight(n int) int {
+	return n * todoRowHeight
+}
+
+// drawTodos draws a row per task into rect with a badge of its priority
+// like the tags of the appointments, the title and the due date. Overdue
+// dates are red.
+func drawTodos(dc *dashboardCanvas, rect image.Rectangle, items []TodoItem, now time.Time, locale Locale) error {
+	for i, item := range items {
+		baseline := float64(rect.Min.Y + (i+1)*todoRowHeight - 6)
+		left := float64(rect.Min.X)
+		priority := min(max(item.Priority, 1), lowestTodoPriority)
+
+		err := setFont(dc.Context, FontBold, FontSizeXXXS)
+		if err != nil {
+			return err
+		}
+
+		dc.SetColor(todoPriorityColors[priority-1])
+		dc.DrawRoundedRectangle(left, baseline-14, todoBadgeWidth, 18, 4)
+		dc.Fill()
+
+		// White is hard to read on yellow.
+		dc.SetColor(ColorWhite)
+		if priority == 2 {
+			dc.SetColor(ColorBlack)
+		}
+		dc.DrawStringAnchored(fmt.Sprintf("P%d", priority), left+todoBadgeWidth/2, baseline-5, 0.5, 0.35)
+
+		err = setFont(dc.Context, FontRegular, FontSizeXXS)
+		if err != nil {
+			return err
+		}
+
+		due, overdue := todoDueLabel(item.DueDate, now, locale)
+		dueW, _ := dc.MeasureString(due)
+		titleLeft := left + todoBadgeWidth + 10
+
+		dc.SetColor(color.Black)
+		dc.DrawStringAnchored(fitString(dc.Context, item.Title, float64(rect.Max.X)-titleLeft-dueW-10), titleLeft, baseline, 0, 0)
+
+		if overdue {
+			dc.SetColor(ColorRed)
+		}
+		dc.DrawStringAnchored(due, float64(rect.Max.X), baseline, 1, 0)
+	}
+
+	return nil
+}
+
+// todoDueLabel returns the due date relative to now, e.g. "Morgen" or
+// "Fr, 17.01.", and whether it is overdue. Tasks without a due date have
+// an empty label.
+func todoDueLabel(due *time.Time, now time.Time, locale Locale) (string, bool) {
+	if due == nil {
+		return "", false
+	}
+
+	english := locale == LocaleEnglish
+	switch d := daysUntil(now, *due); {
+	case d < 0 && english:
+		return "Overdue", true
+	case d < 0:
+		return "Überfällig", true
+	case d == 0 && english:
+		return "Today", false
+	case d == 0:
+		return "Heute", false
+	case d == 1 && english:
+		return "Tomorrow", false
+	case d == 1:
+		return "Morgen", false
+	case english:
+		return due.Format("Mon, Jan 2"), false
+	default:
+		return days[due.Weekday()][:2] + ", " + due.Format("02.01."), false
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"time"
+)
+
+// AppointmentView selects how the appointments are shown.
+type AppointmentView string
+
+const (
+	// AppointmentViewList shows the next appointments as a list (default)
+	AppointmentViewList AppointmentView = "list"
+	// AppointmentViewWeekGrid shows the current week as a grid with one column per day
+	AppointmentViewWeekGrid AppointmentView = "week"
+)
+
+// UnmarshalText validates an appointment view from the config file.
+func (v *AppointmentView) UnmarshalText(text []byte) error {
+	switch AppointmentView(text) {
+	case AppointmentViewList, AppointmentViewWeekGrid:
+		*v = AppointmentView(text)
+	case "":
+		*v = AppointmentViewList
+	default:
+		return fmt.Errorf("invalid appointments view: %s (expected list or week)", string(text))
+	}
+
+	return nil
+}
+
+// Layout of the week grid.
+const (
+	weekDays          = 7
+	weekHeaderHeight  = 44.0
+	weekEntryHeight   = 40.0
+	weekEntriesPerDay = 2
+)
+
+// drawWeek draws the appointments of the current week as a grid with one
+// column per day from Monday to Sunday, filling the space down to bottom.
+// Every column shows up to weekEntriesPerDay appointments and the number of
+// the remaining ones (e.g., "+2 weitere").
+func drawWeek(dc *dashboardCanvas, config *DashboardConfig, offsetTop, bottom float64, now time.Time) error {
+	// Monday is the first column.
+	today := (int(now.Weekday()) + 6) % 7
+	monday := now.AddDate(0, 0, -today)
+
+	var days [weekDays][]*Appointment
+	for _, appointment := range config.Appointments {
+		day := today + daysUntil(now, appointment.Start)
+		if day < 0 || day >= weekDays {
+			continue
+		}
+		days[day] = append(days[day], appointment)
+	}
+
+	left := float64(config.Padding * 2)
+	columnWidth := float64(config.Width-config.Padding*4) / weekDays
+
+	for i, appointments := range days {
+		date := monday.AddDate(0, 0, i)
+		x := left + float64(i)*columnWidth
+		centerX := x + columnWidth/2
+
+		// Column separator
+		if i > 0 {
+			dc.SetColor(color.Black)
+			dc.DrawRectangle(x, offsetTop, 1, bottom-offsetTop)
+			dc.Fill()
+		}
+
+		// Day header, today is inverted
+		textColor := color.Color(color.Black)
+		if i == today {
+			dc.SetColor(color.Black)
+			dc.DrawRoundedRectangle(x+3, offsetTop, columnWidth-6, weekHeaderHeight, 4)
+			dc.Fill()
+			textColor = ColorWhite
+		}
+
+		err := setFont(dc.Context, FontRegular, FontSizeXXS)
+		if err != nil {
+			return fmt.Errorf("failed to set weekday font: %w", err)
+		}
+		dc.SetColor(textColor)
+		dc.DrawStringAnchored(config.WeekdayAbbreviations[date.Weekday()], centerX, offsetTop+13, 0.5, 0.5)
+
+		err = setFont(dc.Context, FontBold, FontSizeSM)
+		if err != nil {
+			return fmt.Errorf("failed to set day font: %w", err)
+		}
+		dc.DrawStringAnchored(strconv.Itoa(date.Day()), centerX, offsetTop+31, 0.5, 0.5)
+
+		// Entries
+		err = setFont(dc.Context, FontRegular, FontSizeXXXS)
+		if err != nil {
+			return fmt.Errorf("failed to set entry font: %w", err)
+		}
+
+		entryTop := offsetTop + weekHeaderHeight + 10
+		for j, appointment := range appointments {
+			if j == weekEntriesPerDay {
+				dc.SetColor(color.Black)
+				dc.DrawStringAnchored(moreLabel(len(appointments)-j, config.Locale), centerX, entryTop+8, 0.5, 0.5)
+				break
+			}
+
+			dc.SetColor(appointment.Color)
+			dc.DrawCircle(x+8, entryTop+8, 3)
+			dc.Fill()
+
+			dc.SetColor(color.Black)
+			dc.DrawStringAnchored(config.TimeFormat.Clock(appointment.Start), x+15, entryTop+8, 0, 0.5)
+			dc.DrawStringAnchored(
+				fitString(dc.Context, appointment.Title, columnWidth-10),
+				x+5,
+				entryTop+24,
+				0, 0.5,
+			)
+
+			entryTop += weekEntryHeight
+		}
+	}
+
+	return nil
+}
+
+// moreLabel returns the label for n appointments that do not fit into a column.
+func moreLabel(n int, locale Locale) string {
+	if locale == LocaleEnglish {
+		return fmt.Sprintf("%d more", n)
+	}
+	return fmt.Sprintf("+%d weitere", n)
+}
+
+// daysUntil returns the number of calendar days from now to t in the
+// location of t (0 for today, 1 for tomorrow, and so on).
+func daysUntil(now, t time.Time) int {
+	now = now.In(t.Location())
+
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	return int(to.Sub(from).Hours() / 24)
+}
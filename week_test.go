@@ -0,0 +1,83 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+	"time"
+)
+
+// weekAppointments returns appointments around the week of now: some on
+// past days, three on one day for the overflow, and one in the next week.
+func weekAppointments(now time.Time) []*Appointment {
+	day := func(offset, hour int) time.Time {
+		return time.Date(now.Year(), now.Month(), now.Day()+offset, hour, 0, 0, 0, now.Location())
+	}
+
+	appointment := func(title string, start time.Time, c color.Color) *Appointment {
+		return &Appointment{Title: title, Start: start, Tag: "AB", Color: c}
+	}
+
+	return []*Appointment{
+		appointment("Frühstück", day(-1, 8), ColorGreen),
+		appointment("Zahnarzt mit einer sehr langen Beschreibung", day(0, 10), ColorBlue),
+		appointment("Elternabend", day(1, 19), ColorRed),
+		appointment("Training", day(2, 9), ColorGreen),
+		appointment("Kino", day(2, 14), ColorBlue),
+		appointment("Abendessen", day(2, 20), ColorRed),
+		appointment("Nächste Woche", day(7, 9), ColorBlue),
+	}
+}
+
+func TestDrawWeek(t *testing.T) {
+	tests := []struct {
+		name   string
+		now    time.Time
+		locale Locale
+	}{
+		// Friday, the overflow is on Sunday and the next week is left out.
+		{name: "week_friday", now: time.Date(2025, time.March, 14, 9, 30, 0, 0, time.UTC), locale: LocaleGerman},
+		// Monday is the first column, the day before is in the last week.
+		{name: "week_monday_english", now: time.Date(2025, time.March, 10, 9, 30, 0, 0, time.UTC), locale: LocaleEnglish},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := fixtureConfig(tt.now)
+			cfg.Now = tt.now
+			cfg.Locale = tt.locale
+			cfg.WeekdayAbbreviations = tt.locale.WeekdayAbbreviations()
+			cfg.AppointmentView = AppointmentViewWeekGrid
+			cfg.Appointments = weekAppointments(tt.now)
+
+			dc, err := GenerateDashboard(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.name, dc.Image())
+		})
+	}
+}
+
+func TestDaysUntil(t *testing.T) {
+	berlin := loadBerlin(t)
+	now := time.Date(2025, time.March, 14, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		t    time.Time
+		want int
+	}{
+		{time.Date(2025, time.March, 14, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC), -4},
+		// In Berlin, now is already on the 15th.
+		{time.Date(2025, time.March, 15, 8, 0, 0, 0, berlin), 0},
+		// The switch to summer time doesn't shorten the count.
+		{time.Date(2025, time.April, 1, 8, 0, 0, 0, berlin), 17},
+	}
+
+	for _, tt := range tests {
+		if got := daysUntil(now, tt.t); got != tt.want {
+			t.Errorf("daysUntil(%v, %v) = %d, want %d", now, tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// WordSource provides the word of the day.
+type WordSource interface {
+	FetchWord(ctx context.Context) (WordEntry, error)
+}
+
+// WordEntry is a word with its meaning.
+type WordEntry struct {
+	Word string
+	// PartOfSpeech is e.g. "noun", empty if it is not known
+	PartOfSpeech string
+	Definition   string
+}
+
+// readWordlist reads a wordlist file with a "word|definition" or a
+// "word|part of speech|definition" per line. The definition may be left
+// out if it is looked up. Empty lines and lines starting with # are skipped.
+func readWordlist(path string) ([]WordEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wordlist: %w", err)
+	}
+	defer f.Close()
+
+	var entries []WordEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "|")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		entry := WordEntry{Word: fields[0]}
+		switch len(fields) {
+		case 1:
+		case 2:
+			entry.Definition = fields[1]
+		default:
+			entry.PartOfSpeech, entry.Definition = fields[1], strings.Join(fields[2:], "|")
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read wordlist: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no words in %s", path)
+	}
+
+	return entries, nil
+}
+
+// LocalWordSource picks the word of the day from a wordlist file.
+type LocalWordSource struct {
+	// Path is the wordlist, see readWordlist
+	Path string
+	// Location is the timezone of the day
+	Location *time.Location
+}
+
+// NewLocalWordSource creates a source of the words in the wordlist at path.
+func NewLocalWordSource(path string, location *time.Location) *LocalWordSource {
+	return &LocalWordSource{Path: path, Location: location}
+}
+
+// FetchWord returns the word of today, the same one all day.
+func (s *LocalWordSource) FetchWord(ctx context.Context) (WordEntry, error) {
+	entries, err := readWordlist(s.Path)
+	if err != nil {
+		return WordEntry{}, err
+	}
+
+	return entries[dayIndex(time.Now().In(s.Location), len(entries))], nil
+}
+
+// merriamWebsterEndpoint is the base URL of the Merriam-Webster Collegiate Dictionary API.
+var merriamWebsterEndpoint = "https://www.dictionaryapi.com/api/v3/references/collegiate/json"
+
+// MerriamWebsterSource picks the word of the day from a wordlist file like
+// LocalWordSource and looks up its part of speech and definition in the
+// Merriam-Webster Collegiate Dictionary. The API has no word of the day.
+type MerriamWebsterSource struct {
+	LocalWordSource
+	// APIKey is the key of the Collegiate Dictionary
+	APIKey string
+	// Client fetches the definitions
+	Client *http.Client
+}
+
+// NewMerriamWebsterSource creates a source of the words in the wordlist at
+// path, looked up with the API key.
+func NewMerriamWebsterSource(apiKey, path string, location *time.Location, client *http.Client) *MerriamWebsterSource {
+	return &MerriamWebsterSource{
+		LocalWordSource: LocalWordSource{Path: path, Location: location},
+		APIKey:          apiKey,
+		Client:          client,
+	}
+}
+
+// FetchWord looks up the word of today.
+func (s *MerriamWebsterSource) FetchWord(ctx context.Context) (WordEntry, error) {
+	entry, err := s.LocalWordSource.FetchWord(ctx)
+	if err != nil {
+		return WordEntry{}, err
+	}
+
+	return FetchWordOfDay(ctx, s.Client, entry.Word, s.APIKey)
+}
+
+// FetchWordOfDay looks up the first meaning of word in the Merriam-Webster
+// Collegiate Dictionary.
+func FetchWordOfDay(ctx context.Context, client *http.Client, word, apiKey string) (WordEntry, error) {
+	endpoint := fmt.Sprintf("%s/%s?key=%s", merriamWebsterEndpoint, url.PathEscape(word), url.QueryEscape(apiKey))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return WordEntry{}, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return WordEntry{}, fmt.Errorf("failed to fetch word: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return WordEntry{}, fmt.Errorf("failed to fetch word: unexpected status %s", resp.Status)
+	}
+
+	// Unknown words return a list of suggested spellings instead of entries.
+	var results []json.RawMessage
+	if err = json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return WordEntry{}, fmt.Errorf("failed to decode word: %w", err)
+	}
+	if len(results) == 0 || results[0][0] == '"' {
+		return WordEntry{}, fmt.Errorf("word not found in the dictionary: %s", word)
+	}
+
+	var result struct {
+		FunctionalLabel  string   `json:"fl"`
+		ShortDefinitions []string `json:"shortdef"`
+	}
+	if err = json.Unmarshal(results[0], &result); err != nil {
+		return WordEntry{}, fmt.Errorf("failed to decode word: %w", err)
+	}
+	if len(result.ShortDefinitions) == 0 {
+		return WordEntry{}, errors.New("word has no definition: " + word)
+	}
+
+	return WordEntry{Word: word, PartOfSpeech: result.FunctionalLabel, Definition: result.ShortDefinitions[0]}, nil
+}
+
+// wordHeight is the height of the word widget below the appointments.
+const wordHeight = 40
+
+// drawWord draws the word in bold at the top of rect and below it the part
+// of speech and the definition, shortened to maxLines lines.
+func drawWord(dc *dashboardCanvas, rect image.Rectangle, entry WordEntry, size FontSize, maxLines int) error {
+	err := setFont(dc.Context, FontBold, size)
+	if err != nil {
+		return err
+	}
+
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(entry.Word, float64(rect.Min.X), float64(rect.Min.Y), 0, 1)
+	_, wordH := dc.MeasureString(entry.Word)
+
+	err = setFont(dc.Context, FontRegular, FontSizeXXS)
+	if err != nil {
+		return err
+	}
+
+	text := entry.Definition
+	if entry.PartOfSpeech != "" {
+		text = entry.PartOfSpeech + " · " + text
+	}
+	lines := wrapString(dc.Context, text, float64(rect.Dx()), maxLines)
+	dc.DrawStringWrapped(strings.Join(lines, "\n"), float64(rect.Min.X), float64(rect.Min.Y)+wordH+6, 0, 0, float64(rect.Dx()), 1.3, gg.AlignLeft)
+
+	return nil
+}